@@ -1,8 +1,9 @@
 package api
 
 import (
-	"fmt"
+	"net/http"
 
+	"github.com/ava-labs/avalanche-network-runner/utils"
 	"github.com/ava-labs/avalanchego/api/admin"
 	"github.com/ava-labs/avalanchego/api/health"
 	"github.com/ava-labs/avalanchego/api/info"
@@ -36,13 +37,18 @@ type APIClient struct {
 	cindex       indexer.Client
 }
 
-// Returns a new API client for a node at [ipAddr]:[port].
-type NewAPIClientF func(ipAddr string, port uint16) Client
+// Returns a new API client for a node at [ipAddr]:[port],
+// sending its requests with [httpClient].
+type NewAPIClientF func(ipAddr string, port uint16, httpClient *http.Client) (Client, error)
 
 // NewAPIClient initialize most of avalanchego apis
-func NewAPIClient(ipAddr string, port uint16) Client {
-	uri := fmt.Sprintf("http://%s:%d", ipAddr, port)
-	return &APIClient{
+// If [httpClient] is nil, http.DefaultClient is used.
+// The C-Chain eth client uses a websocket connection, and doesn't use [httpClient].
+// Returns an error wrapping ErrUnexpectedClientLayout if the avalanchego clients can't
+// be given [httpClient].
+func NewAPIClient(ipAddr string, port uint16, httpClient *http.Client) (Client, error) {
+	uri := "http://" + utils.JoinHostPort(ipAddr, port)
+	c := &APIClient{
 		platform:     platformvm.NewClient(uri),
 		xChain:       avm.NewClient(uri, "X"),
 		xChainWallet: avm.NewWalletClient(uri, "X"),
@@ -56,6 +62,29 @@ func NewAPIClient(ipAddr string, port uint16) Client {
 		pindex:       indexer.NewClient(uri + "/ext/index/P/block"),
 		cindex:       indexer.NewClient(uri + "/ext/index/C/block"),
 	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	for _, client := range []interface{}{
+		c.platform,
+		c.xChain,
+		c.xChainWallet,
+		c.info,
+		c.health,
+		c.ipcs,
+		c.keystore,
+		c.admin,
+		c.pindex,
+		c.cindex,
+	} {
+		if err := setHTTPClient(client, httpClient, "requester"); err != nil {
+			return nil, err
+		}
+	}
+	if err := setHTTPClient(c.cChain, httpClient, "requester", "adminRequester"); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (c APIClient) PChainAPI() platformvm.Client {
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// Transport recording the requests sent through it, answering with an empty result
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`)),
+		Request:    req,
+	}, nil
+}
+
+// Test that every avalanchego API client sends its requests with the given http client
+func TestNewAPIClientHTTPClient(t *testing.T) {
+	assert := assert.New(t)
+	transport := &recordingTransport{}
+	// no server listens on the port, the transport answers instead
+	client, err := NewAPIClient("127.0.0.1", 1, &http.Client{Transport: transport})
+	assert.NoError(err)
+	ctx := context.Background()
+	tests := []struct {
+		path   string
+		method string
+		call   func()
+	}{
+		{"/ext/P", "platform.getHeight", func() { _, _ = client.PChainAPI().GetHeight(ctx) }},
+		{"/ext/bc/X", "avm.getTxStatus", func() { _, _ = client.XChainAPI().GetTxStatus(ctx, ids.Empty) }},
+		{"/ext/bc/X/wallet", "wallet.issueTx", func() { _, _ = client.XChainWalletAPI().IssueTx(ctx, nil) }},
+		{"/ext/bc/C/avax", "avax.getAtomicTxStatus", func() { _, _ = client.CChainAPI().GetAtomicTxStatus(ctx, ids.Empty) }},
+		{"/ext/bc/C/admin", "admin.startCPUProfiler", func() { _ = client.CChainAPI().StartCPUProfiler(ctx) }},
+		{"/ext/info", "info.isBootstrapped", func() { _, _ = client.InfoAPI().IsBootstrapped(ctx, "P") }},
+		{"/ext/health", "health.readiness", func() { _, _ = client.HealthAPI().Readiness(ctx) }},
+		{"/ext/ipcs", "ipcs.getPublishedBlockchains", func() { _, _ = client.IpcsAPI().GetPublishedBlockchains(ctx) }},
+		{"/ext/keystore", "keystore.listUsers", func() { _, _ = client.KeystoreAPI().ListUsers(ctx) }},
+		{"/ext/admin", "admin.startCPUProfiler", func() { _ = client.AdminAPI().StartCPUProfiler(ctx) }},
+		{"/ext/index/P/block", "index.isAccepted", func() { _, _ = client.PChainIndexAPI().IsAccepted(ctx, ids.Empty) }},
+		{"/ext/index/C/block", "index.isAccepted", func() { _, _ = client.CChainIndexAPI().IsAccepted(ctx, ids.Empty) }},
+	}
+	for _, tt := range tests {
+		transport.requests = nil
+		tt.call()
+		if !assert.Len(transport.requests, 1, tt.path) {
+			continue
+		}
+		req := transport.requests[0]
+		assert.Equal("127.0.0.1:1", req.URL.Host)
+		assert.Equal(tt.path, req.URL.Path)
+		body := struct {
+			Method string `json:"method"`
+		}{}
+		assert.NoError(json.NewDecoder(req.Body).Decode(&body))
+		assert.Equal(tt.method, body.Method)
+	}
+}
+
+// Test that the requests keep the options given on each call
+func TestRequesterOptions(t *testing.T) {
+	assert := assert.New(t)
+	transport := &recordingTransport{}
+	client, err := NewAPIClient("127.0.0.1", 1, &http.Client{Transport: transport})
+	assert.NoError(err)
+	bootstrapped, err := client.InfoAPI().IsBootstrapped(
+		context.Background(),
+		"P",
+		rpc.WithHeader("Authorization", "Bearer token"),
+		rpc.WithQueryParam("key", "value"),
+	)
+	assert.NoError(err)
+	assert.False(bootstrapped)
+	assert.Len(transport.requests, 1)
+	req := transport.requests[0]
+	assert.Equal("Bearer token", req.Header.Get("Authorization"))
+	assert.Equal("application/json", req.Header.Get("Content-Type"))
+	assert.Equal("value", req.URL.Query().Get("key"))
+}
+
+// Test that the default headers of the http client are added unless given on the request
+func TestNewHTTPClientHeaders(t *testing.T) {
+	assert := assert.New(t)
+	transport := &recordingTransport{}
+	httpClient := NewHTTPClient(map[string]string{"Authorization": "Bearer token", "X-Custom": "custom"}, transport, 0)
+	assert.NotSame(http.DefaultClient, httpClient)
+	client, err := NewAPIClient("127.0.0.1", 1, httpClient)
+	assert.NoError(err)
+	_, err = client.InfoAPI().IsBootstrapped(context.Background(), "P", rpc.WithHeader("Authorization", "Bearer other"))
+	assert.NoError(err)
+	assert.Len(transport.requests, 1)
+	req := transport.requests[0]
+	assert.Equal("Bearer other", req.Header.Get("Authorization"))
+	assert.Equal("custom", req.Header.Get("X-Custom"))
+
+	// without headers, the requests are sent unmodified
+	transport.requests = nil
+	client, err = NewAPIClient("127.0.0.1", 1, NewHTTPClient(nil, transport, 0))
+	assert.NoError(err)
+	_, err = client.InfoAPI().IsBootstrapped(context.Background(), "P")
+	assert.NoError(err)
+	assert.Len(transport.requests, 1)
+	assert.Empty(transport.requests[0].Header.Get("Authorization"))
+}
+
+// Test that the requests fail on a non successful status code
+func TestRequesterStatusCode(t *testing.T) {
+	assert := assert.New(t)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Body:       io.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	})
+	client, err := NewAPIClient("127.0.0.1", 1, &http.Client{Transport: transport})
+	assert.NoError(err)
+	_, err = client.InfoAPI().IsBootstrapped(context.Background(), "P")
+	assert.Error(err)
+	assert.Contains(err.Error(), "received status code: 401")
+}
+
+// Test that the requests are limited by the timeout of the http client
+func TestNewHTTPClientTimeout(t *testing.T) {
+	assert := assert.New(t)
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(err)
+	port, err := strconv.ParseUint(serverURL.Port(), 10, 16)
+	assert.NoError(err)
+	client, err := NewAPIClient(serverURL.Hostname(), uint16(port), NewHTTPClient(nil, nil, 50*time.Millisecond))
+	assert.NoError(err)
+	start := time.Now()
+	_, err = client.InfoAPI().IsBootstrapped(context.Background(), "P")
+	assert.Error(err)
+	assert.Contains(err.Error(), "Timeout exceeded")
+	assert.Less(time.Since(start), 5*time.Second)
+}
+
+// Test that the http client can't be set on clients not having the expected requester fields
+func TestSetHTTPClientLayout(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		name   string
+		client interface{}
+	}{
+		{"not a pointer", struct{ requester rpc.EndpointRequester }{}},
+		{"no requester field", &struct{ other rpc.EndpointRequester }{}},
+		{"nil requester", &struct{ requester rpc.EndpointRequester }{}},
+		{"unexpected requester", &struct{ requester rpc.EndpointRequester }{&otherRequester{}}},
+		{"wrong field type", &struct{ requester string }{}},
+	}
+	for _, tt := range tests {
+		err := setHTTPClient(tt.client, http.DefaultClient, "requester")
+		assert.ErrorIs(err, ErrUnexpectedClientLayout, tt.name)
+	}
+	// the requesters of the avalanchego clients are replaced
+	client := &struct{ requester rpc.EndpointRequester }{rpc.NewEndpointRequester("http://127.0.0.1:1/ext/info", "info")}
+	assert.NoError(setHTTPClient(client, http.DefaultClient, "requester"))
+	assert.IsType(&requester{}, client.requester)
+}
+
+// Requester without the fields of the avalanchego requesters
+type otherRequester struct{}
+
+func (*otherRequester) SendRequest(context.Context, string, interface{}, interface{}, ...rpc.Option) error {
+	return nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"sync"
 
+	"github.com/ava-labs/avalanche-network-runner/utils"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/ethclient"
@@ -70,7 +71,7 @@ func NewEthClientWithChainID(ipAddr string, port uint, chainID string) EthClient
 // connect attempts to connect with websocket ethclient API
 func (c *ethClient) connect() error {
 	if c.client == nil {
-		client, err := ethclient.Dial(fmt.Sprintf("ws://%s:%d/ext/bc/%s/ws", c.ipAddr, c.port, c.chainID))
+		client, err := ethclient.Dial(fmt.Sprintf("ws://%s/ext/bc/%s/ws", utils.JoinHostPort(c.ipAddr, uint16(c.port)), c.chainID))
 		if err != nil {
 			return err
 		}
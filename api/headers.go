@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// NewHTTPClient returns an http client for the API clients of a node, adding [headers]
+// to every request, unless given on the request itself, and sending it through
+// [transport], or http.DefaultTransport if nil.
+// Each request is limited to [timeout], or not limited if 0.
+func NewHTTPClient(headers map[string]string, transport http.RoundTripper, timeout time.Duration) *http.Client {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if len(headers) == 0 {
+		return &http.Client{Transport: transport, Timeout: timeout}
+	}
+	header := http.Header{}
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+	return &http.Client{
+		Transport: &headersTransport{
+			headers: header,
+			base:    transport,
+		},
+		Timeout: timeout,
+	}
+}
+
+// headersTransport adds [headers] to each request, and sends it through [base]
+type headersTransport struct {
+	headers http.Header
+	base    http.RoundTripper
+}
+
+func (t *headersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the given request
+	req = req.Clone(req.Context())
+	for k, vs := range t.headers {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = vs
+		}
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"unsafe"
+
+	"github.com/ava-labs/avalanchego/utils/rpc"
+	json2 "github.com/gorilla/rpc/v2/json2"
+)
+
+var _ rpc.EndpointRequester = (*requester)(nil)
+
+// ErrUnexpectedClientLayout is returned when the http client of an avalanchego API client
+// can't be set, as its unexported fields aren't the ones of the supported avalanchego version
+var ErrUnexpectedClientLayout = errors.New("unexpected avalanchego API client layout")
+
+// requester sends the requests of an avalanchego API client as rpc.NewEndpointRequester
+// does, but with [httpClient] instead of http.DefaultClient
+type requester struct {
+	httpClient *http.Client
+	uri        string
+	base       string
+}
+
+func (r *requester) SendRequest(
+	ctx context.Context,
+	method string,
+	params interface{},
+	reply interface{},
+	options ...rpc.Option,
+) error {
+	uri, err := url.Parse(r.uri)
+	if err != nil {
+		return err
+	}
+	return SendJSONRequest(ctx, r.httpClient, uri, fmt.Sprintf("%s.%s", r.base, method), params, reply, options...)
+}
+
+// SendJSONRequest sends the JSON-RPC request [method] to [uri] as rpc.SendJSONRequest
+// does, but with [httpClient] instead of http.DefaultClient
+func SendJSONRequest(
+	ctx context.Context,
+	httpClient *http.Client,
+	uri *url.URL,
+	method string,
+	params interface{},
+	reply interface{},
+	options ...rpc.Option,
+) error {
+	requestBodyBytes, err := json2.EncodeClientRequest(method, params)
+	if err != nil {
+		return fmt.Errorf("failed to encode client params: %w", err)
+	}
+	ops := rpc.NewOptions(options)
+	uri.RawQuery = ops.QueryParams().Encode()
+	request, err := http.NewRequestWithContext(ctx, "POST", uri.String(), bytes.NewBuffer(requestBodyBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header = ops.Headers()
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to issue request: %w", err)
+	}
+	// Return an error for any non successful status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Drop any error during close to report the original error
+		_ = resp.Body.Close()
+		return fmt.Errorf("received status code: %d", resp.StatusCode)
+	}
+	if err := json2.DecodeClientResponse(resp.Body, reply); err != nil {
+		// Drop any error during close to report the original error
+		_ = resp.Body.Close()
+		return fmt.Errorf("failed to decode client response: %w", err)
+	}
+	return resp.Body.Close()
+}
+
+// setHTTPClient makes the avalanchego API client [client] send its requests with [httpClient].
+// The avalanchego clients can't be given an http client: they keep the rpc.EndpointRequester
+// sending their requests in unexported fields, named [fields], so these are replaced with
+// requesters for the same endpoints that use [httpClient].
+// Returns ErrUnexpectedClientLayout if [client] doesn't have these fields, e.g. after
+// an avalanchego upgrade, which the tests of every client check for.
+func setHTTPClient(client interface{}, httpClient *http.Client, fields ...string) error {
+	requesterType := reflect.TypeOf((*rpc.EndpointRequester)(nil)).Elem()
+	clientValue := reflect.ValueOf(client)
+	if clientValue.Kind() != reflect.Ptr || clientValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T is not a pointer to a struct", ErrUnexpectedClientLayout, client)
+	}
+	clientValue = clientValue.Elem()
+	for _, field := range fields {
+		fieldValue := clientValue.FieldByName(field)
+		if !fieldValue.IsValid() || fieldValue.Type() != requesterType || fieldValue.IsNil() {
+			return fmt.Errorf("%w: %T has no requester field %q", ErrUnexpectedClientLayout, client, field)
+		}
+		// the endpoint of the replaced requester, as given to rpc.NewEndpointRequester
+		endpoint := fieldValue.Elem()
+		if endpoint.Kind() != reflect.Ptr || endpoint.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("%w: unexpected requester %s of %T", ErrUnexpectedClientLayout, endpoint.Type(), client)
+		}
+		endpoint = endpoint.Elem()
+		uri, base := endpoint.FieldByName("uri"), endpoint.FieldByName("base")
+		if !uri.IsValid() || uri.Kind() != reflect.String || !base.IsValid() || base.Kind() != reflect.String {
+			return fmt.Errorf("%w: unexpected requester %s of %T", ErrUnexpectedClientLayout, endpoint.Type(), client)
+		}
+		r := &requester{
+			httpClient: httpClient,
+			uri:        uri.String(),
+			base:       base.String(),
+		}
+		reflect.NewAt(requesterType, unsafe.Pointer(fieldValue.UnsafeAddr())).Elem().Set(reflect.ValueOf(r))
+	}
+	return nil
+}
@@ -4,31 +4,51 @@
 package local
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
+	"github.com/ava-labs/avalanche-network-runner/api"
 	"github.com/ava-labs/avalanche-network-runner/network"
 	"github.com/ava-labs/avalanche-network-runner/network/node"
 	"github.com/ava-labs/avalanche-network-runner/utils"
-	"github.com/ava-labs/avalanchego/api/admin"
+	"github.com/ava-labs/avalanchego/codec"
 	"github.com/ava-labs/avalanchego/config"
 	"github.com/ava-labs/avalanchego/genesis"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/crypto"
+	"github.com/ava-labs/avalanchego/utils/formatting/address"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/ava-labs/avalanchego/utils/units"
 	"github.com/ava-labs/avalanchego/vms/platformvm"
+	"github.com/ava-labs/avalanchego/vms/platformvm/status"
+	"github.com/ava-labs/avalanchego/vms/platformvm/txs"
 	"github.com/ava-labs/avalanchego/vms/platformvm/validator"
 	"github.com/ava-labs/avalanchego/vms/secp256k1fx"
+	"github.com/ava-labs/avalanchego/wallet/chain/p"
+	"github.com/ava-labs/avalanchego/wallet/chain/x"
 	"github.com/ava-labs/avalanchego/wallet/subnet/primary"
 	"github.com/ava-labs/avalanchego/wallet/subnet/primary/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -42,12 +62,44 @@ const (
 	blockchainLogPullFrequency = time.Second
 	// check period while waiting for all validators to be ready
 	waitForValidatorsPullFrequency = time.Second
-	defaultTimeout                 = time.Minute
+	// check period while waiting for all nodes to reach a blockchain height
+	waitForChainHeightPullFrequency = time.Second
+	// timeout of the health check done on a node before using it to issue transactions
+	txNodeHealthCheckTimeout = 5 * time.Second
+	// max wait for the P-Chain of the node used to issue transactions to be bootstrapped
+	txNodeReadyTimeout = 30 * time.Second
+	// check period while waiting for the node used to issue transactions to be ready
+	txNodeReadyPullFrequency = time.Second
+	// consecutive failed checks, while waiting for the node used to issue transactions
+	// to be ready, after which the failure is reported
+	txNodeReadyMaxConsecutiveErrors = 5
+	// check period while waiting for the nodes to commit a tx
+	waitForTxCommittedPullFrequency = time.Second
+	defaultTimeout                  = time.Minute
+	// maximum factor by which a check period is increased while the checks fail,
+	// so overloaded nodes are not queried at the normal rate
+	maxPollBackoffFactor = 8
+	// name of the subnet-evm VM, whose blockchains expose an EVM RPC as the C-Chain does
+	subnetEVMName = "subnetevm"
+	// path of the EVM RPC of a blockchain endpoint
+	evmRPCPath = "/rpc"
 )
 
 var (
-	errAborted  = errors.New("aborted")
-	defaultPoll = common.WithPollFrequency(100 * time.Millisecond)
+	errAborted     = errors.New("aborted")
+	errTxRejected  = errors.New("tx rejected")
+	errNotEVMChain = errors.New("blockchain doesn't run an EVM")
+	// gets the height of a blockchain from its EVM RPC
+	evmBlockQuery = network.VMBlockQuery{
+		Method: "eth_blockNumber",
+		ParseHeight: func(result json.RawMessage) (uint64, error) {
+			var height hexutil.Uint64
+			if err := json.Unmarshal(result, &height); err != nil {
+				return 0, err
+			}
+			return uint64(height), nil
+		},
+	}
 )
 
 type blockchainInfo struct {
@@ -57,50 +109,378 @@ type blockchainInfo struct {
 	blockchainID ids.ID
 }
 
-// get an arbitrary node in the network
+// get an arbitrary node in the network, not stopped with StopNode
 func (ln *localNetwork) getSomeNode() node.Node {
 	var node node.Node
 	for _, n := range ln.nodes {
+		if atomic.LoadUint32(&n.stopped) == 1 {
+			continue
+		}
 		node = n
 		break
 	}
 	return node
 }
 
-// get node client URI for an arbitrary node in the network
-func (ln *localNetwork) getClientURI() (string, error) {
-	node := ln.getSomeNode()
-	clientURI := fmt.Sprintf("http://%s:%d", node.GetURL(), node.GetAPIPort())
-	return clientURI, nil
+// Assumes [ln.lock] is held.
+// Returns the nodes of the network not stopped with StopNode, which can be queried.
+func (ln *localNetwork) runningNodes() map[string]*localNode {
+	nodes := make(map[string]*localNode, len(ln.nodes))
+	for nodeName, node := range ln.nodes {
+		if atomic.LoadUint32(&node.stopped) == 0 {
+			nodes[nodeName] = node
+		}
+	}
+	return nodes
+}
+
+// get the node used to issue transactions, once its P-Chain is bootstrapped
+// if [op] specifies a node name, that node is used. otherwise, the first node
+// by name, in the natural order of GetNodeNames, that passes a health check is used
+func (ln *localNetwork) getTxNode(ctx context.Context, op *network.SetupOp) (node.Node, error) {
+	node, err := ln.selectTxNode(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	if err := ln.awaitTxNodeReady(ctx, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Assumes [ln.lock] is held.
+// Runs [f] with a wallet issuing txs through the node [txNodeName], knowing the txs [pTXs],
+// or with [baseWallet] if [txNodeName] is empty.
+// In the former case, a new base wallet is returned, as the txs issued by [f] spend
+// outputs that [baseWallet] still considers available.
+func (ln *localNetwork) withTxNode(
+	ctx context.Context,
+	baseWallet primary.Wallet,
+	txNodeName string,
+	pTXs []ids.ID,
+	op *network.SetupOp,
+	f func(wallet primary.Wallet) error,
+) (primary.Wallet, error) {
+	if txNodeName == "" {
+		return baseWallet, f(baseWallet)
+	}
+	wallet, err := ln.newWallet(ctx, txNodeName, pTXs, op)
+	if err != nil {
+		return nil, err
+	}
+	if err := f(wallet); err != nil {
+		return nil, err
+	}
+	return ln.newWallet(ctx, op.TxNodeName, pTXs, op)
+}
+
+// Assumes [ln.lock] is held.
+// Returns a wallet issuing txs through the node [txNodeName], or through the node
+// selected as described in getTxNode if empty, knowing the txs [pTXs].
+func (ln *localNetwork) newWallet(ctx context.Context, txNodeName string, pTXs []ids.ID, op *network.SetupOp) (primary.Wallet, error) {
+	txNodeOp := *op
+	txNodeOp.TxNodeName = txNodeName
+	txNode, err := ln.getTxNode(ctx, &txNodeOp)
+	if err != nil {
+		return nil, err
+	}
+	testKeychain, _, err := setupKeychain(op)
+	if err != nil {
+		return nil, err
+	}
+	return ln.newWalletF(ctx, txNode.GetAPIClient(), testKeychain, pTXs...)
+}
+
+// Returns a wallet for the keys of [kc], knowing the txs [preloadTXs], as
+// primary.NewWalletWithTxs does, but sending its requests through the API
+// clients [client] of a node instead of new clients with the default http client.
+func newWalletWithTxs(ctx context.Context, client api.Client, kc *secp256k1fx.Keychain, preloadTXs ...ids.ID) (primary.Wallet, error) {
+	pClient, xClient := client.PChainAPI(), client.XChainAPI()
+	pCTX, err := p.NewContextFromClients(ctx, client.InfoAPI(), xClient)
+	if err != nil {
+		return nil, err
+	}
+	xCTX, err := x.NewContextFromClients(ctx, client.InfoAPI(), xClient)
+	if err != nil {
+		return nil, err
+	}
+	utxos := primary.NewUTXOs()
+	addrs := kc.Addrs.List()
+	chains := []struct {
+		id     ids.ID
+		client primary.UTXOClient
+		codec  codec.Manager
+	}{
+		{id: constants.PlatformChainID, client: pClient, codec: txs.Codec},
+		{id: xCTX.BlockchainID(), client: xClient, codec: x.Parser.Codec()},
+	}
+	for _, destinationChain := range chains {
+		for _, sourceChain := range chains {
+			if err := primary.AddAllUTXOs(
+				ctx,
+				utxos,
+				destinationChain.client,
+				destinationChain.codec,
+				sourceChain.id,
+				destinationChain.id,
+				addrs,
+			); err != nil {
+				return nil, err
+			}
+		}
+	}
+	pTXs := make(map[ids.ID]*txs.Tx, len(preloadTXs))
+	for _, id := range preloadTXs {
+		txBytes, err := pClient.GetTx(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := txs.Parse(txs.Codec, txBytes)
+		if err != nil {
+			return nil, err
+		}
+		pTXs[id] = tx
+	}
+	pBackend := p.NewBackend(pCTX, primary.NewChainUTXOs(constants.PlatformChainID, utxos), pTXs)
+	xChainID := xCTX.BlockchainID()
+	xBackend := x.NewBackend(xCTX, xChainID, primary.NewChainUTXOs(xChainID, utxos))
+	return primary.NewWallet(
+		p.NewWallet(p.NewBuilder(kc.Addrs, pBackend), p.NewSigner(kc, pBackend), pClient, pBackend),
+		x.NewWallet(x.NewBuilder(kc.Addrs, xBackend), x.NewSigner(kc, xBackend), xClient, xBackend),
+	), nil
+}
+
+// select the node used to issue transactions, as described in getTxNode
+func (ln *localNetwork) selectTxNode(ctx context.Context, op *network.SetupOp) (node.Node, error) {
+	log := setupLogger(ln.log, op)
+	if op.TxNodeName != "" {
+		node, ok := ln.nodes[op.TxNodeName]
+		if !ok {
+			return nil, fmt.Errorf("tx node %q not found", op.TxNodeName)
+		}
+		return node, nil
+	}
+	nodeNames := make([]string, 0, len(ln.nodes))
+	for nodeName := range ln.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	utils.SortNodeNames(nodeNames)
+	for _, nodeName := range nodeNames {
+		node := ln.nodes[nodeName]
+		cctx, cancel := context.WithTimeout(ctx, txNodeHealthCheckTimeout)
+		health, err := node.GetAPIClient().HealthAPI().Health(cctx)
+		cancel()
+		if err == nil && health.Healthy {
+			return node, nil
+		}
+		log.Info("skipping unhealthy node as tx node", zap.String("node-name", nodeName), zap.Error(err))
+	}
+	return nil, errors.New("no healthy node available to issue transactions")
+}
+
+// waits for the P-Chain of [node] to be bootstrapped, as its APIs may not
+// be ready right after the network reports healthy
+// if the check fails [txNodeReadyMaxConsecutiveErrors] times in a row, the
+// last error is returned, instead of waiting for the timeout
+func (ln *localNetwork) awaitTxNodeReady(ctx context.Context, node node.Node) error {
+	cctx, cancel := context.WithTimeout(ctx, txNodeReadyTimeout)
+	defer cancel()
+	var (
+		lastErr           error
+		consecutiveErrors int
+	)
+	backoff := newPollBackoff(txNodeReadyPullFrequency)
+	for {
+		rctx, rcancel := createNodeCtx(cctx, node)
+		bootstrapped, err := node.GetAPIClient().InfoAPI().IsBootstrapped(rctx, "P")
+		rcancel()
+		if err == nil && bootstrapped {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+			consecutiveErrors++
+			ln.log.Warn("failure checking tx node P-Chain bootstrap", zap.String("node-name", node.GetName()), zap.Int("consecutive-errors", consecutiveErrors), zap.Error(err))
+			if consecutiveErrors >= txNodeReadyMaxConsecutiveErrors {
+				return fmt.Errorf("failure checking P-Chain bootstrap of tx node %q: %w", node.GetName(), err)
+			}
+		} else {
+			consecutiveErrors = 0
+			ln.log.Debug("tx node P-Chain not bootstrapped yet", zap.String("node-name", node.GetName()))
+		}
+		select {
+		case <-ln.onStopCh:
+			return errAborted
+		case <-cctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("P-Chain of tx node %q not bootstrapped: %w (last error: %s)", node.GetName(), cctx.Err(), lastErr)
+			}
+			return fmt.Errorf("P-Chain of tx node %q not bootstrapped: %w", node.GetName(), cctx.Err())
+		case <-time.After(backoff.next(err != nil)):
+		}
+	}
+}
+
+// get the API URI of [node], as logged for the node used to issue transactions
+func getClientURI(node node.Node) string {
+	return "http://" + utils.JoinHostPort(node.GetURL(), node.GetAPIPort())
+}
+
+// get the platform client given in [op], or the one of the node used to issue transactions
+func (ln *localNetwork) getPlatformClient(ctx context.Context, op *network.SetupOp) (platformvm.Client, error) {
+	if op.PlatformClient != nil {
+		return op.PlatformClient, nil
+	}
+	txNode, err := ln.getTxNode(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	return txNode.GetAPIClient().PChainAPI(), nil
 }
 
 func (ln *localNetwork) CreateBlockchains(
 	ctx context.Context,
 	chainSpecs []network.BlockchainSpec, // VM name + genesis bytes
-) error {
+	opts ...network.SetupOption,
+) ([]network.Endpoint, error) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+	return ln.deployBlockchains(ctx, chainSpecs, opts...)
+}
+
+// See network.Network
+func (ln *localNetwork) DeployBlockchain(
+	ctx context.Context,
+	subnetID ids.ID,
+	chainSpec network.BlockchainSpec,
+	opts ...network.SetupOption,
+) ([]network.Endpoint, error) {
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
-	chainInfos, err := ln.installCustomChains(ctx, chainSpecs)
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	if subnetID == constants.PrimaryNetworkID {
+		return nil, errors.New("can't deploy a blockchain to the primary network")
+	}
+	subnetIDStr := subnetID.String()
+	chainSpec.SubnetId = &subnetIDStr
+	return ln.deployBlockchains(ctx, []network.BlockchainSpec{chainSpec}, opts...)
+}
+
+// Assumes [ln.lock] is held.
+// Creates the blockchains of [chainSpecs], and the subnets of the ones without one,
+// and waits for them to be ready.
+func (ln *localNetwork) deployBlockchains(
+	ctx context.Context,
+	chainSpecs []network.BlockchainSpec,
+	opts ...network.SetupOption,
+) ([]network.Endpoint, error) {
+	if err := validateBlockchainSpecs(ctx, chainSpecs); err != nil {
+		return nil, err
+	}
+	op := network.NewSetupOp(opts...)
+	if err := validateSetupOp(op); err != nil {
+		return nil, err
+	}
+	chainInfos, err := ln.installCustomChains(ctx, chainSpecs, op)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var endpoints []network.Endpoint
+	if err := runPhase(ctx, "bootstrap", op.BootstrapTimeout, func(ctx context.Context) error {
+		return ln.waitForCustomChainsReady(ctx, chainInfos, op)
+	}); err != nil {
+		if !op.BestEffort {
+			return nil, err
+		}
+		setupLogger(ln.log, op).Warn("custom chains not ready, returning the endpoints of the nodes running them", zap.Error(err))
+		endpoints = ln.runningEndpoints(chainInfos, ln.finalizeBlockchains(chainInfos, op))
+	} else {
+		endpoints = ln.finalizeBlockchains(chainInfos, op)
+	}
+	if op.EndpointsFile != "" {
+		if err := writeEndpointsFile(op.EndpointsFile, endpoints); err != nil {
+			// the blockchains are deployed, so their endpoints are still returned
+			return endpoints, fmt.Errorf("failure writing endpoints file %q: %w", op.EndpointsFile, err)
+		}
 	}
+	return endpoints, nil
+}
+
+// Entry of the endpoints file of SetupOp.EndpointsFile
+type endpointsFileEntry struct {
+	NodeName     string `json:"nodeName"`
+	NodeID       string `json:"nodeID"`
+	BlockchainID string `json:"blockchainID"`
+	// Full URL of the blockchain API on the node
+	URL string `json:"url"`
+	// Path of the blockchain API on the node
+	Path string `json:"path"`
+}
 
-	if err := ln.waitForCustomChainsReady(ctx, chainInfos); err != nil {
+// Writes [endpoints] as JSON to [path], replacing it atomically
+func writeEndpointsFile(path string, endpoints []network.Endpoint) error {
+	entries := make([]endpointsFileEntry, len(endpoints))
+	for i, endpoint := range endpoints {
+		entries[i] = endpointsFileEntry{
+			NodeName:     endpoint.NodeName,
+			NodeID:       endpoint.NodeID.String(),
+			BlockchainID: endpoint.BlockchainID.String(),
+			URL:          endpoint.URL(),
+			Path:         endpoint.Path,
+		}
+	}
+	entriesJSON, err := json.MarshalIndent(entries, "", "    ")
+	if err != nil {
 		return err
 	}
-	return nil
+	return writeFileAtomic(path, entriesJSON)
+}
+
+// Assumes [ln.lock] is held.
+// Returns the [endpoints] of the nodes that started their blockchain, as given by its log
+// file, logging the ID of each blockchain of [chainInfos] not started on any node.
+func (ln *localNetwork) runningEndpoints(chainInfos []blockchainInfo, endpoints []network.Endpoint) []network.Endpoint {
+	runningEndpoints := []network.Endpoint{}
+	running := map[ids.ID]struct{}{}
+	for _, endpoint := range endpoints {
+		node, ok := ln.nodes[endpoint.NodeName]
+		if !ok {
+			continue
+		}
+		p := filepath.Join(node.GetLogsDir(), endpoint.BlockchainID.String()+".log")
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		running[endpoint.BlockchainID] = struct{}{}
+		runningEndpoints = append(runningEndpoints, endpoint)
+	}
+	for _, chainInfo := range chainInfos {
+		if _, ok := running[chainInfo.blockchainID]; !ok {
+			ln.log.Warn("custom chain not running on any node",
+				zap.String("vm-name", chainInfo.chainName),
+				zap.String("blockchain-ID", chainInfo.blockchainID.String()),
+			)
+		}
+	}
+	return runningEndpoints
 }
 
 func (ln *localNetwork) CreateSubnets(
 	ctx context.Context,
 	numSubnets uint32,
-) error {
+	opts ...network.SetupOption,
+) ([]ids.ID, error) {
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
-	if _, err := ln.setupWalletAndInstallSubnets(ctx, numSubnets); err != nil {
-		return err
+	op := network.NewSetupOp(opts...)
+	if err := validateSetupOp(op); err != nil {
+		return nil, err
 	}
-	return nil
+	return ln.setupWalletAndInstallSubnets(ctx, numSubnets, op)
 }
 
 // provisions local cluster and install custom chains if applicable
@@ -108,15 +488,23 @@ func (ln *localNetwork) CreateSubnets(
 func (ln *localNetwork) installCustomChains(
 	ctx context.Context,
 	chainSpecs []network.BlockchainSpec,
+	op *network.SetupOp,
 ) ([]blockchainInfo, error) {
+	log := setupLogger(ln.log, op)
 	println()
-	ln.log.Info(logging.Blue.Wrap(logging.Bold.Wrap("create and install custom chains")))
+	log.Info(logging.Blue.Wrap(logging.Bold.Wrap("create and install custom chains")))
 
-	clientURI, err := ln.getClientURI()
+	if err := ln.checkNetworkIDs(ctx, op.MaxConcurrency); err != nil {
+		return nil, err
+	}
+	txNode, err := ln.getTxNode(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	platformCli, err := ln.getPlatformClient(ctx, op)
 	if err != nil {
 		return nil, err
 	}
-	platformCli := platformvm.NewClient(clientURI)
 
 	// wallet needs txs for all previously created subnets
 	var pTXs []ids.ID
@@ -134,7 +522,7 @@ func (ln *localNetwork) installCustomChains(
 		}
 	}
 
-	baseWallet, avaxAssetID, testKeyAddr, err := setupWallet(ctx, clientURI, pTXs, ln.log)
+	baseWallet, avaxAssetID, testKeyAddr, err := ln.setupWallet(ctx, txNode, pTXs, op, log)
 	if err != nil {
 		return nil, err
 	}
@@ -149,15 +537,32 @@ func (ln *localNetwork) installCustomChains(
 		}
 	}
 
-	if err := ln.addPrimaryValidators(ctx, platformCli, baseWallet, testKeyAddr); err != nil {
+	if err := runPhase(ctx, "validators", op.ValidatorsTimeout, func(ctx context.Context) error {
+		if op.MaxClockSkew > 0 {
+			if err := ln.checkClockSkew(ctx, op.MaxClockSkew, op.MaxConcurrency); err != nil {
+				return err
+			}
+		}
+		var err error
+		baseWallet, err = ln.withTxNode(ctx, baseWallet, op.ValidatorsTxNodeName, pTXs, op, func(wallet primary.Wallet) error {
+			if err := ln.addPrimaryValidators(ctx, platformCli, wallet, testKeyAddr, ln.nodes, op); err != nil {
+				return err
+			}
+			return ln.addDelegators(ctx, platformCli, wallet, testKeyAddr, op.Delegators, op)
+		})
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
 	if numSubnets > 0 {
 		var addedSubnetIDs []ids.ID
 		// add missing subnets, restarting network and waiting for subnet validation to start
-		baseWallet, addedSubnetIDs, err = ln.installSubnets(ctx, numSubnets, baseWallet, testKeyAddr, pTXs)
-		if err != nil {
+		if err := runPhase(ctx, "subnet creation", op.SubnetsTimeout, func(ctx context.Context) error {
+			var err error
+			baseWallet, addedSubnetIDs, err = ln.installSubnets(ctx, numSubnets, baseWallet, testKeyAddr, pTXs, op)
+			return err
+		}); err != nil {
 			return nil, err
 		}
 
@@ -180,21 +585,77 @@ func (ln *localNetwork) installCustomChains(
 		}
 		subnetIDs = append(subnetIDs, subnetID)
 	}
-	clientURI, err = ln.getClientURI()
+	chainSpecs, err = ln.renderGenesisTemplates(chainSpecs)
 	if err != nil {
 		return nil, err
 	}
-	platformCli = platformvm.NewClient(clientURI)
-	if err = ln.addSubnetValidators(ctx, platformCli, baseWallet, subnetIDs); err != nil {
+	platformCli, err = ln.getPlatformClient(ctx, op)
+	if err != nil {
 		return nil, err
 	}
-
-	if err := ln.reloadVMPlugins(ctx); err != nil {
+	if err := runPhase(ctx, "validators", op.ValidatorsTimeout, func(ctx context.Context) error {
+		if err := checkSubnetSigners(ctx, platformCli, subnetIDs, op); err != nil {
+			return err
+		}
+		var err error
+		baseWallet, err = ln.withTxNode(ctx, baseWallet, op.ValidatorsTxNodeName, subnetIDs, op, func(wallet primary.Wallet) error {
+			return ln.addSubnetValidators(ctx, platformCli, wallet, subnetIDs, ln.nodes, op)
+		})
+		if err != nil {
+			return err
+		}
+		// added validators only become active at their start time, so wait for them
+		// before creating the blockchains, for the nodes to validate them from the start
+		return ln.waitSubnetValidators(ctx, platformCli, subnetIDs, ln.runningNodes(), op)
+	}); err != nil {
 		return nil, err
 	}
 
-	blockchainIDs, err := createBlockchains(ctx, chainSpecs, baseWallet, testKeyAddr, ln.log)
-	if err != nil {
+	var blockchainIDs []ids.ID
+	if err := runPhase(ctx, "blockchain creation", op.BlockchainsTimeout, func(ctx context.Context) error {
+		if err := ln.reloadVMPlugins(ctx); err != nil {
+			return err
+		}
+		blockchainIDs = make([]ids.ID, len(chainSpecs))
+		if op.IfNotExists {
+			var err error
+			blockchainIDs, err = ln.getExistingBlockchainIDs(ctx, chainSpecs)
+			if err != nil {
+				return err
+			}
+		}
+		newChainSpecs := []network.BlockchainSpec{}
+		for i, chainSpec := range chainSpecs {
+			if blockchainIDs[i] == ids.Empty {
+				newChainSpecs = append(newChainSpecs, chainSpec)
+			}
+		}
+		var (
+			newBlockchainIDs []ids.ID
+			err              error
+		)
+		baseWallet, err = ln.withTxNode(ctx, baseWallet, op.BlockchainsTxNodeName, subnetIDs, op, func(wallet primary.Wallet) error {
+			var err error
+			newBlockchainIDs, err = createBlockchains(ctx, newChainSpecs, wallet, testKeyAddr, log, op)
+			if err != nil {
+				return err
+			}
+			// the ID of a blockchain is the ID of the tx creating it
+			return ln.awaitTxsCommitted(ctx, newBlockchainIDs, op)
+		})
+		if err != nil {
+			return err
+		}
+		j := 0
+		for i := range blockchainIDs {
+			if blockchainIDs[i] == ids.Empty {
+				blockchainIDs[i] = newBlockchainIDs[j]
+				j++
+			}
+		}
+		// the existing blockchains already run with their chain configs
+		return ln.restartNodesWithChainConfigs(ctx, newChainSpecs, newBlockchainIDs)
+	}); err != nil {
 		return nil, err
 	}
 
@@ -219,12 +680,12 @@ func (ln *localNetwork) installCustomChains(
 	}
 
 	println()
-	ln.log.Info(logging.Green.Wrap("checking the remaining balance of the base wallet"))
+	log.Info(logging.Green.Wrap("checking the remaining balance of the base wallet"))
 	balances, err := baseWallet.P().Builder().GetBalance()
 	if err != nil {
 		return nil, err
 	}
-	ln.log.Info("base wallet AVAX balance", zap.Uint64("balance", balances[avaxAssetID]), zap.String("address", testKeyAddr.String()))
+	log.Info("base wallet AVAX balance", zap.Uint64("balance", balances[avaxAssetID]), zap.String("address", testKeyAddr.String()))
 
 	return chainInfos, nil
 }
@@ -232,52 +693,85 @@ func (ln *localNetwork) installCustomChains(
 func (ln *localNetwork) setupWalletAndInstallSubnets(
 	ctx context.Context,
 	numSubnets uint32,
+	op *network.SetupOp,
 ) ([]ids.ID, error) {
+	log := setupLogger(ln.log, op)
 	println()
-	ln.log.Info(logging.Blue.Wrap(logging.Bold.Wrap("create subnets")))
+	log.Info(logging.Blue.Wrap(logging.Bold.Wrap("create subnets")))
 
-	clientURI, err := ln.getClientURI()
+	if err := ln.checkNetworkIDs(ctx, op.MaxConcurrency); err != nil {
+		return nil, err
+	}
+	txNode, err := ln.getTxNode(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	platformCli, err := ln.getPlatformClient(ctx, op)
 	if err != nil {
 		return nil, err
 	}
-	platformCli := platformvm.NewClient(clientURI)
 
 	pTXs := []ids.ID{}
-	baseWallet, avaxAssetID, testKeyAddr, err := setupWallet(ctx, clientURI, pTXs, ln.log)
+	baseWallet, avaxAssetID, testKeyAddr, err := ln.setupWallet(ctx, txNode, pTXs, op, log)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := ln.addPrimaryValidators(ctx, platformCli, baseWallet, testKeyAddr); err != nil {
+	if err := runPhase(ctx, "validators", op.ValidatorsTimeout, func(ctx context.Context) error {
+		if op.MaxClockSkew > 0 {
+			if err := ln.checkClockSkew(ctx, op.MaxClockSkew, op.MaxConcurrency); err != nil {
+				return err
+			}
+		}
+		var err error
+		baseWallet, err = ln.withTxNode(ctx, baseWallet, op.ValidatorsTxNodeName, pTXs, op, func(wallet primary.Wallet) error {
+			if err := ln.addPrimaryValidators(ctx, platformCli, wallet, testKeyAddr, ln.nodes, op); err != nil {
+				return err
+			}
+			return ln.addDelegators(ctx, platformCli, wallet, testKeyAddr, op.Delegators, op)
+		})
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
 	// add subnets restarting network if necessary
-	baseWallet, subnetIDs, err := ln.installSubnets(ctx, numSubnets, baseWallet, testKeyAddr, pTXs)
-	if err != nil {
+	var subnetIDs []ids.ID
+	if err := runPhase(ctx, "subnet creation", op.SubnetsTimeout, func(ctx context.Context) error {
+		var err error
+		baseWallet, subnetIDs, err = ln.installSubnets(ctx, numSubnets, baseWallet, testKeyAddr, pTXs, op)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
-	clientURI, err = ln.getClientURI()
+	platformCli, err = ln.getPlatformClient(ctx, op)
 	if err != nil {
 		return nil, err
 	}
-	platformCli = platformvm.NewClient(clientURI)
-	if err = ln.addSubnetValidators(ctx, platformCli, baseWallet, subnetIDs); err != nil {
-		return nil, err
-	}
-
-	if err = ln.waitSubnetValidators(ctx, platformCli, subnetIDs); err != nil {
+	if err := runPhase(ctx, "validators", op.ValidatorsTimeout, func(ctx context.Context) error {
+		if err := checkSubnetSigners(ctx, platformCli, subnetIDs, op); err != nil {
+			return err
+		}
+		var err error
+		baseWallet, err = ln.withTxNode(ctx, baseWallet, op.ValidatorsTxNodeName, subnetIDs, op, func(wallet primary.Wallet) error {
+			return ln.addSubnetValidators(ctx, platformCli, wallet, subnetIDs, ln.nodes, op)
+		})
+		if err != nil {
+			return err
+		}
+		return ln.waitSubnetValidators(ctx, platformCli, subnetIDs, ln.runningNodes(), op)
+	}); err != nil {
 		return nil, err
 	}
 
 	println()
-	ln.log.Info(logging.Green.Wrap("checking the remaining balance of the base wallet"))
+	log.Info(logging.Green.Wrap("checking the remaining balance of the base wallet"))
 	balances, err := baseWallet.P().Builder().GetBalance()
 	if err != nil {
 		return nil, err
 	}
-	ln.log.Info("base wallet AVAX balance", zap.Uint64("balance", balances[avaxAssetID]), zap.String("address", testKeyAddr.String()))
+	log.Info("base wallet AVAX balance", zap.Uint64("balance", balances[avaxAssetID]), zap.String("address", testKeyAddr.String()))
 
 	return subnetIDs, nil
 }
@@ -288,31 +782,42 @@ func (ln *localNetwork) installSubnets(
 	baseWallet primary.Wallet,
 	testKeyAddr ids.ShortID,
 	pTXs []ids.ID,
+	op *network.SetupOp,
 ) (primary.Wallet, []ids.ID, error) {
+	log := setupLogger(ln.log, op)
 	println()
-	ln.log.Info(logging.Blue.Wrap(logging.Bold.Wrap("add subnets")))
+	log.Info(logging.Blue.Wrap(logging.Bold.Wrap("add subnets")))
 
-	subnetIDs, err := createSubnets(ctx, numSubnets, baseWallet, testKeyAddr, ln.log)
+	subnetIDs, err := createSubnets(ctx, numSubnets, baseWallet, testKeyAddr, log, op)
 	if err != nil {
 		return nil, nil, err
 	}
+	// the subnets are read from the nodes when restarting them
+	if err := ln.awaitTxsCommitted(ctx, subnetIDs, op); err != nil {
+		return nil, nil, err
+	}
 	if numSubnets > 0 {
-		if err = ln.restartNodesWithWhitelistedSubnets(ctx, subnetIDs); err != nil {
+		// picked up by the nodes when restarted
+		ln.setSubnetConfigs(subnetIDs, op)
+		if err = ln.restartNodesWithWhitelistedSubnets(ctx, subnetIDs, op); err != nil {
 			return nil, nil, err
 		}
 		println()
-		ln.log.Info(logging.Green.Wrap("reconnecting the wallet client after restart"))
-		clientURI, err := ln.getClientURI()
+		log.Info(logging.Green.Wrap("reconnecting the wallet client after restart"))
+		txNode, err := ln.getTxNode(ctx, op)
+		if err != nil {
+			return nil, nil, err
+		}
+		testKeychain, _, err := setupKeychain(op)
 		if err != nil {
 			return nil, nil, err
 		}
-		testKeychain := secp256k1fx.NewKeychain(genesis.EWOQKey)
 		allTxs := append(pTXs, subnetIDs...)
-		baseWallet, err = primary.NewWalletWithTxs(ctx, clientURI, testKeychain, allTxs...)
+		baseWallet, err = ln.newWalletF(ctx, txNode.GetAPIClient(), testKeychain, allTxs...)
 		if err != nil {
 			return nil, nil, err
 		}
-		ln.log.Info("set up base wallet with pre-funded test key address", zap.String("endpoint", clientURI), zap.String("address", testKeyAddr.String()))
+		log.Info("set up base wallet with pre-funded test key address", zap.String("endpoint", getClientURI(txNode)), zap.String("address", testKeyAddr.String()))
 	}
 	return baseWallet, subnetIDs, nil
 }
@@ -320,11 +825,13 @@ func (ln *localNetwork) installSubnets(
 func (ln *localNetwork) waitForCustomChainsReady(
 	ctx context.Context,
 	chainInfos []blockchainInfo,
+	op *network.SetupOp,
 ) error {
+	log := setupLogger(ln.log, op)
 	println()
-	ln.log.Info(logging.Blue.Wrap(logging.Bold.Wrap("waiting for custom chains to report healthy...")))
+	log.Info(logging.Blue.Wrap(logging.Bold.Wrap("waiting for custom chains to report healthy...")))
 
-	if err := ln.healthy(ctx); err != nil {
+	if err := ln.awaitChainsReadyQuorum(ctx, chainInfos, op); err != nil {
 		return err
 	}
 
@@ -332,62 +839,210 @@ func (ln *localNetwork) waitForCustomChainsReady(
 	for _, chainInfo := range chainInfos {
 		subnetIDs = append(subnetIDs, chainInfo.subnetID)
 	}
-	clientURI, err := ln.getClientURI()
+	platformCli, err := ln.getPlatformClient(ctx, op)
 	if err != nil {
 		return err
 	}
-	platformCli := platformvm.NewClient(clientURI)
-	if err := ln.waitSubnetValidators(ctx, platformCli, subnetIDs); err != nil {
+	if err := ln.waitSubnetValidators(ctx, platformCli, subnetIDs, ln.runningNodes(), op); err != nil {
 		return err
 	}
 
-	for nodeName, node := range ln.nodes {
-		ln.log.Info("inspecting node log directory for custom chain logs", zap.String("log-dir", node.GetLogsDir()), zap.String("node-name", nodeName))
-		for _, chainInfo := range chainInfos {
-			p := filepath.Join(node.GetLogsDir(), chainInfo.blockchainID.String()+".log")
-			ln.log.Info("checking log",
+	println()
+	log.Info(logging.Green.Wrap("all custom chains are running!!!"))
+
+	println()
+	log.Info(logging.Green.Wrap(logging.Bold.Wrap("all custom chains are ready on RPC server-side -- network-runner RPC client can poll and query the cluster status")))
+
+	return nil
+}
+
+// Assumes [ln.lock] is held.
+// Waits for the blockchains of [chainInfos] to be running on the fraction
+// [op.BootstrapQuorum] of the nodes, or on all of them if not given, but the
+// nodes stopped with StopNode, which are neither counted nor waited for.
+// The remaining nodes are then waited for, and logged, in the background.
+func (ln *localNetwork) awaitChainsReadyQuorum(
+	ctx context.Context,
+	chainInfos []blockchainInfo,
+	op *network.SetupOp,
+) error {
+	log := setupLogger(ln.log, op)
+	nodes := ln.runningNodes()
+	required := len(nodes)
+	if op.BootstrapQuorum > 0 {
+		// tolerance so that rounding errors don't require an extra node
+		required = int(math.Ceil(op.BootstrapQuorum*float64(len(nodes)) - 1e-9))
+	}
+	log.Info("waiting for the nodes to run the custom chains", zap.Int("required-nodes", required))
+
+	// cancelled as soon as enough nodes are ready
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		lock     sync.Mutex
+		ready    = map[string]struct{}{}
+		nodeErrs = map[string]error{}
+	)
+	// errors are recorded instead of returned, so a node failing doesn't stop the others
+	_ = forEachNode(cctx, nodes, op.MaxConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		if err := ln.awaitNodeChainsReady(ctx, node, chainInfos); err != nil {
+			lock.Lock()
+			nodeErrs[nodeName] = err
+			lock.Unlock()
+			return nil
+		}
+		lock.Lock()
+		ready[nodeName] = struct{}{}
+		if len(ready) >= required {
+			cancel()
+		}
+		lock.Unlock()
+		return nil
+	})
+
+	stragglers := map[string]*localNode{}
+	for nodeName, node := range nodes {
+		if _, ok := ready[nodeName]; !ok {
+			stragglers[nodeName] = node
+		}
+	}
+	stragglerNames := make([]string, 0, len(stragglers))
+	for nodeName := range stragglers {
+		stragglerNames = append(stragglerNames, nodeName)
+	}
+	sort.Strings(stragglerNames)
+	if len(ready) >= required {
+		if len(stragglers) > 0 {
+			log.Warn("custom chains not running yet on some nodes, waiting for them in the background", zap.Strings("node-names", stragglerNames))
+			go ln.awaitStragglers(stragglers, chainInfos, op)
+		}
+		return nil
+	}
+	errs := make([]string, 0, len(stragglerNames))
+	for _, nodeName := range stragglerNames {
+		nodeErr := nodeErrs[nodeName]
+		if nodeErr == nil {
+			nodeErr = ctx.Err()
+		}
+		errs = append(errs, fmt.Sprintf("node %q: %s", nodeName, nodeErr))
+	}
+	return fmt.Errorf("custom chains running on %d nodes, %d required: %s", len(ready), required, strings.Join(errs, "; "))
+}
+
+// Waits, for at most [op.BootstrapTimeout] if not 0, or until the network is stopped, for the
+// blockchains of [chainInfos] to be running on the nodes [stragglers], logging the
+// outcome for each of them.
+// Runs once the setup returned, so without holding [ln.lock]: a straggler restarted
+// or removed meanwhile is logged as failing.
+func (ln *localNetwork) awaitStragglers(stragglers map[string]*localNode, chainInfos []blockchainInfo, op *network.SetupOp) {
+	log := setupLogger(ln.log, op)
+	ctx, cancel := ln.newStopAwareContext(context.Background())
+	defer cancel()
+	if op.BootstrapTimeout > 0 {
+		var timeoutCancel context.CancelFunc
+		ctx, timeoutCancel = context.WithTimeout(ctx, op.BootstrapTimeout)
+		defer timeoutCancel()
+	}
+	_ = forEachNode(ctx, stragglers, op.MaxConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		if err := ln.awaitNodeChainsReady(ctx, node, chainInfos); err != nil {
+			log.Warn("custom chains not running on straggler node", zap.String("node-name", nodeName), zap.Error(err))
+			return nil
+		}
+		log.Info("custom chains running on straggler node", zap.String("node-name", nodeName))
+		return nil
+	})
+}
+
+// Waits for [node] to be healthy, and to have started the blockchains of
+// [chainInfos], as given by their log files.
+func (ln *localNetwork) awaitNodeChainsReady(ctx context.Context, node *localNode, chainInfos []blockchainInfo) error {
+	if err := ln.awaitNodeHealthy(ctx, node); err != nil {
+		return err
+	}
+	nodeName := node.GetName()
+	ln.log.Info("inspecting node log directory for custom chain logs", zap.String("log-dir", node.GetLogsDir()), zap.String("node-name", nodeName))
+	for _, chainInfo := range chainInfos {
+		p := filepath.Join(node.GetLogsDir(), chainInfo.blockchainID.String()+".log")
+		ln.log.Info("checking log",
+			zap.String("vm-ID", chainInfo.vmID.String()),
+			zap.String("subnet-ID", chainInfo.subnetID.String()),
+			zap.String("blockchain-ID", chainInfo.blockchainID.String()),
+			zap.String("path", p),
+		)
+		for {
+			_, err := os.Stat(p)
+			if err == nil {
+				ln.log.Info("found the log", zap.String("path", p))
+				break
+			}
+
+			ln.log.Info("log not found yet, retrying...",
 				zap.String("vm-ID", chainInfo.vmID.String()),
 				zap.String("subnet-ID", chainInfo.subnetID.String()),
 				zap.String("blockchain-ID", chainInfo.blockchainID.String()),
-				zap.String("path", p),
+				zap.Error(err),
 			)
-			for {
-				_, err := os.Stat(p)
-				if err == nil {
-					ln.log.Info("found the log", zap.String("path", p))
-					break
-				}
-
-				ln.log.Info("log not found yet, retrying...",
-					zap.String("vm-ID", chainInfo.vmID.String()),
-					zap.String("subnet-ID", chainInfo.subnetID.String()),
-					zap.String("blockchain-ID", chainInfo.blockchainID.String()),
-					zap.Error(err),
-				)
-				select {
-				case <-ln.onStopCh:
-					return errAborted
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(blockchainLogPullFrequency):
-				}
+			select {
+			case <-ln.onStopCh:
+				return errAborted
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(blockchainLogPullFrequency):
 			}
 		}
 	}
+	return nil
+}
 
-	println()
-	ln.log.Info(logging.Green.Wrap("all custom chains are running!!!"))
-
-	println()
-	ln.log.Info(logging.Green.Wrap(logging.Bold.Wrap("all custom chains are ready on RPC server-side -- network-runner RPC client can poll and query the cluster status")))
+// Assumes [ln.lock] is held.
+// Returns the endpoints of the blockchains of [chainInfos] on every node,
+// appending the RPC path given in [op] for the VM of each blockchain, if any.
+func (ln *localNetwork) finalizeBlockchains(
+	chainInfos []blockchainInfo,
+	op *network.SetupOp,
+) []network.Endpoint {
+	log := setupLogger(ln.log, op)
+	nodeNames := make([]string, 0, len(ln.nodes))
+	for nodeName := range ln.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
 
-	return nil
+	endpoints := []network.Endpoint{}
+	for _, chainInfo := range chainInfos {
+		path := "/ext/bc/" + chainInfo.blockchainID.String()
+		if rpcPath := op.RPCPaths[chainInfo.chainName]; rpcPath != "" {
+			if !strings.HasPrefix(rpcPath, "/") {
+				rpcPath = "/" + rpcPath
+			}
+			path += rpcPath
+		}
+		for _, nodeName := range nodeNames {
+			node := ln.nodes[nodeName]
+			endpoint := network.Endpoint{
+				NodeName:     nodeName,
+				NodeID:       node.GetNodeID(),
+				BlockchainID: chainInfo.blockchainID,
+				BaseURL:      "http://" + utils.JoinHostPort(node.GetURL(), node.GetAPIPort()),
+				Path:         path,
+			}
+			log.Info("blockchain endpoint",
+				zap.String("vm-name", chainInfo.chainName),
+				zap.String("node-name", nodeName),
+				zap.String("url", endpoint.URL()),
+			)
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
 }
 
 func (ln *localNetwork) getCurrentSubnets(ctx context.Context) ([]ids.ID, error) {
 	nonPlatformSubnets := []ids.ID{}
 	node := ln.getSomeNode()
-	subnets, err := node.GetAPIClient().PChainAPI().GetSubnets(ctx, nil)
+	cctx, cancel := createNodeCtx(ctx, node)
+	subnets, err := node.GetAPIClient().PChainAPI().GetSubnets(cctx, nil)
+	cancel()
 	if err != nil {
 		return nil, err
 	}
@@ -403,9 +1058,11 @@ func (ln *localNetwork) getCurrentSubnets(ctx context.Context) ([]ids.ID, error)
 func (ln *localNetwork) restartNodesWithWhitelistedSubnets(
 	ctx context.Context,
 	subnetIDs []ids.ID,
+	op *network.SetupOp,
 ) (err error) {
+	log := setupLogger(ln.log, op)
 	println()
-	ln.log.Info(logging.Green.Wrap("restarting each node"), zap.String("whitelisted-subnets", config.WhitelistedSubnetsKey))
+	log.Info(logging.Green.Wrap("restarting each node"), zap.String("whitelisted-subnets", config.WhitelistedSubnetsKey))
 	whitelistedSubnetIDsMap := map[string]struct{}{}
 	currentSubnets, err := ln.getCurrentSubnets(ctx)
 	if err != nil {
@@ -424,18 +1081,32 @@ func (ln *localNetwork) restartNodesWithWhitelistedSubnets(
 	sort.Strings(whitelistedSubnetIDs)
 	whitelistedSubnets := strings.Join(whitelistedSubnetIDs, ",")
 
-	ln.log.Info("restarting all nodes to whitelist subnets", zap.Strings("whitelisted-subnet-IDs", whitelistedSubnetIDs))
+	log.Info("restarting all nodes to whitelist subnets", zap.Strings("whitelisted-subnet-IDs", whitelistedSubnetIDs))
 
 	// change default setting
 	ln.flags[config.WhitelistedSubnetsKey] = whitelistedSubnets
 
-	for nodeName, node := range ln.nodes {
-		nodeConfig := node.GetConfig()
+	// nodes are removed and added back while restarting, so iterate over a fixed list of names
+	nodeNames := make([]string, 0, len(ln.nodes))
+	for nodeName := range ln.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	for _, nodeName := range nodeNames {
+		node := ln.nodes[nodeName]
+		nodeConfig := node.getConfig()
 
 		// delete node specific flag so as to use default one
 		delete(nodeConfig.Flags, config.WhitelistedSubnetsKey)
 
-		ln.log.Info("removing and adding back the node for whitelisted subnets", zap.String("node-name", nodeName))
+		// the nodes stopped with StopNode get the default when started again
+		if atomic.LoadUint32(&node.stopped) == 1 {
+			ln.setStoppedNodeConfig(node, nodeConfig)
+			continue
+		}
+
+		log.Info("removing and adding back the node for whitelisted subnets", zap.String("node-name", nodeName))
 		if err := ln.removeNode(ctx, nodeName); err != nil {
 			return err
 		}
@@ -444,29 +1115,242 @@ func (ln *localNetwork) restartNodesWithWhitelistedSubnets(
 			return err
 		}
 
-		ln.log.Info("waiting for local cluster readiness after restarting node", zap.String("node-name", nodeName))
-		if err := ln.healthy(ctx); err != nil {
+		// only the restarted node needs to be checked, the others were
+		// already healthy and are all checked again below
+		log.Info("waiting for node readiness after restart", zap.String("node-name", nodeName))
+		if err := ln.awaitNodeHealthy(ctx, ln.nodes[nodeName]); err != nil {
+			return err
+		}
+	}
+
+	log.Info("waiting for local cluster readiness after restarting nodes")
+	return ln.runningNodesHealthy(ctx, op.MaxConcurrency)
+}
+
+// See network.Network
+func (ln *localNetwork) AddSubnetValidatorNode(
+	ctx context.Context,
+	nodeConfig node.Config,
+	subnetID ids.ID,
+	privateKey string,
+	opts ...network.SetupOption,
+) (node.Node, error) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	if subnetID == constants.PrimaryNetworkID {
+		return nil, errors.New("can't add a subnet validator of the primary network")
+	}
+	if privateKey != "" {
+		opts = append(opts, network.WithFundedKey(privateKey))
+	}
+	op := network.NewSetupOp(opts...)
+	if err := validateSetupOp(op); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+
+	if err := ln.whitelistSubnet(&nodeConfig, subnetID); err != nil {
+		return nil, err
+	}
+	newNode, err := ln.addNode(nodeConfig)
+	if err != nil {
+		return nil, err
+	}
+	nodeName := newNode.GetName()
+	if err := ln.awaitNodeHealthy(ctx, ln.nodes[nodeName]); err != nil {
+		return nil, fmt.Errorf("node %q did not become healthy: %w", nodeName, err)
+	}
+
+	txNode, err := ln.getTxNode(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	platformCli, err := ln.getPlatformClient(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	// the wallet needs the subnet tx to sign the subnet validator txs
+	baseWallet, _, testKeyAddr, err := ln.setupWallet(ctx, txNode, []ids.ID{subnetID}, op, setupLogger(ln.log, op))
+	if err != nil {
+		return nil, err
+	}
+	// only the new node is added as validator, the other nodes may not track the subnet
+	newNodes := map[string]*localNode{nodeName: ln.nodes[nodeName]}
+	if err := runPhase(ctx, "validators", op.ValidatorsTimeout, func(ctx context.Context) error {
+		_, err := ln.withTxNode(ctx, baseWallet, op.ValidatorsTxNodeName, []ids.ID{subnetID}, op, func(wallet primary.Wallet) error {
+			if err := ln.addPrimaryValidators(ctx, platformCli, wallet, testKeyAddr, newNodes, op); err != nil {
+				return err
+			}
+			if err := checkSubnetSigners(ctx, platformCli, []ids.ID{subnetID}, op); err != nil {
+				return err
+			}
+			return ln.addSubnetValidators(ctx, platformCli, wallet, []ids.ID{subnetID}, newNodes, op)
+		})
+		if err != nil {
 			return err
 		}
+		return ln.waitSubnetValidators(ctx, platformCli, []ids.ID{subnetID}, newNodes, op)
+	}); err != nil {
+		return nil, fmt.Errorf("failure adding node %q as validator of subnet %s: %w", nodeName, subnetID, err)
+	}
+	return newNode, nil
+}
+
+// Assumes [ln.lock] is held.
+// Adds [subnetID] to the whitelisted subnets flag of [nodeConfig], unless the node
+// would already track it, given its config file and the network default flags,
+// so that a new node tracks the subnet without restarting it as TrackSubnet does.
+func (ln *localNetwork) whitelistSubnet(nodeConfig *node.Config, subnetID ids.ID) error {
+	var configFile map[string]interface{}
+	if nodeConfig.ConfigFile != "" {
+		if err := json.Unmarshal([]byte(nodeConfig.ConfigFile), &configFile); err != nil {
+			return fmt.Errorf("couldn't unmarshal config file of node %q: %w", nodeConfig.Name, err)
+		}
+	}
+	// copied, so that the network defaults are only used to get the current value
+	flags := copyMapStringInterface(nodeConfig.Flags)
+	addNetworkFlags(ln.log, ln.flags, flags)
+	whitelistedSubnets, err := getConfigEntry(flags, configFile, config.WhitelistedSubnetsKey, "")
+	if err != nil {
+		return err
+	}
+	whitelistedSubnetIDs := []string{}
+	for _, subnetIDStr := range strings.Split(whitelistedSubnets, ",") {
+		subnetIDStr = strings.TrimSpace(subnetIDStr)
+		if subnetIDStr == subnetID.String() {
+			return nil
+		}
+		if subnetIDStr != "" {
+			whitelistedSubnetIDs = append(whitelistedSubnetIDs, subnetIDStr)
+		}
+	}
+	whitelistedSubnetIDs = append(whitelistedSubnetIDs, subnetID.String())
+	sort.Strings(whitelistedSubnetIDs)
+	nodeConfig.Flags = copyMapStringInterface(nodeConfig.Flags)
+	nodeConfig.Flags[config.WhitelistedSubnetsKey] = strings.Join(whitelistedSubnetIDs, ",")
+	return nil
+}
+
+// Assumes [ln.lock] is held.
+// Sets the subnet config of [op], if any, as the network default for each of [subnetIDs],
+// so that it is written to the subnet config dir of every node when (re)started.
+func (ln *localNetwork) setSubnetConfigs(subnetIDs []ids.ID, op *network.SetupOp) {
+	if len(op.SubnetConfig) == 0 {
+		return
+	}
+	if ln.subnetConfigFiles == nil {
+		ln.subnetConfigFiles = map[string]string{}
+	}
+	for _, subnetID := range subnetIDs {
+		ln.subnetConfigFiles[subnetID.String()] = string(op.SubnetConfig)
+	}
+}
+
+// Assumes [ln.lock] is held.
+// Writes the chain config and upgrade config of each of [chainSpecs] that has them to
+// the chain config dir of every node, under the corresponding id of [blockchainIDs],
+// and restarts the nodes one at a time so that the blockchains run with them.
+// The nodes stopped with StopNode are not restarted, but get the configs when started again.
+// Nodes added later get the chain and upgrade configs from the network defaults.
+func (ln *localNetwork) restartNodesWithChainConfigs(
+	ctx context.Context,
+	chainSpecs []network.BlockchainSpec,
+	blockchainIDs []ids.ID,
+) error {
+	chainConfigs := map[string]string{}
+	upgradeConfigs := map[string]string{}
+	for i, chainSpec := range chainSpecs {
+		if len(chainSpec.ChainConfig) > 0 {
+			chainConfigs[blockchainIDs[i].String()] = string(chainSpec.ChainConfig)
+		}
+		if len(chainSpec.UpgradeConfig) > 0 {
+			upgradeConfigs[blockchainIDs[i].String()] = string(chainSpec.UpgradeConfig)
+		}
+	}
+	if len(chainConfigs) == 0 && len(upgradeConfigs) == 0 {
+		return nil
+	}
+
+	println()
+	ln.log.Info(logging.Green.Wrap("restarting each node with the chain configs"),
+		zap.Int("num-chain-configs", len(chainConfigs)),
+		zap.Int("num-upgrade-configs", len(upgradeConfigs)),
+	)
+
+	if ln.chainConfigFiles == nil {
+		ln.chainConfigFiles = map[string]string{}
+	}
+	for blockchainID, chainConfig := range chainConfigs {
+		ln.chainConfigFiles[blockchainID] = chainConfig
+	}
+	if ln.upgradeConfigFiles == nil {
+		ln.upgradeConfigFiles = map[string]string{}
+	}
+	for blockchainID, upgradeConfig := range upgradeConfigs {
+		ln.upgradeConfigFiles[blockchainID] = upgradeConfig
+	}
+
+	nodeNames := make([]string, 0, len(ln.nodes))
+	for nodeName := range ln.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	for _, nodeName := range nodeNames {
+		node := ln.nodes[nodeName]
+		nodeConfig := node.getConfig()
+		if nodeConfig.ChainConfigFiles == nil {
+			nodeConfig.ChainConfigFiles = map[string]string{}
+		}
+		for blockchainID, chainConfig := range chainConfigs {
+			nodeConfig.ChainConfigFiles[blockchainID] = chainConfig
+		}
+		if nodeConfig.UpgradeConfigFiles == nil {
+			nodeConfig.UpgradeConfigFiles = map[string]string{}
+		}
+		for blockchainID, upgradeConfig := range upgradeConfigs {
+			nodeConfig.UpgradeConfigFiles[blockchainID] = upgradeConfig
+		}
+		if atomic.LoadUint32(&node.stopped) == 1 {
+			ln.setStoppedNodeConfig(node, nodeConfig)
+			continue
+		}
+		ln.log.Info("restarting node with chain configs", zap.String("node-name", nodeName))
+		restartedNode, err := ln.restartNode(ctx, node, nodeConfig)
+		if err != nil {
+			return fmt.Errorf("failure restarting node %q: %w", nodeName, err)
+		}
+		if err := ln.awaitNodeHealthy(ctx, restartedNode); err != nil {
+			return fmt.Errorf("node %q did not become healthy after restart: %w", nodeName, err)
+		}
 	}
 	return nil
 }
 
-func setupWallet(
+func (ln *localNetwork) setupWallet(
 	ctx context.Context,
-	clientURI string,
+	txNode node.Node,
 	pTXs []ids.ID,
+	op *network.SetupOp,
 	log logging.Logger,
 ) (baseWallet primary.Wallet, avaxAssetID ids.ID, testKeyAddr ids.ShortID, err error) {
-	// "local/default/genesis.json" pre-funds "ewoq" key
-	testKey := genesis.EWOQKey
+	testKeychain, testKey, err := setupKeychain(op)
+	if err != nil {
+		return nil, ids.Empty, ids.ShortEmpty, err
+	}
 	testKeyAddr = testKey.PublicKey().Address()
-	testKeychain := secp256k1fx.NewKeychain(genesis.EWOQKey)
 
 	println()
 	log.Info(logging.Green.Wrap("setting up the base wallet with the seed test key"))
 
-	baseWallet, err = primary.NewWalletWithTxs(ctx, clientURI, testKeychain, pTXs...)
+	clientURI := getClientURI(txNode)
+	baseWallet, err = ln.newWalletF(ctx, txNode.GetAPIClient(), testKeychain, pTXs...)
 	if err != nil {
 		return nil, ids.Empty, ids.ShortEmpty, err
 	}
@@ -488,7 +1372,7 @@ func setupWallet(
 	return baseWallet, avaxAssetID, testKeyAddr, nil
 }
 
-// add the nodes in [nodeInfos] as validators of the primary network, in case they are not
+// add the nodes in [nodes] as validators of the primary network, in case they are not
 // the validation starts as soon as possible and its duration is as long as possible, that is,
 // it is set to max accepted duration by avalanchego
 func (ln *localNetwork) addPrimaryValidators(
@@ -496,8 +1380,11 @@ func (ln *localNetwork) addPrimaryValidators(
 	platformCli platformvm.Client,
 	baseWallet primary.Wallet,
 	testKeyAddr ids.ShortID,
+	nodes map[string]*localNode,
+	op *network.SetupOp,
 ) error {
-	ln.log.Info(logging.Green.Wrap("adding the nodes as primary network validators"))
+	log := setupLogger(ln.log, op)
+	log.Info(logging.Green.Wrap("adding the nodes as primary network validators"))
 	// ref. https://docs.avax.network/build/avalanchego-apis/p-chain/#platformgetcurrentvalidators
 	cctx, cancel := createDefaultCtx(ctx)
 	vs, err := platformCli.GetCurrentValidators(cctx, constants.PrimaryNetworkID, nil)
@@ -509,7 +1396,9 @@ func (ln *localNetwork) addPrimaryValidators(
 	for _, v := range vs {
 		curValidators[v.NodeID] = struct{}{}
 	}
-	for nodeName, node := range ln.nodes {
+	// txs are issued without waiting for each of them, and then confirmed together
+	txIDs := []ids.ID{}
+	for nodeName, node := range nodes {
 		nodeID := node.GetNodeID()
 
 		_, isValidator := curValidators[nodeID]
@@ -517,28 +1406,134 @@ func (ln *localNetwork) addPrimaryValidators(
 			continue
 		}
 
-		cctx, cancel = createDefaultCtx(ctx)
-		txID, err := baseWallet.P().IssueAddValidatorTx(
-			&validator.Validator{
-				NodeID: nodeID,
-				Start:  uint64(time.Now().Add(validationStartOffset).Unix()),
-				End:    uint64(time.Now().Add(validationDuration).Unix()),
-				Wght:   1 * units.Avax,
-			},
-			&secp256k1fx.OutputOwners{
-				Threshold: 1,
-				Addrs:     []ids.ShortID{testKeyAddr},
-			},
-			10*10000, // 10% fee percent, times 10000 to make it as shares
-			common.WithContext(cctx),
-			defaultPoll,
-		)
-		cancel()
+		var txID ids.ID
+		err := retryTransient(ctx, log, op, func(cctx context.Context) error {
+			var err error
+			txID, err = baseWallet.P().IssueAddValidatorTx(
+				&validator.Validator{
+					NodeID: nodeID,
+					Start:  uint64(time.Now().Add(validationStartOffset).Unix()),
+					End:    uint64(time.Now().Add(validationDuration).Unix()),
+					Wght:   1 * units.Avax,
+				},
+				&secp256k1fx.OutputOwners{
+					Threshold: 1,
+					Addrs:     []ids.ShortID{testKeyAddr},
+				},
+				10*10000, // 10% fee percent, times 10000 to make it as shares
+				common.WithContext(cctx),
+				common.WithAssumeDecided(),
+			)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		txIDs = append(txIDs, txID)
+		log.Info("issued primary subnet validator tx", zap.String("node-name", nodeName), zap.String("node-ID", nodeID.String()), zap.String("tx-ID", txID.String()))
+	}
+	// delegations can only be added to committed validators
+	if err := ln.awaitTxsCommitted(ctx, txIDs, op); err != nil {
+		return err
+	}
+	if len(txIDs) > 0 {
+		log.Info("added nodes as primary subnet validators", zap.Int("num-validators", len(txIDs)))
+	}
+	return nil
+}
+
+// add the given delegations to nodes that are already current or pending primary
+// network validators
+// the delegation starts as soon as possible, but not before the validation starts,
+// and ends at the time the validation ends for the node
+func (ln *localNetwork) addDelegators(
+	ctx context.Context,
+	platformCli platformvm.Client,
+	baseWallet primary.Wallet,
+	testKeyAddr ids.ShortID,
+	delegators []network.DelegatorSpec,
+	op *network.SetupOp,
+) error {
+	log := setupLogger(ln.log, op)
+	if len(delegators) == 0 {
+		return nil
+	}
+	log.Info(logging.Green.Wrap("adding delegators to primary network validators"))
+	cctx, cancel := createDefaultCtx(ctx)
+	vs, err := platformCli.GetCurrentValidators(cctx, constants.PrimaryNetworkID, nil)
+	cancel()
+	if err != nil {
+		return err
+	}
+	primaryValidatorsEndtime := make(map[ids.NodeID]time.Time)
+	for _, v := range vs {
+		primaryValidatorsEndtime[v.NodeID] = time.Unix(int64(v.EndTime), 0)
+	}
+	// txs are issued without waiting for each of them, and then confirmed together
+	txIDs := []ids.ID{}
+	for _, delegator := range delegators {
+		node, ok := ln.nodes[delegator.NodeName]
+		if !ok {
+			return fmt.Errorf("delegation target node %q not found", delegator.NodeName)
+		}
+		nodeID := node.GetNodeID()
+		if delegator.Weight == 0 {
+			return fmt.Errorf("delegation to node %q has zero weight", delegator.NodeName)
+		}
+		startTime := time.Now().Add(validationStartOffset)
+		endTime, isValidator := primaryValidatorsEndtime[nodeID]
+		if !isValidator {
+			// validators added by the setup are pending until their start time,
+			// and can be delegated to from then on
+			var (
+				validationStartTime time.Time
+				isPending           bool
+			)
+			validationStartTime, endTime, isPending, err = getPendingValidatorTimes(ctx, platformCli, constants.PrimaryNetworkID, nodeID)
+			if err != nil {
+				return err
+			}
+			if !isPending {
+				return fmt.Errorf("delegation target node %q is not a primary network validator", delegator.NodeName)
+			}
+			if validationStartTime.After(startTime) {
+				startTime = validationStartTime
+			}
+		}
+		var txID ids.ID
+		err := retryTransient(ctx, log, op, func(cctx context.Context) error {
+			var err error
+			txID, err = baseWallet.P().IssueAddDelegatorTx(
+				&validator.Validator{
+					NodeID: nodeID,
+					Start:  uint64(startTime.Unix()),
+					End:    uint64(endTime.Unix()),
+					Wght:   delegator.Weight,
+				},
+				&secp256k1fx.OutputOwners{
+					Threshold: 1,
+					Addrs:     []ids.ShortID{testKeyAddr},
+				},
+				common.WithContext(cctx),
+				common.WithAssumeDecided(),
+			)
+			return err
+		})
 		if err != nil {
 			return err
 		}
-		ln.log.Info("added node as primary subnet validator", zap.String("node-name", nodeName), zap.String("node-ID", nodeID.String()), zap.String("tx-ID", txID.String()))
+		txIDs = append(txIDs, txID)
+		log.Info("issued delegator tx",
+			zap.String("node-name", delegator.NodeName),
+			zap.String("node-ID", nodeID.String()),
+			zap.Uint64("weight", delegator.Weight),
+			zap.String("tx-ID", txID.String()),
+		)
+	}
+	if err := ln.awaitTxsCommitted(ctx, txIDs, op); err != nil {
+		return err
 	}
+	log.Info("added delegators to primary network validators", zap.Int("num-delegators", len(txIDs)))
 	return nil
 }
 
@@ -548,23 +1543,28 @@ func createSubnets(
 	baseWallet primary.Wallet,
 	testKeyAddr ids.ShortID,
 	log logging.Logger,
+	op *network.SetupOp,
 ) ([]ids.ID, error) {
 	println()
 	log.Info(logging.Green.Wrap("creating subnets VM"), zap.Uint32("num-subnets", numSubnets))
+	owners, err := subnetOwners(op, testKeyAddr)
+	if err != nil {
+		return nil, err
+	}
 	subnetIDs := make([]ids.ID, numSubnets)
 	var i uint32
 	for i = 0; i < numSubnets; i++ {
 		log.Info("creating subnet tx")
-		cctx, cancel := createDefaultCtx(ctx)
-		subnetID, err := baseWallet.P().IssueCreateSubnetTx(
-			&secp256k1fx.OutputOwners{
-				Threshold: 1,
-				Addrs:     []ids.ShortID{testKeyAddr},
-			},
-			common.WithContext(cctx),
-			defaultPoll,
-		)
-		cancel()
+		var subnetID ids.ID
+		err := retryTransient(ctx, log, op, func(cctx context.Context) error {
+			var err error
+			subnetID, err = baseWallet.P().IssueCreateSubnetTx(
+				owners,
+				common.WithContext(cctx),
+				common.WithAssumeDecided(),
+			)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -574,16 +1574,163 @@ func createSubnets(
 	return subnetIDs, nil
 }
 
-// add the nodes in [nodeInfos] as validators of the given subnets, in case they are not
+// returns an error if the settings of [op] can't be used for a setup,
+// checking them before any tx is issued
+func validateSetupOp(op *network.SetupOp) error {
+	if op.MaxConcurrency == 0 {
+		return errors.New("max concurrency must be greater than 0")
+	}
+	if op.BootstrapQuorum < 0 || op.BootstrapQuorum > 1 {
+		return fmt.Errorf("bootstrap quorum %g not in (0, 1]", op.BootstrapQuorum)
+	}
+	if len(op.SubnetConfig) > 0 && !json.Valid(op.SubnetConfig) {
+		return errors.New("subnet config is not valid JSON")
+	}
+	keychain, fundedKey, err := setupKeychain(op)
+	if err != nil {
+		return err
+	}
+	owners, err := subnetOwners(op, fundedKey.PublicKey().Address())
+	if err != nil {
+		return err
+	}
+	// the created subnets must be usable to add validators and create blockchains
+	if _, _, ok := keychain.Match(owners, 0); !ok {
+		return fmt.Errorf("%w: threshold %d of the created subnets", ErrNotEnoughSigners, owners.Threshold)
+	}
+	return nil
+}
+
+// Returns an error wrapping ErrNotEnoughSigners if the keys of [op] can't reach the
+// threshold of the control keys of some of [subnetIDs], as given by [platformCli].
+func checkSubnetSigners(ctx context.Context, platformCli platformvm.Client, subnetIDs []ids.ID, op *network.SetupOp) error {
+	if len(subnetIDs) == 0 {
+		return nil
+	}
+	keychain, _, err := setupKeychain(op)
+	if err != nil {
+		return err
+	}
+	cctx, cancel := createDefaultCtx(ctx)
+	subnets, err := platformCli.GetSubnets(cctx, subnetIDs)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failure getting subnets: %w", err)
+	}
+	for _, subnet := range subnets {
+		owners := &secp256k1fx.OutputOwners{
+			Threshold: subnet.Threshold,
+			Addrs:     subnet.ControlKeys,
+		}
+		if _, _, ok := keychain.Match(owners, 0); !ok {
+			return fmt.Errorf("%w: threshold %d of subnet %s", ErrNotEnoughSigners, subnet.Threshold, subnet.ID)
+		}
+	}
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetFundedAddresses() ([]string, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if !ln.initialized() {
+		return nil, network.ErrUndefined
+	}
+	return network.FundedAddresses(ln.genesis)
+}
+
+// See network.Network
+func (ln *localNetwork) GetFundedKeys() ([]string, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if !ln.initialized() {
+		return nil, network.ErrUndefined
+	}
+	return network.FundedKeys(ln.genesis, []*crypto.PrivateKeySECP256K1R{genesis.EWOQKey})
+}
+
+// returns the key signing the setup txs, as given by [op], or the "ewoq" key,
+// pre-funded by "local/default/genesis.json", if none is given
+func setupKey(op *network.SetupOp) (*crypto.PrivateKeySECP256K1R, error) {
+	if op.FundedKey == "" {
+		return genesis.EWOQKey, nil
+	}
+	key, err := network.ParsePrivateKey(op.FundedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid funded key: %w", err)
+	}
+	return key, nil
+}
+
+// returns the keychain signing the setup txs, holding the funded key given by [op],
+// also returned, and the subnet signing keys of [op]
+func setupKeychain(op *network.SetupOp) (*secp256k1fx.Keychain, *crypto.PrivateKeySECP256K1R, error) {
+	fundedKey, err := setupKey(op)
+	if err != nil {
+		return nil, nil, err
+	}
+	// txs are signed locally by the wallet, so the node keystore is not needed
+	keychain := secp256k1fx.NewKeychain(fundedKey)
+	for i, signingKey := range op.SubnetSigningKeys {
+		key, err := network.ParsePrivateKey(signingKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid subnet signing key %d: %w", i, err)
+		}
+		keychain.Add(key)
+	}
+	return keychain, fundedKey, nil
+}
+
+// returns the owners of the subnets to create, as given by the control keys and threshold
+// of [op], or [testKeyAddr] with threshold 1 if no control keys are given
+func subnetOwners(op *network.SetupOp, testKeyAddr ids.ShortID) (*secp256k1fx.OutputOwners, error) {
+	if len(op.ControlKeys) == 0 {
+		if op.Threshold > 1 {
+			return nil, fmt.Errorf("subnet threshold %d is greater than the number of control keys 1", op.Threshold)
+		}
+		return &secp256k1fx.OutputOwners{
+			Threshold: 1,
+			Addrs:     []ids.ShortID{testKeyAddr},
+		}, nil
+	}
+	if op.Threshold == 0 {
+		return nil, errors.New("subnet threshold must be greater than 0")
+	}
+	if int(op.Threshold) > len(op.ControlKeys) {
+		return nil, fmt.Errorf("subnet threshold %d is greater than the number of control keys %d", op.Threshold, len(op.ControlKeys))
+	}
+	addrs, err := address.ParseToIDs(op.ControlKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet control key: %w", err)
+	}
+	ids.SortShortIDs(addrs)
+	if !ids.IsSortedAndUniqueShortIDs(addrs) {
+		return nil, errors.New("repeated subnet control keys")
+	}
+	return &secp256k1fx.OutputOwners{
+		Threshold: op.Threshold,
+		Addrs:     addrs,
+	}, nil
+}
+
+// add the nodes in [nodes] as validators of the given subnets, in case they are not
 // the validation starts as soon as possible and its duration is as long as possible, that is,
-// it ends at the time the primary network validation ends for the node
+// it ends at the time the primary network validation ends for the node, which may
+// still be pending if the node was just added as primary network validator
 func (ln *localNetwork) addSubnetValidators(
 	ctx context.Context,
 	platformCli platformvm.Client,
 	baseWallet primary.Wallet,
 	subnetIDs []ids.ID,
+	nodes map[string]*localNode,
+	op *network.SetupOp,
 ) error {
-	ln.log.Info(logging.Green.Wrap("adding the nodes as subnet validators"))
+	log := setupLogger(ln.log, op)
+	log.Info(logging.Green.Wrap("adding the nodes as subnet validators"))
+	// txs are issued without waiting for each of them, and then confirmed together
+	txIDs := []ids.ID{}
 	for _, subnetID := range subnetIDs {
 		cctx, cancel := createDefaultCtx(ctx)
 		vs, err := platformCli.GetCurrentValidators(cctx, constants.PrimaryNetworkID, nil)
@@ -605,32 +1752,73 @@ func (ln *localNetwork) addSubnetValidators(
 		for _, v := range vs {
 			subnetValidators.Add(v.NodeID)
 		}
-		for nodeName, node := range ln.nodes {
+		// validators added but not started yet are also skipped, so that re-running
+		// the setup doesn't issue redundant txs
+		pendingNodeIDs, err := getPendingValidators(ctx, platformCli, subnetID)
+		if err != nil {
+			return err
+		}
+		subnetValidators.Add(pendingNodeIDs...)
+		for nodeName, node := range nodes {
 			nodeID := node.GetNodeID()
-			isValidator := subnetValidators.Contains(nodeID)
-			if isValidator {
-				continue
+			if subnetValidators.Contains(nodeID) {
+				if !op.ForceSubnetValidatorTxs {
+					log.Info("skipping node already validating subnet",
+						zap.String("node-name", nodeName),
+						zap.String("subnet-ID", subnetID.String()),
+					)
+					continue
+				}
+				log.Info("re-issuing subnet validator tx for node already validating subnet",
+					zap.String("node-name", nodeName),
+					zap.String("subnet-ID", subnetID.String()),
+				)
 			}
-			cctx, cancel := createDefaultCtx(ctx)
-			txID, err := baseWallet.P().IssueAddSubnetValidatorTx(
-				&validator.SubnetValidator{
-					Validator: validator.Validator{
-						NodeID: nodeID,
-						// reasonable delay in most/slow test environments
-						Start: uint64(time.Now().Add(validationStartOffset).Unix()),
-						End:   uint64(primaryValidatorsEndtime[nodeID].Unix()),
-						Wght:  subnetValidatorsWeight,
+			// a node not tracking the subnet would never validate it
+			trackedSubnets, err := node.GetTrackedSubnets(ctx)
+			if err != nil {
+				return fmt.Errorf("failure getting tracked subnets of node %q: %w", nodeName, err)
+			}
+			trackedSubnetsSet := ids.NewSet(len(trackedSubnets))
+			trackedSubnetsSet.Add(trackedSubnets...)
+			if !trackedSubnetsSet.Contains(subnetID) {
+				return fmt.Errorf("node %q is not configured to track subnet %s", nodeName, subnetID)
+			}
+			primaryEndTime, ok := primaryValidatorsEndtime[nodeID]
+			if !ok {
+				var isPending bool
+				_, primaryEndTime, isPending, err = getPendingValidatorTimes(ctx, platformCli, constants.PrimaryNetworkID, nodeID)
+				if err != nil {
+					return err
+				}
+				if !isPending {
+					return fmt.Errorf("node %q is not a primary network validator", nodeName)
+				}
+			}
+			var txID ids.ID
+			err = retryTransient(ctx, log, op, func(cctx context.Context) error {
+				var err error
+				txID, err = baseWallet.P().IssueAddSubnetValidatorTx(
+					&validator.SubnetValidator{
+						Validator: validator.Validator{
+							NodeID: nodeID,
+							// reasonable delay in most/slow test environments
+							Start: uint64(time.Now().Add(validationStartOffset).Unix()),
+							End:   uint64(primaryEndTime.Unix()),
+							Wght:  subnetValidatorsWeight,
+						},
+						Subnet: subnetID,
 					},
-					Subnet: subnetID,
-				},
-				common.WithContext(cctx),
-				defaultPoll,
-			)
-			cancel()
+					common.WithContext(cctx),
+					common.WithAssumeDecided(),
+				)
+				return err
+			})
 			if err != nil {
 				return err
 			}
-			ln.log.Info("added node as a subnet validator to subnet",
+			txIDs = append(txIDs, txID)
+			log.Info("issued subnet validator tx",
 				zap.String("node-name", nodeName),
 				zap.String("node-ID", nodeID.String()),
 				zap.String("subnet-ID", subnetID.String()),
@@ -638,18 +1826,26 @@ func (ln *localNetwork) addSubnetValidators(
 			)
 		}
 	}
-	return nil
+	return ln.awaitTxsCommitted(ctx, txIDs, op)
 }
 
-// waits until all nodes in [nodeInfos] start validating the given [subnetIDs]
+// waits until all nodes in [nodes] are in the current validator set of
+// each of the given [subnetIDs], that is, until they are active validators
 func (ln *localNetwork) waitSubnetValidators(
 	ctx context.Context,
 	platformCli platformvm.Client,
 	subnetIDs []ids.ID,
+	nodes map[string]*localNode,
+	op *network.SetupOp,
 ) error {
-	ln.log.Info(logging.Green.Wrap("waiting for the nodes to become subnet validators"))
+	log := setupLogger(ln.log, op)
+	log.Info(logging.Green.Wrap("waiting for the nodes to become subnet validators"))
+	// node@subnet entries seen as pending validators, waiting for their start time
+	seenPending := map[string]struct{}{}
+	backoff := newPollBackoff(waitForValidatorsPullFrequency)
 	for {
-		ready := true
+		pending := []string{}
+		failed := false
 		for _, subnetID := range subnetIDs {
 			cctx, cancel := createDefaultCtx(ctx)
 			vs, err := platformCli.GetCurrentValidators(cctx, subnetID, nil)
@@ -661,53 +1857,219 @@ func (ln *localNetwork) waitSubnetValidators(
 			for _, v := range vs {
 				subnetValidators.Add(v.NodeID)
 			}
-			for _, node := range ln.nodes {
+			// only used to make the wait comprehensible, so failures are not fatal
+			pendingValidators := ids.NodeIDSet{}
+			pendingNodeIDs, err := getPendingValidators(ctx, platformCli, subnetID)
+			if err != nil {
+				log.Debug("failure getting pending subnet validators", zap.String("subnet-ID", subnetID.String()), zap.Error(err))
+				failed = true
+			}
+			pendingValidators.Add(pendingNodeIDs...)
+			for nodeName, node := range nodes {
 				nodeID := node.GetNodeID()
-				if isValidator := subnetValidators.Contains(nodeID); !isValidator {
-					ready = false
+				entry := fmt.Sprintf("%s@%s", nodeName, subnetID)
+				_, wasPending := seenPending[entry]
+				switch {
+				case subnetValidators.Contains(nodeID):
+					if wasPending {
+						log.Info("pending subnet validator became active", zap.String("node-name", nodeName), zap.String("subnet-ID", subnetID.String()))
+						delete(seenPending, entry)
+					}
+				case pendingValidators.Contains(nodeID):
+					if !wasPending {
+						log.Info("subnet validator pending its start time", zap.String("node-name", nodeName), zap.String("subnet-ID", subnetID.String()))
+						seenPending[entry] = struct{}{}
+					}
+					pending = append(pending, entry)
+				default:
+					pending = append(pending, entry)
 				}
 			}
 		}
-		if ready {
+		if len(pending) == 0 {
 			return nil
 		}
+		sort.Strings(pending)
+		log.Debug("subnet validators not active yet", zap.Strings("pending", pending))
 		select {
 		case <-ln.onStopCh:
 			return errAborted
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(waitForValidatorsPullFrequency):
+			return fmt.Errorf("%w: subnet validators not active: %v", ctx.Err(), pending)
+		case <-time.After(backoff.next(failed)):
 		}
 	}
 }
 
-// reload VM plugins on all nodes
-func (ln *localNetwork) reloadVMPlugins(
+// Assumes [ln.lock] is held.
+// Waits for the P-Chain txs [txIDs] to be committed on the nodes.
+// Failing queries are retried, so a node that is briefly unreachable doesn't fail the wait.
+// Returns once all the nodes but [op.MaxUnconfirmedNodes] have committed the txs,
+// or with an error if some tx was rejected. At least one node must commit them,
+// whatever [op.MaxUnconfirmedNodes].
+func (ln *localNetwork) awaitTxsCommitted(
 	ctx context.Context,
+	txIDs []ids.ID,
+	op *network.SetupOp,
 ) error {
-	ln.log.Info(logging.Green.Wrap("reloading plugin binaries"))
-	for _, node := range ln.nodes {
-		uri := fmt.Sprintf("http://%s:%d", node.GetURL(), node.GetAPIPort())
-		adminCli := admin.NewClient(uri)
-		cctx, cancel := createDefaultCtx(ctx)
-		_, failedVMs, err := adminCli.LoadVMs(cctx)
-		cancel()
-		if err != nil {
-			return err
+	if len(txIDs) == 0 {
+		return nil
+	}
+	log := setupLogger(ln.log, op)
+	// the nodes stopped with StopNode can't confirm the txs
+	nodes := ln.runningNodes()
+	required := len(nodes) - int(op.MaxUnconfirmedNodes)
+	if required < 1 {
+		required = 1
+	}
+	log.Info(logging.Green.Wrap("waiting for the nodes to commit the txs"), zap.Int("required-nodes", required))
+
+	// cancelled as soon as enough nodes confirm the txs, or a tx is rejected
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		lock      sync.Mutex
+		confirmed = map[string]struct{}{}
+		nodeErrs  = map[string]error{}
+		rejectErr error
+	)
+	// errors are recorded instead of returned, so a node failing doesn't stop the others
+	_ = forEachNode(cctx, nodes, op.MaxConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		if err := ln.awaitNodeTxsCommitted(ctx, node, txIDs); err != nil {
+			lock.Lock()
+			if errors.Is(err, errTxRejected) && rejectErr == nil {
+				rejectErr = fmt.Errorf("node %q: %w", nodeName, err)
+				cancel()
+			}
+			nodeErrs[nodeName] = err
+			lock.Unlock()
+			return nil
 		}
-		if len(failedVMs) > 0 {
-			return fmt.Errorf("%d VMs failed to load: %v\n", len(failedVMs), failedVMs)
+		lock.Lock()
+		confirmed[nodeName] = struct{}{}
+		if len(confirmed) >= required {
+			cancel()
 		}
-	}
-	return nil
-}
+		lock.Unlock()
+		return nil
+	})
 
-func createBlockchains(
-	ctx context.Context,
-	chainSpecs []network.BlockchainSpec,
-	baseWallet primary.Wallet,
+	if rejectErr != nil {
+		return rejectErr
+	}
+	unconfirmed := []string{}
+	for nodeName := range nodes {
+		if _, ok := confirmed[nodeName]; !ok {
+			unconfirmed = append(unconfirmed, nodeName)
+		}
+	}
+	sort.Strings(unconfirmed)
+	if len(confirmed) >= required {
+		if len(unconfirmed) > 0 {
+			log.Warn("txs not confirmed by some nodes", zap.Strings("node-names", unconfirmed))
+		}
+		return nil
+	}
+	errs := make([]string, 0, len(unconfirmed))
+	for _, nodeName := range unconfirmed {
+		nodeErr := nodeErrs[nodeName]
+		if nodeErr == nil {
+			nodeErr = ctx.Err()
+		}
+		errs = append(errs, fmt.Sprintf("node %q: %s", nodeName, nodeErr))
+	}
+	return fmt.Errorf("txs committed by %d nodes, %d required: %s", len(confirmed), required, strings.Join(errs, "; "))
+}
+
+// Polls [node] until it commits all the P-Chain txs [txIDs].
+// Txs are only committed with new blocks, so the statuses of the pending txs are
+// only queried again once the P-Chain height of the node changes, and a poll costs
+// a single height query while no block is accepted. If the height can't be queried,
+// the statuses are queried on every poll.
+// Returns an error wrapping errTxRejected if a tx is aborted or dropped, and otherwise
+// retries until [ctx] is done, reporting then the last failure.
+func (ln *localNetwork) awaitNodeTxsCommitted(ctx context.Context, node node.Node, txIDs []ids.ID) error {
+	pending := make([]ids.ID, len(txIDs))
+	copy(pending, txIDs)
+	var (
+		lastErr error
+		// height at which all the pending tx statuses were last queried
+		checkedHeight uint64
+		checked       bool
+	)
+	backoff := newPollBackoff(waitForTxCommittedPullFrequency)
+	for {
+		platformCli := node.GetAPIClient().PChainAPI()
+		// the height is queried first, so blocks accepted during the status queries are not missed
+		cctx, cancel := createNodeCtx(ctx, node)
+		height, heightErr := platformCli.GetHeight(cctx)
+		cancel()
+		if heightErr != nil || !checked || height != checkedHeight {
+			stillPending := []ids.ID{}
+			queried := true
+			for _, txID := range pending {
+				cctx, cancel := createNodeCtx(ctx, node)
+				resp, err := platformCli.GetTxStatus(cctx, txID)
+				cancel()
+				if err != nil {
+					lastErr = fmt.Errorf("failure getting status of tx %s: %w", txID, err)
+					queried = false
+					stillPending = append(stillPending, txID)
+					continue
+				}
+				switch resp.Status {
+				case status.Committed:
+					continue
+				case status.Aborted, status.Dropped:
+					return fmt.Errorf("%w: tx %s %s %s", errTxRejected, txID, resp.Status, resp.Reason)
+				}
+				lastErr = fmt.Errorf("tx %s %s", txID, resp.Status)
+				stillPending = append(stillPending, txID)
+			}
+			pending = stillPending
+			checked = heightErr == nil && queried
+			checkedHeight = height
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ln.onStopCh:
+			return errAborted
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s", ctx.Err(), lastErr)
+		case <-time.After(backoff.next(heightErr != nil || !checked)):
+		}
+	}
+}
+
+// reload VM plugins on all nodes, but the nodes stopped with StopNode,
+// which load them when started again
+func (ln *localNetwork) reloadVMPlugins(
+	ctx context.Context,
+) error {
+	ln.log.Info(logging.Green.Wrap("reloading plugin binaries"))
+	for _, node := range ln.runningNodes() {
+		cctx, cancel := createNodeCtx(ctx, node)
+		_, failedVMs, err := node.GetAPIClient().AdminAPI().LoadVMs(cctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(failedVMs) > 0 {
+			return fmt.Errorf("%d VMs failed to load: %v\n", len(failedVMs), failedVMs)
+		}
+	}
+	return nil
+}
+
+func createBlockchains(
+	ctx context.Context,
+	chainSpecs []network.BlockchainSpec,
+	baseWallet primary.Wallet,
 	testKeyAddr ids.ShortID,
 	log logging.Logger,
+	op *network.SetupOp,
 ) ([]ids.ID, error) {
 	println()
 	log.Info(logging.Green.Wrap("creating each custom chain"))
@@ -725,21 +2087,28 @@ func createBlockchains(
 			zap.String("vm-ID", vmID.String()),
 			zap.Int("bytes length of genesis", len(vmGenesisBytes)),
 		)
-		cctx, cancel := createDefaultCtx(ctx)
 		subnetID, err := ids.FromString(*chainSpec.SubnetId)
 		if err != nil {
 			return nil, err
 		}
-		blockchainID, err := baseWallet.P().IssueCreateChainTx(
-			subnetID,
-			vmGenesisBytes,
-			vmID,
-			nil,
-			vmName,
-			common.WithContext(cctx),
-			defaultPoll,
-		)
-		cancel()
+		fxIDs, err := parseFxIDs(chainSpec.FxIDs)
+		if err != nil {
+			return nil, err
+		}
+		var blockchainID ids.ID
+		err = retryTransient(ctx, log, op, func(cctx context.Context) error {
+			var err error
+			blockchainID, err = baseWallet.P().IssueCreateChainTx(
+				subnetID,
+				vmGenesisBytes,
+				vmID,
+				fxIDs,
+				vmName,
+				common.WithContext(cctx),
+				common.WithAssumeDecided(),
+			)
+			return err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failure creating blockchain: %w", err)
 		}
@@ -756,9 +2125,858 @@ func createBlockchains(
 	return blockchainIDs, nil
 }
 
+// Assumes [ln.lock] is held.
+// Returns, for each of [chainSpecs], the ID of a blockchain of its subnet named as its VM
+// and running its VM, as created by createBlockchains, or ids.Empty if there is none.
+func (ln *localNetwork) getExistingBlockchainIDs(ctx context.Context, chainSpecs []network.BlockchainSpec) ([]ids.ID, error) {
+	blockchains, err := ln.getBlockchains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blockchainIDs := make([]ids.ID, len(chainSpecs))
+	for i, chainSpec := range chainSpecs {
+		vmID, err := utils.VMID(chainSpec.VmName)
+		if err != nil {
+			return nil, err
+		}
+		subnetID, err := ids.FromString(*chainSpec.SubnetId)
+		if err != nil {
+			return nil, err
+		}
+		for _, blockchain := range blockchains {
+			if blockchain.SubnetID == subnetID && blockchain.Name == chainSpec.VmName && blockchain.VMID == vmID {
+				ln.log.Info("using existing blockchain",
+					zap.String("vm-name", chainSpec.VmName),
+					zap.String("blockchain-ID", blockchain.ID.String()),
+				)
+				blockchainIDs[i] = blockchain.ID
+				break
+			}
+		}
+	}
+	return blockchainIDs, nil
+}
+
+// validates all given chain specs concurrently:
+// - the genesis, using each spec's GenesisValidator if given, or checking for non empty valid JSON otherwise
+// - the chain config, which must be given if required, and be valid JSON if given
+// - the upgrade config, which must be valid JSON if given
+// - the fx IDs
+func validateBlockchainSpecs(
+	ctx context.Context,
+	chainSpecs []network.BlockchainSpec,
+) error {
+	errGr, _ := errgroup.WithContext(ctx)
+	for _, chainSpec := range chainSpecs {
+		chainSpec := chainSpec
+		errGr.Go(func() error {
+			if chainSpec.GenesisTemplate != "" {
+				// the rendered genesis is validated once the subnet is known
+				if len(chainSpec.Genesis) != 0 {
+					return fmt.Errorf("both genesis and genesis template given for vm %q", chainSpec.VmName)
+				}
+				if _, err := template.New(chainSpec.VmName).Parse(chainSpec.GenesisTemplate); err != nil {
+					return fmt.Errorf("invalid genesis template for vm %q: %w", chainSpec.VmName, err)
+				}
+			} else if err := validateBlockchainGenesis(chainSpec); err != nil {
+				return err
+			}
+			if len(chainSpec.ChainConfig) == 0 {
+				if chainSpec.ChainConfigRequired {
+					return fmt.Errorf("chain config required for vm %q but not given", chainSpec.VmName)
+				}
+			} else if !json.Valid(chainSpec.ChainConfig) {
+				return fmt.Errorf("chain config for vm %q is not valid JSON", chainSpec.VmName)
+			}
+			if len(chainSpec.UpgradeConfig) > 0 && !json.Valid(chainSpec.UpgradeConfig) {
+				return fmt.Errorf("upgrade config for vm %q is not valid JSON", chainSpec.VmName)
+			}
+			if _, err := parseFxIDs(chainSpec.FxIDs); err != nil {
+				return fmt.Errorf("invalid fx IDs for vm %q: %w", chainSpec.VmName, err)
+			}
+			return nil
+		})
+	}
+	return errGr.Wait()
+}
+
+// validates the genesis of [chainSpec], using its GenesisValidator if given,
+// or checking for non empty valid JSON otherwise
+func validateBlockchainGenesis(chainSpec network.BlockchainSpec) error {
+	if len(chainSpec.Genesis) == 0 {
+		return fmt.Errorf("empty genesis for vm %q", chainSpec.VmName)
+	}
+	if chainSpec.GenesisValidator != nil {
+		if err := chainSpec.GenesisValidator(chainSpec.Genesis); err != nil {
+			return fmt.Errorf("invalid genesis for vm %q: %w", chainSpec.VmName, err)
+		}
+	} else if !json.Valid(chainSpec.Genesis) {
+		return fmt.Errorf("genesis for vm %q is not valid JSON", chainSpec.VmName)
+	}
+	return nil
+}
+
+// Returns a copy of [chainSpecs] where the genesis of the specs with a genesis
+// template is rendered with the values of the network, and validated.
+// Assumes the subnet IDs of the specs are set.
+func (ln *localNetwork) renderGenesisTemplates(chainSpecs []network.BlockchainSpec) ([]network.BlockchainSpec, error) {
+	renderedSpecs := make([]network.BlockchainSpec, len(chainSpecs))
+	copy(renderedSpecs, chainSpecs)
+	var fundedAddresses []string
+	for i, chainSpec := range renderedSpecs {
+		if chainSpec.GenesisTemplate == "" {
+			continue
+		}
+		if fundedAddresses == nil {
+			var err error
+			fundedAddresses, err = network.FundedAddresses(ln.genesis)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get funded addresses for genesis template: %w", err)
+			}
+		}
+		tmpl, err := template.New(chainSpec.VmName).Parse(chainSpec.GenesisTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid genesis template for vm %q: %w", chainSpec.VmName, err)
+		}
+		genesis := &bytes.Buffer{}
+		if err := tmpl.Execute(genesis, network.GenesisTemplateValues{
+			NetworkID:       ln.networkID,
+			FundedAddresses: fundedAddresses,
+			SubnetID:        *chainSpec.SubnetId,
+		}); err != nil {
+			return nil, fmt.Errorf("couldn't render genesis template for vm %q: %w", chainSpec.VmName, err)
+		}
+		renderedSpecs[i].Genesis = genesis.Bytes()
+		renderedSpecs[i].GenesisTemplate = ""
+		if err := validateBlockchainGenesis(renderedSpecs[i]); err != nil {
+			return nil, fmt.Errorf("rendered genesis template: %w", err)
+		}
+	}
+	return renderedSpecs, nil
+}
+
+func parseFxIDs(fxIDStrs []string) ([]ids.ID, error) {
+	fxIDs := make([]ids.ID, 0, len(fxIDStrs))
+	for _, fxIDStr := range fxIDStrs {
+		fxID, err := ids.FromString(fxIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse fx ID %q: %w", fxIDStr, err)
+		}
+		fxIDs = append(fxIDs, fxID)
+	}
+	return fxIDs, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetBlockchainID(ctx context.Context, name string) (ids.ID, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return ids.Empty, network.ErrStopped
+	}
+
+	blockchains, err := ln.getBlockchains(ctx)
+	if err != nil {
+		return ids.Empty, err
+	}
+	// blockchain names are not unique
+	matches := []ids.ID{}
+	for _, blockchain := range blockchains {
+		if blockchain.Name == name {
+			matches = append(matches, blockchain.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return ids.Empty, fmt.Errorf("blockchain %q not found", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return ids.Empty, fmt.Errorf("%d blockchains named %q: %v", len(matches), name, matches)
+	}
+}
+
+// See network.Network
+func (ln *localNetwork) GetBlockchains(ctx context.Context) ([]network.BlockchainInfo, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	blockchains, err := ln.getBlockchains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blockchainInfos := make([]network.BlockchainInfo, len(blockchains))
+	for i, blockchain := range blockchains {
+		blockchainInfos[i] = network.BlockchainInfo{
+			Name:     blockchain.Name,
+			ID:       blockchain.ID,
+			SubnetID: blockchain.SubnetID,
+			VMID:     blockchain.VMID,
+		}
+	}
+	return blockchainInfos, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetSubnetInfo(ctx context.Context, subnetID ids.ID) (network.SubnetInfo, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return network.SubnetInfo{}, network.ErrStopped
+	}
+
+	node := ln.getSomeNode()
+	if node == nil {
+		return network.SubnetInfo{}, errors.New("no nodes available to query the P-Chain")
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	subnets, err := node.GetAPIClient().PChainAPI().GetSubnets(cctx, []ids.ID{subnetID})
+	cancel()
+	if err != nil {
+		return network.SubnetInfo{}, fmt.Errorf("failure getting subnet %s: %w", subnetID, err)
+	}
+	if len(subnets) == 0 || subnets[0].ID != subnetID {
+		return network.SubnetInfo{}, fmt.Errorf("%w: %s", network.ErrSubnetNotFound, subnetID)
+	}
+	subnetInfo := network.SubnetInfo{
+		ID:          subnetID,
+		ControlKeys: make([]string, len(subnets[0].ControlKeys)),
+		Threshold:   subnets[0].Threshold,
+		Blockchains: []network.BlockchainInfo{},
+	}
+	for i, controlKey := range subnets[0].ControlKeys {
+		subnetInfo.ControlKeys[i], err = address.Format("P", constants.GetHRP(ln.networkID), controlKey.Bytes())
+		if err != nil {
+			return network.SubnetInfo{}, fmt.Errorf("couldn't format control key %s: %w", controlKey, err)
+		}
+	}
+
+	blockchains, err := ln.getBlockchains(ctx)
+	if err != nil {
+		return network.SubnetInfo{}, err
+	}
+	for _, blockchain := range blockchains {
+		if blockchain.SubnetID != subnetID {
+			continue
+		}
+		subnetInfo.Blockchains = append(subnetInfo.Blockchains, network.BlockchainInfo{
+			Name:     blockchain.Name,
+			ID:       blockchain.ID,
+			SubnetID: blockchain.SubnetID,
+			VMID:     blockchain.VMID,
+		})
+	}
+	sort.SliceStable(subnetInfo.Blockchains, func(i, j int) bool {
+		return subnetInfo.Blockchains[i].Name < subnetInfo.Blockchains[j].Name
+	})
+	return subnetInfo, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetPrimaryValidators(ctx context.Context) ([]network.ValidatorInfo, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	node := ln.getSomeNode()
+	if node == nil {
+		return nil, errors.New("no nodes available to query the P-Chain")
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	vs, err := node.GetAPIClient().PChainAPI().GetCurrentValidators(cctx, constants.PrimaryNetworkID, nil)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failure getting primary network validators: %w", err)
+	}
+	nodeNames := map[ids.NodeID]string{}
+	for nodeName, node := range ln.nodes {
+		nodeNames[node.GetNodeID()] = nodeName
+	}
+	validators := make([]network.ValidatorInfo, len(vs))
+	for i, v := range vs {
+		validators[i] = network.ValidatorInfo{
+			NodeID:    v.NodeID,
+			NodeName:  nodeNames[v.NodeID],
+			StartTime: time.Unix(int64(v.StartTime), 0),
+			EndTime:   time.Unix(int64(v.EndTime), 0),
+		}
+		if v.StakeAmount != nil {
+			validators[i].StakeAmount = *v.StakeAmount
+		}
+		if v.Connected != nil {
+			validators[i].Connected = *v.Connected
+		}
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return validators[i].NodeID.String() < validators[j].NodeID.String()
+	})
+	return validators, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetSubnetValidatorDetails(ctx context.Context, subnetID ids.ID) ([]network.SubnetValidatorDetail, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	node := ln.getSomeNode()
+	if node == nil {
+		return nil, errors.New("no nodes available to query the P-Chain")
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	vs, err := node.GetAPIClient().PChainAPI().GetCurrentValidators(cctx, subnetID, nil)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failure getting subnet %s validators: %w", subnetID, err)
+	}
+	nodeNames := map[ids.NodeID]string{}
+	for nodeName, node := range ln.nodes {
+		nodeNames[node.GetNodeID()] = nodeName
+	}
+	validators := make([]network.SubnetValidatorDetail, len(vs))
+	for i, v := range vs {
+		validators[i] = network.SubnetValidatorDetail{
+			NodeID:    v.NodeID,
+			NodeName:  nodeNames[v.NodeID],
+			StartTime: time.Unix(int64(v.StartTime), 0),
+			EndTime:   time.Unix(int64(v.EndTime), 0),
+		}
+		if v.Weight != nil {
+			validators[i].Weight = *v.Weight
+		}
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return validators[i].NodeID.String() < validators[j].NodeID.String()
+	})
+	return validators, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetPendingSubnetValidators(ctx context.Context, subnetID ids.ID) ([]ids.NodeID, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	node := ln.getSomeNode()
+	if node == nil {
+		return nil, errors.New("no nodes available to query the P-Chain")
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	defer cancel()
+	return getPendingValidators(cctx, node.GetAPIClient().PChainAPI(), subnetID)
+}
+
+// See network.Network
+func (ln *localNetwork) EstimateSetupCost(ctx context.Context, chainSpec network.BlockchainSpec, validatorCount uint32) (uint64, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return 0, network.ErrStopped
+	}
+
+	node := ln.getSomeNode()
+	if node == nil {
+		return 0, errors.New("no nodes available to get the tx fees")
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	defer cancel()
+	fees, err := node.GetAPIClient().InfoAPI().GetTxFee(cctx)
+	if err != nil {
+		return 0, fmt.Errorf("failure getting tx fees from node %q: %w", node.GetName(), err)
+	}
+	cost := uint64(fees.CreateBlockchainTxFee) + uint64(validatorCount)*uint64(fees.TxFee)
+	if chainSpec.SubnetId == nil {
+		cost += uint64(fees.CreateSubnetTxFee)
+	}
+	return cost, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetUptime(ctx context.Context, nodeName string) (float64, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return 0, network.ErrStopped
+	}
+
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	defer cancel()
+	uptime, err := node.client.InfoAPI().Uptime(cctx)
+	if err != nil {
+		return 0, fmt.Errorf("failure getting uptime of node %q: %w", nodeName, err)
+	}
+	return float64(uptime.WeightedAveragePercentage), nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetSubnetValidatorUptime(ctx context.Context, subnetID ids.ID, nodeID ids.NodeID) (float64, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return 0, network.ErrStopped
+	}
+
+	node := ln.getSomeNode()
+	if node == nil {
+		return 0, errors.New("no nodes available to query the P-Chain")
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	defer cancel()
+	platformCli := node.GetAPIClient().PChainAPI()
+	if subnetID != constants.PrimaryNetworkID {
+		if _, err := getCurrentValidator(cctx, platformCli, subnetID, nodeID); err != nil {
+			return 0, err
+		}
+	}
+	validator, err := getCurrentValidator(cctx, platformCli, constants.PrimaryNetworkID, nodeID)
+	if err != nil {
+		return 0, err
+	}
+	if validator.Uptime == nil {
+		return 0, fmt.Errorf("no uptime reported for validator %s", nodeID)
+	}
+	// reported as a fraction
+	return 100 * float64(*validator.Uptime), nil
+}
+
+// Assumes [ln.lock] is held.
+// Returns the IDs of the primary network and of the subnets for which [nodeID]
+// is a current or pending validator, as reported by the P-Chain of an arbitrary node.
+func (ln *localNetwork) getValidatedSubnets(ctx context.Context, nodeID ids.NodeID) ([]ids.ID, error) {
+	node := ln.getSomeNode()
+	if node == nil {
+		return nil, errors.New("no nodes available to query the P-Chain")
+	}
+	subnetIDs, err := ln.getCurrentSubnets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failure getting subnets: %w", err)
+	}
+	subnetIDs = append([]ids.ID{constants.PrimaryNetworkID}, subnetIDs...)
+	cctx, cancel := createNodeCtx(ctx, node)
+	defer cancel()
+	platformCli := node.GetAPIClient().PChainAPI()
+	validatedSubnetIDs := []ids.ID{}
+	for _, subnetID := range subnetIDs {
+		_, err := getCurrentValidator(cctx, platformCli, subnetID, nodeID)
+		switch {
+		case err == nil:
+			validatedSubnetIDs = append(validatedSubnetIDs, subnetID)
+			continue
+		case !errors.Is(err, network.ErrNotValidator):
+			return nil, err
+		}
+		pendingIDs, err := getPendingValidators(cctx, platformCli, subnetID)
+		if err != nil {
+			return nil, err
+		}
+		for _, pendingID := range pendingIDs {
+			if pendingID == nodeID {
+				validatedSubnetIDs = append(validatedSubnetIDs, subnetID)
+				break
+			}
+		}
+	}
+	return validatedSubnetIDs, nil
+}
+
+// Returns the current validator [nodeID] of [subnetID].
+// Returns an error wrapping network.ErrNotValidator if [nodeID] is not one.
+func getCurrentValidator(
+	ctx context.Context,
+	platformCli platformvm.Client,
+	subnetID ids.ID,
+	nodeID ids.NodeID,
+) (platformvm.ClientPrimaryValidator, error) {
+	cctx, cancel := createDefaultCtx(ctx)
+	vs, err := platformCli.GetCurrentValidators(cctx, subnetID, []ids.NodeID{nodeID})
+	cancel()
+	if err != nil {
+		return platformvm.ClientPrimaryValidator{}, fmt.Errorf("failure getting current validators of subnet %s: %w", subnetID, err)
+	}
+	for _, v := range vs {
+		if v.NodeID == nodeID {
+			return v, nil
+		}
+	}
+	return platformvm.ClientPrimaryValidator{}, fmt.Errorf("%w: %s of subnet %s", network.ErrNotValidator, nodeID, subnetID)
+}
+
+// Returns the IDs of the pending validators of [subnetID], that is, the ones
+// added to it whose start time has not been reached yet, sorted.
+func getPendingValidators(ctx context.Context, platformCli platformvm.Client, subnetID ids.ID) ([]ids.NodeID, error) {
+	cctx, cancel := createDefaultCtx(ctx)
+	vs, _, err := platformCli.GetPendingValidators(cctx, subnetID, nil)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failure getting pending validators of subnet %s: %w", subnetID, err)
+	}
+	nodeIDs := make([]ids.NodeID, 0, len(vs))
+	for _, v := range vs {
+		// the pending validators are returned as decoded JSON objects
+		vMap, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected pending validator type %T", v)
+		}
+		nodeIDStr, ok := vMap["nodeID"].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected pending validator node ID type %T", vMap["nodeID"])
+		}
+		nodeID, err := ids.NodeIDFromString(nodeIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse pending validator node ID %q: %w", nodeIDStr, err)
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	ids.SortNodeIDs(nodeIDs)
+	return nodeIDs, nil
+}
+
+// Returns the start and end times of the pending validation of [subnetID] by [nodeID], as
+// given by [platformCli], and false if [nodeID] is not a pending validator of [subnetID].
+func getPendingValidatorTimes(
+	ctx context.Context,
+	platformCli platformvm.Client,
+	subnetID ids.ID,
+	nodeID ids.NodeID,
+) (time.Time, time.Time, bool, error) {
+	cctx, cancel := createDefaultCtx(ctx)
+	vs, _, err := platformCli.GetPendingValidators(cctx, subnetID, []ids.NodeID{nodeID})
+	cancel()
+	if err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("failure getting pending validators of subnet %s: %w", subnetID, err)
+	}
+	for _, v := range vs {
+		// the pending validators are returned as decoded JSON objects
+		vMap, ok := v.(map[string]interface{})
+		if !ok {
+			return time.Time{}, time.Time{}, false, fmt.Errorf("unexpected pending validator type %T", v)
+		}
+		if vMap["nodeID"] != nodeID.String() {
+			continue
+		}
+		times := make([]time.Time, 2)
+		for i, key := range []string{"startTime", "endTime"} {
+			// JSON encoded as a string
+			timeStr, ok := vMap[key].(string)
+			if !ok {
+				return time.Time{}, time.Time{}, false, fmt.Errorf("unexpected pending validator %s type %T", key, vMap[key])
+			}
+			unixTime, err := strconv.ParseUint(timeStr, 10, 64)
+			if err != nil {
+				return time.Time{}, time.Time{}, false, fmt.Errorf("couldn't parse pending validator %s %q: %w", key, timeStr, err)
+			}
+			times[i] = time.Unix(int64(unixTime), 0)
+		}
+		return times[0], times[1], true, nil
+	}
+	return time.Time{}, time.Time{}, false, nil
+}
+
+// Assumes [ln.lock] is held.
+// Returns the blockchains reported by the P-Chain of an arbitrary node.
+func (ln *localNetwork) getBlockchains(ctx context.Context) ([]platformvm.APIBlockchain, error) {
+	node := ln.getSomeNode()
+	if node == nil {
+		return nil, errors.New("no nodes available to query the P-Chain")
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	blockchains, err := node.GetAPIClient().PChainAPI().GetBlockchains(cctx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failure getting blockchains: %w", err)
+	}
+	return blockchains, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetChainHeight(ctx context.Context, blockchainID ids.ID) (map[string]uint64, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	if err := ln.checkChainHeightSupported(ctx, blockchainID); err != nil {
+		return nil, err
+	}
+	return ln.getChainHeight(ctx, blockchainID)
+}
+
+// See network.Network
+func (ln *localNetwork) GetPChainHeight(ctx context.Context) (map[string]uint64, error) {
+	return ln.GetChainHeight(ctx, constants.PlatformChainID)
+}
+
+// Assumes [ln.lock] is held.
+// Returns the height of [blockchainID] for each node not stopped with StopNode,
+// querying them concurrently.
+func (ln *localNetwork) getChainHeight(ctx context.Context, blockchainID ids.ID) (map[string]uint64, error) {
+	var heightsLock sync.Mutex
+	heights := make(map[string]uint64, len(ln.nodes))
+	err := forEachNode(ctx, ln.runningNodes(), network.DefaultMaxConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		height, err := getNodeChainHeight(ctx, node, blockchainID)
+		if err != nil {
+			return fmt.Errorf("failure getting height of blockchain %s on node %q: %w", blockchainID, nodeName, err)
+		}
+		heightsLock.Lock()
+		heights[nodeName] = height
+		heightsLock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return heights, nil
+}
+
+// See network.Network
+func (ln *localNetwork) AwaitChainHeight(ctx context.Context, blockchainID ids.ID, target uint64) error {
+	ln.lock.RLock()
+	if ln.stopCalled() {
+		ln.lock.RUnlock()
+		return network.ErrStopped
+	}
+	err := ln.checkChainHeightSupported(ctx, blockchainID)
+	ln.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// the lock is only held to get the nodes to poll, so that the network can be
+	// used, e.g. nodes added or stopped, while they are queried or waited for
+	for {
+		ln.lock.RLock()
+		if ln.stopCalled() {
+			ln.lock.RUnlock()
+			return errAborted
+		}
+		nodes := ln.runningNodes()
+		ln.lock.RUnlock()
+		lagging := []string{}
+		for nodeName, node := range nodes {
+			height, err := getNodeChainHeight(ctx, node, blockchainID)
+			if err != nil {
+				ln.log.Debug("failure getting blockchain height", zap.String("node-name", nodeName), zap.Error(err))
+			}
+			if err != nil || height < target {
+				lagging = append(lagging, nodeName)
+			}
+		}
+		if len(lagging) == 0 {
+			return nil
+		}
+		sort.Strings(lagging)
+		select {
+		case <-ln.onStopCh:
+			return errAborted
+		case <-ctx.Done():
+			return fmt.Errorf("nodes %v did not reach height %d of blockchain %s: %w", lagging, target, blockchainID, ctx.Err())
+		case <-time.After(waitForChainHeightPullFrequency):
+		}
+	}
+}
+
+// See network.Network
+func (ln *localNetwork) AwaitVMBlock(ctx context.Context, blockchainID ids.ID, target uint64, rpcPath string, query network.VMBlockQuery) error {
+	ln.lock.RLock()
+	stopped := ln.stopCalled()
+	ln.lock.RUnlock()
+	if stopped {
+		return network.ErrStopped
+	}
+	if query.Method == "" || query.ParseHeight == nil {
+		return errors.New("VM block query must give a method and a height parser")
+	}
+
+	// the lock is only held to get the nodes to poll, so that the network can be
+	// used, e.g. nodes added or stopped, while they are queried or waited for
+	for {
+		ln.lock.RLock()
+		if ln.stopCalled() {
+			ln.lock.RUnlock()
+			return errAborted
+		}
+		nodes := ln.runningNodes()
+		ln.lock.RUnlock()
+		lagging := []string{}
+		for nodeName, node := range nodes {
+			height, err := getNodeVMHeight(ctx, node, blockchainID, rpcPath, query)
+			if err != nil {
+				ln.log.Debug("failure getting VM block height", zap.String("node-name", nodeName), zap.Error(err))
+			}
+			if err != nil || height < target {
+				lagging = append(lagging, nodeName)
+			}
+		}
+		if len(lagging) == 0 {
+			return nil
+		}
+		sort.Strings(lagging)
+		select {
+		case <-ln.onStopCh:
+			return errAborted
+		case <-ctx.Done():
+			return fmt.Errorf("nodes %v did not accept block %d of blockchain %s: %w", lagging, target, blockchainID, ctx.Err())
+		case <-time.After(waitForChainHeightPullFrequency):
+		}
+	}
+}
+
+// Returns the height of the last accepted block of the VM of [blockchainID]
+// on [node], by sending [query] to its RPC at [rpcPath].
+func getNodeVMHeight(ctx context.Context, node *localNode, blockchainID ids.ID, rpcPath string, query network.VMBlockQuery) (uint64, error) {
+	uri := &url.URL{
+		Scheme: "http",
+		Host:   utils.JoinHostPort(node.GetURL(), node.GetAPIPort()),
+		Path:   fmt.Sprintf("/ext/bc/%s%s", blockchainID, rpcPath),
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	defer cancel()
+	var result json.RawMessage
+	if err := api.SendJSONRequest(cctx, node.httpClient, uri, query.Method, query.Params, &result); err != nil {
+		return 0, err
+	}
+	height, err := query.ParseHeight(result)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse %s result: %w", query.Method, err)
+	}
+	return height, nil
+}
+
+// Assumes [ln.lock] is held.
+// Returns an error wrapping errNotEVMChain if [blockchainID] is neither the P-Chain nor
+// a blockchain of the C-Chain or subnet-evm VMs, whose heights getNodeChainHeight can't get.
+func (ln *localNetwork) checkChainHeightSupported(ctx context.Context, blockchainID ids.ID) error {
+	if blockchainID == constants.PlatformChainID {
+		return nil
+	}
+	subnetEVMID, err := utils.VMID(subnetEVMName)
+	if err != nil {
+		return err
+	}
+	blockchains, err := ln.getBlockchains(ctx)
+	if err != nil {
+		return err
+	}
+	for _, blockchain := range blockchains {
+		if blockchain.ID != blockchainID {
+			continue
+		}
+		if blockchain.VMID != constants.EVMID && blockchain.VMID != subnetEVMID {
+			return fmt.Errorf("%w: blockchain %s runs VM %s, use AwaitVMBlock to query its height", errNotEVMChain, blockchainID, blockchain.VMID)
+		}
+		return nil
+	}
+	return fmt.Errorf("blockchain %s not found", blockchainID)
+}
+
+// Returns the height of [blockchainID] on [node].
+// The P-Chain height is obtained from the platform API. Any other
+// blockchain must expose an EVM RPC, as checked by checkChainHeightSupported.
+func getNodeChainHeight(ctx context.Context, node *localNode, blockchainID ids.ID) (uint64, error) {
+	if blockchainID == constants.PlatformChainID {
+		cctx, cancel := createNodeCtx(ctx, node)
+		defer cancel()
+		return node.GetAPIClient().PChainAPI().GetHeight(cctx)
+	}
+	return getNodeVMHeight(ctx, node, blockchainID, evmRPCPath, evmBlockQuery)
+}
+
+// Runs [f] with a context limited to [timeout], so a setup phase
+// can't consume the time budget of the following ones.
+// If [timeout] is 0, [f] is only limited by [ctx].
+// The returned error names [phase], and tells if its budget was exceeded.
+func runPhase(ctx context.Context, phase string, timeout time.Duration, f func(context.Context) error) error {
+	cctx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		cctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	if err := f(cctx); err != nil {
+		if ctx.Err() == nil && errors.Is(cctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s phase timed out after %s: %w", phase, timeout, err)
+		}
+		return fmt.Errorf("%s phase failed: %w", phase, err)
+	}
+	return nil
+}
+
+// Calls [f] with a default timeout context until it succeeds or fails with a non
+// transient error, retrying at most [op.IssueRetries] times with an exponential
+// backoff starting at [op.IssueRetryBackoff].
+// [f] must only issue a tx, without waiting for it to be decided, which is done
+// separately: a transient error while waiting would issue an accepted tx again.
+func retryTransient(ctx context.Context, log logging.Logger, op *network.SetupOp, f func(context.Context) error) error {
+	backoff := op.IssueRetryBackoff
+	for attempt := uint32(0); ; attempt++ {
+		cctx, cancel := createDefaultCtx(ctx)
+		err := f(cctx)
+		cancel()
+		if err == nil || attempt >= op.IssueRetries || !isTransientError(err) {
+			return err
+		}
+		log.Info("transient error issuing tx, retrying", zap.Uint32("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Returns true if [err] is a network error that may not happen again
+// on retry, as a connection reset during node startup.
+// Timeouts of the given contexts and tx rejections are not transient.
+func isTransientError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 func createDefaultCtx(ctx context.Context) (context.Context, context.CancelFunc) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 	return context.WithTimeout(ctx, defaultTimeout)
 }
+
+// Returns a context for a single request to the API of [node],
+// limited by the node API timeout.
+func createNodeCtx(ctx context.Context, node node.Node) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, node.GetAPITimeout())
+}
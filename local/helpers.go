@@ -1,14 +1,24 @@
 package local
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
 
+	"github.com/ava-labs/avalanche-network-runner/network"
 	"github.com/ava-labs/avalanche-network-runner/network/node"
 	"github.com/ava-labs/avalanchego/config"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 // writeFiles writes the files a node needs on startup.
@@ -72,9 +82,36 @@ func writeFiles(genesis []byte, nodeRootDir string, nodeConfig *node.Config) ([]
 			}
 		}
 	}
+	if len(nodeConfig.SubnetConfigFiles) > 0 {
+		subnetConfigDir := filepath.Join(nodeRootDir, subnetConfigSubDir)
+		flags = append(flags, fmt.Sprintf("--%s=%s", config.SubnetConfigDirKey, subnetConfigDir))
+		for subnetID, subnetConfigFile := range nodeConfig.SubnetConfigFiles {
+			subnetConfigPath := filepath.Join(subnetConfigDir, subnetID+subnetConfigExt)
+			if err := createFileAndWrite(subnetConfigPath, []byte(subnetConfigFile)); err != nil {
+				return nil, fmt.Errorf("couldn't write file at %q: %w", subnetConfigPath, err)
+			}
+		}
+	}
 	return flags, nil
 }
 
+// getExtraArg returns the value given to flag [name] by the last of [extraArgs] setting it,
+// which takes precedence when given to the node, or "" if it is given without value.
+// Returns false if no extra arg sets the flag.
+func getExtraArg(extraArgs []string, name string) (string, bool) {
+	for i := len(extraArgs) - 1; i >= 0; i-- {
+		arg := extraArgs[i]
+		if node.ExtraArgName(arg) != name {
+			continue
+		}
+		if j := strings.Index(arg, "="); j >= 0 {
+			return arg[j+1:], true
+		}
+		return "", true
+	}
+	return "", false
+}
+
 // getConfigEntry returns an entry in the config file if it is found, otherwise returns the default value
 func getConfigEntry(
 	nodeConfigFlags map[string]interface{},
@@ -98,13 +135,20 @@ func getConfigEntry(
 	return defaultVal, nil
 }
 
-// getPort looks up the port config in the config file, if there is none, it tries to get a random free port from the OS
+// getPort returns [requestedPort] if not 0, or looks up the port config in the flags and the
+// config file. If there is none, it allocates one with [allocatePort].
+// Returns ErrPortInUse if a port explicitly given is already in use.
 func getPort(
 	flags map[string]interface{},
 	configFile map[string]interface{},
 	portKey string,
+	requestedPort uint16,
+	usedPorts map[uint16]struct{},
+	allocatePort func() (uint16, error),
 ) (port uint16, err error) {
-	if portIntf, ok := flags[portKey]; ok {
+	if requestedPort != 0 {
+		port = requestedPort
+	} else if portIntf, ok := flags[portKey]; ok {
 		if portFromFlags, ok := portIntf.(int); ok {
 			port = uint16(portFromFlags)
 		} else if portFromFlags, ok := portIntf.(float64); ok {
@@ -121,10 +165,14 @@ func getPort(
 	} else {
 		// Use a random free port.
 		// Note: it is possible but unlikely for getFreePort to return the same port multiple times.
-		port, err = getFreePort()
+		port, err = allocatePort()
 		if err != nil {
 			return 0, fmt.Errorf("couldn't get free API port: %w", err)
 		}
+		return port, nil
+	}
+	if err := checkPortFree(port, usedPorts); err != nil {
+		return 0, err
 	}
 	return port, nil
 }
@@ -148,6 +196,25 @@ func makeNodeDir(log logging.Logger, rootDir, nodeName string) (string, error) {
 	return nodeRootDir, nil
 }
 
+// Returns the JSON config a node is launched with: the entries of its
+// [configFile], overridden by the [flags] passed to its process, as avalanchego does.
+// Flags are given as --name=value, or as --name for a true boolean flag.
+func launchConfig(configFile map[string]interface{}, flags []string) ([]byte, error) {
+	config := map[string]interface{}{}
+	for k, v := range configFile {
+		config[k] = v
+	}
+	for _, flag := range flags {
+		name, value, ok := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		if !ok {
+			config[name] = true
+			continue
+		}
+		config[name] = value
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
 // createFileAndWrite creates a file with the given path and
 // writes the given contents
 func createFileAndWrite(path string, contents []byte) error {
@@ -167,6 +234,47 @@ func createFileAndWrite(path string, contents []byte) error {
 	return nil
 }
 
+// Writes [contents] to [path] through a temporary file in the same dir, then
+// renamed to [path], so that readers never see it partially written.
+func writeFileAtomic(path string, contents []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	if err := writeAndClose(file, contents); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Writes [contents] to [file], syncs it and closes it
+func writeAndClose(file *os.File, contents []byte) error {
+	if _, err := file.Write(contents); err != nil {
+		_ = file.Close()
+		return err
+	}
+	// temporary files are created only readable by their owner
+	if err := file.Chmod(0o644); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 // addNetworkFlags adds the flags in [networkFlags] to [nodeConfig.Flags].
 // [nodeFlags] must not be nil.
 func addNetworkFlags(log logging.Logger, networkFlags map[string]interface{}, nodeFlags map[string]interface{}) {
@@ -185,3 +293,221 @@ func addNetworkFlags(log logging.Logger, networkFlags map[string]interface{}, no
 		}
 	}
 }
+
+// scrapeMetrics returns the raw Prometheus exposition text served at [metricsURL],
+// requested with [httpClient]
+func scrapeMetrics(ctx context.Context, httpClient *http.Client, metricsURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %q", resp.StatusCode, metricsURL)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Returns the clock of the node serving the info API at [infoURL], as given by the
+// Date header of its response, and the local times at which the request was
+// sent and the response was received.
+// The request is sent with [httpClient].
+// The Date header has a resolution of one second.
+func getNodeTime(ctx context.Context, httpClient *http.Client, infoURL string) (nodeTime time.Time, sent time.Time, received time.Time, err error) {
+	body := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"info.getNodeVersion","params":{}}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, infoURL, body)
+	if err != nil {
+		return time.Time{}, time.Time{}, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	sent = time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return time.Time{}, time.Time{}, time.Time{}, err
+	}
+	received = time.Now()
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("no Date header in response from %q", infoURL)
+	}
+	nodeTime, err = http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("invalid Date header %q in response from %q: %w", date, infoURL, err)
+	}
+	return nodeTime, sent, received, nil
+}
+
+// Returns how far [nodeTime] is from the local clock, given that it was read
+// between [sent] and [received], with a resolution of one second.
+// Returns 0 if it may be within that interval.
+func clockSkew(nodeTime time.Time, sent time.Time, received time.Time) time.Duration {
+	switch {
+	case nodeTime.Before(sent.Truncate(time.Second)):
+		return sent.Truncate(time.Second).Sub(nodeTime)
+	case nodeTime.After(received):
+		return nodeTime.Sub(received)
+	default:
+		return 0
+	}
+}
+
+// pollBackoff gives the wait between the checks of a polling loop: its base
+// period, doubled after each failed check up to [maxPollBackoffFactor] times
+// the base period, so that nodes failing under load are queried less often,
+// and reset after a successful check.
+type pollBackoff struct {
+	base    time.Duration
+	current time.Duration
+}
+
+func newPollBackoff(base time.Duration) *pollBackoff {
+	return &pollBackoff{base: base, current: base}
+}
+
+// Returns the wait before the next check, given whether the last one [failed]
+func (b *pollBackoff) next(failed bool) time.Duration {
+	switch {
+	case !failed:
+		b.current = b.base
+	case b.current < b.base*maxPollBackoffFactor:
+		b.current *= 2
+		if b.current > b.base*maxPollBackoffFactor {
+			b.current = b.base * maxPollBackoffFactor
+		}
+	}
+	return b.current
+}
+
+// Returns [log], adding the request ID of [op], if any, to each of its log lines
+func setupLogger(log logging.Logger, op *network.SetupOp) logging.Logger {
+	if op.RequestID == "" {
+		return log
+	}
+	return &fieldsLogger{Logger: log, fields: []zap.Field{zap.String("request-ID", op.RequestID)}}
+}
+
+// fieldsLogger adds [fields] to each line logged by the wrapped logger
+type fieldsLogger struct {
+	logging.Logger
+	fields []zap.Field
+}
+
+func (l *fieldsLogger) withFields(fields []zap.Field) []zap.Field {
+	return append(append(make([]zap.Field, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+}
+
+func (l *fieldsLogger) Fatal(msg string, fields ...zap.Field) {
+	l.Logger.Fatal(msg, l.withFields(fields)...)
+}
+
+func (l *fieldsLogger) Error(msg string, fields ...zap.Field) {
+	l.Logger.Error(msg, l.withFields(fields)...)
+}
+
+func (l *fieldsLogger) Warn(msg string, fields ...zap.Field) {
+	l.Logger.Warn(msg, l.withFields(fields)...)
+}
+
+func (l *fieldsLogger) Info(msg string, fields ...zap.Field) {
+	l.Logger.Info(msg, l.withFields(fields)...)
+}
+
+func (l *fieldsLogger) Trace(msg string, fields ...zap.Field) {
+	l.Logger.Trace(msg, l.withFields(fields)...)
+}
+
+func (l *fieldsLogger) Debug(msg string, fields ...zap.Field) {
+	l.Logger.Debug(msg, l.withFields(fields)...)
+}
+
+func (l *fieldsLogger) Verbo(msg string, fields ...zap.Field) {
+	l.Logger.Verbo(msg, l.withFields(fields)...)
+}
+
+// Returns the versions of the runner and avalanchego modules compiled into
+// the running binary, or empty strings if they are not known.
+func getModuleVersions() (runnerVersion string, avalanchegoVersion string) {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+	modules := append([]*debug.Module{&buildInfo.Main}, buildInfo.Deps...)
+	for _, module := range modules {
+		version := module.Version
+		if module.Replace != nil {
+			version = module.Replace.Version
+		}
+		switch module.Path {
+		case runnerModulePath:
+			runnerVersion = version
+		case avalanchegoModulePath:
+			avalanchegoVersion = version
+		}
+	}
+	return runnerVersion, avalanchegoVersion
+}
+
+// Runs [f] on each node of [nodes], for at most [maxConcurrency] nodes at a time,
+// so the number of goroutines doesn't grow with the number of nodes.
+// Returns the first error found. In that case, the context given to [f] is cancelled
+// and the remaining nodes are skipped.
+func forEachNode(
+	ctx context.Context,
+	nodes map[string]*localNode,
+	maxConcurrency uint32,
+	f func(ctx context.Context, nodeName string, node *localNode) error,
+) error {
+	nodeNames := make([]string, 0, len(nodes))
+	for nodeName := range nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	return forEachName(ctx, nodeNames, maxConcurrency, func(ctx context.Context, nodeName string) error {
+		return f(ctx, nodeName, nodes[nodeName])
+	})
+}
+
+// Runs [f] on each of [names], for at most [maxConcurrency] names at a time,
+// as forEachNode does for nodes.
+func forEachName(
+	ctx context.Context,
+	names []string,
+	maxConcurrency uint32,
+	f func(ctx context.Context, name string) error,
+) error {
+	if maxConcurrency == 0 {
+		return errors.New("max concurrency must be greater than 0")
+	}
+	errGr, ctx := errgroup.WithContext(ctx)
+	namesCh := make(chan string)
+	errGr.Go(func() error {
+		defer close(namesCh)
+		for _, name := range names {
+			select {
+			case namesCh <- name:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	for i := uint32(0); i < maxConcurrency && int(i) < len(names); i++ {
+		errGr.Go(func() error {
+			for name := range namesCh {
+				if err := f(ctx, name); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return errGr.Wait()
+}
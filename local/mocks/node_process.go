@@ -17,6 +17,36 @@ type NodeProcess struct {
 	mock.Mock
 }
 
+// Exited provides a mock function with given fields:
+func (_m *NodeProcess) Exited() <-chan struct{} {
+	ret := _m.Called()
+
+	var r0 <-chan struct{}
+	if rf, ok := ret.Get(0).(func() <-chan struct{}); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan struct{})
+		}
+	}
+
+	return r0
+}
+
+// Pid provides a mock function with given fields:
+func (_m *NodeProcess) Pid() int {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
 // Status provides a mock function with given fields:
 func (_m *NodeProcess) Status() status.Status {
 	ret := _m.Called()
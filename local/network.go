@@ -11,7 +11,10 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ava-labs/avalanche-network-runner/api"
@@ -20,14 +23,17 @@ import (
 	"github.com/ava-labs/avalanche-network-runner/network/node/status"
 	"github.com/ava-labs/avalanche-network-runner/utils"
 	"github.com/ava-labs/avalanchego/config"
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/network/peer"
 	"github.com/ava-labs/avalanchego/staking"
 	"github.com/ava-labs/avalanchego/utils/beacon"
+	"github.com/ava-labs/avalanchego/utils/constants"
 	"github.com/ava-labs/avalanchego/utils/ips"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/avalanchego/vms/secp256k1fx"
+	"github.com/ava-labs/avalanchego/wallet/subnet/primary"
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -37,15 +43,32 @@ const (
 	stakingKeyFileName    = "staking.key"
 	stakingCertFileName   = "staking.crt"
 	genesisFileName       = "genesis.json"
+	launchConfigFileName  = "launch_config.json"
 	stopTimeout           = 30 * time.Second
 	healthCheckFreq       = 3 * time.Second
+	drainCheckFreq        = 5 * time.Second
 	DefaultNumNodes       = 5
 	snapshotPrefix        = "anr-snapshot-"
+	tmpSnapshotPrefix     = ".anr-tmp-snapshot-"
 	rootDirPrefix         = "network-runner-root-data"
 	defaultDbSubdir       = "db"
 	defaultLogsSubdir     = "logs"
+	defaultProfilesSubdir = "profiles"
 	// difference between unlock schedule locktime and startime in original genesis
 	genesisLocktimeStartimeDelta = 2836800
+	// file of the snapshot dir holding the snapshot metadata
+	snapshotMetadataFileName = "metadata.json"
+	// files of the profile dir that the admin API writes the profiles to
+	cpuProfileFileName  = "cpu.profile"
+	memProfileFileName  = "mem.profile"
+	lockProfileFileName = "lock.profile"
+	// suffix of the compressed node db archives of a snapshot
+	gzipArchiveSuffix = ".tar.gz"
+	// number of unexpected node exits buffered until they are received
+	nodeExitsBufferSize = 64
+	// modules whose versions are reported by GetBuildInfo
+	runnerModulePath      = "github.com/ava-labs/avalanche-network-runner"
+	avalanchegoModulePath = "github.com/ava-labs/avalanchego"
 )
 
 // interface compliance
@@ -59,10 +82,25 @@ var (
 		config.BootstrapIDsKey: {},
 	}
 	chainConfigSubDir = "chainConfigs"
+	// subnet configs are named by subnet ID with this extension
+	subnetConfigSubDir = "subnetConfigs"
+	subnetConfigExt    = ".json"
+	// aliases of the chains checked for the bootstrapped readiness mode
+	primaryNetworkChains = []string{"P", "X", "C"}
 
 	snapshotsRelPath = filepath.Join(".avalanche-network-runner", "snapshots")
 
-	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrSnapshotNotFound  = errors.New("snapshot not found")
+	ErrSnapshotInUse     = errors.New("snapshot is backing the running network")
+	ErrPortInUse         = errors.New("port already in use")
+	ErrNoBeacon          = errors.New("no node available as beacon")
+	ErrClockSkew         = errors.New("node clock skew exceeds maximum")
+	ErrAdminAPIDisabled  = errors.New("admin API disabled")
+	ErrNotEnoughSigners  = errors.New("not enough signing keys for subnet threshold")
+	ErrNodeStopped       = errors.New("node stopped")
+	ErrNetworkIDMismatch = errors.New("node network ID differs from the network one")
+	// returned when setting network conditions elsewhere than on Linux
+	ErrNetworkConditionsUnsupported = errors.New("network conditions need tc netem, only available on Linux")
 )
 
 // network keeps information uses for network management, and accessing all the nodes
@@ -76,11 +114,15 @@ type localNetwork struct {
 	genesis []byte
 	// Used to create a new API client
 	newAPIClientF api.NewAPIClientF
+	// Used to create the wallets issuing the setup txs
+	newWalletF func(ctx context.Context, client api.Client, kc *secp256k1fx.Keychain, pTXs ...ids.ID) (primary.Wallet, error)
 	// Used to create new node processes
 	nodeProcessCreator NodeProcessCreator
 	stopOnce           sync.Once
 	// Closed when Stop begins.
 	onStopCh chan struct{}
+	// receives the unexpected exits of node processes
+	nodeExits chan network.NodeExit
 	// For node name generation
 	nextNodeSuffix uint64
 	// Node Name --> Node
@@ -92,6 +134,8 @@ type localNetwork struct {
 	rootDir string
 	// directory where networks can be persistently saved
 	snapshotsDir string
+	// name of the snapshot the network was loaded from, if any
+	loadedSnapshotName string
 	// flags to apply to all nodes per default
 	flags map[string]interface{}
 	// binary path to use per default
@@ -100,6 +144,18 @@ type localNetwork struct {
 	chainConfigFiles map[string]string
 	// upgrade config files to use per default
 	upgradeConfigFiles map[string]string
+	// subnet config files to use per default
+	subnetConfigFiles map[string]string
+	// range of ports from which node ports not given explicitly are allocated
+	minPort uint16
+	maxPort uint16
+	// maximum number of nodes queried at a time by the health checks
+	healthCheckConcurrency uint32
+	// node pair ("<node name>/<node name>", sorted) --> tc setup of its network conditions.
+	// Nil until network conditions are first set.
+	netConditions map[string]netConditionsPair
+	// tc class ID of the last node pair given network conditions
+	nextNetConditionsClass uint16
 }
 
 var (
@@ -221,14 +277,21 @@ func init() {
 // NewNetwork returns a new network that uses the given log.
 // Files (e.g. logs, databases) default to being written at directory [rootDir].
 // If there isn't a directory at [dir] one will be created.
-// If len([dir]) == 0, files will be written underneath a new temporary directory.
+// If len([dir]) == 0, files will be written underneath [networkConfig.RootDataDir],
+// or a new temporary directory if that is also empty.
 // Snapshots are saved to snapshotsDir, defaults to defaultSnapshotsDir if not given
+// [opts] can set a function to be notified as each node becomes healthy,
+// and what to wait for before returning.
 func NewNetwork(
 	log logging.Logger,
 	networkConfig network.Config,
 	rootDir string,
 	snapshotsDir string,
+	opts ...network.LoadConfigOption,
 ) (network.Network, error) {
+	if rootDir == "" {
+		rootDir = networkConfig.RootDataDir
+	}
 	net, err := newNetwork(
 		log,
 		api.NewAPIClient,
@@ -244,7 +307,7 @@ func NewNetwork(
 	if err != nil {
 		return net, err
 	}
-	return net, net.loadConfig(context.Background(), networkConfig)
+	return net, net.loadConfig(context.Background(), networkConfig, opts...)
 }
 
 // See NewNetwork.
@@ -278,12 +341,18 @@ func newNetwork(
 		nextNodeSuffix:     1,
 		nodes:              map[string]*localNode{},
 		onStopCh:           make(chan struct{}),
+		nodeExits:          make(chan network.NodeExit, nodeExitsBufferSize),
 		log:                log,
 		bootstraps:         beacon.NewSet(),
 		newAPIClientF:      newAPIClientF,
+		newWalletF:         newWalletWithTxs,
 		nodeProcessCreator: nodeProcessCreator,
 		rootDir:            rootDir,
 		snapshotsDir:       snapshotsDir,
+		minPort:            defaultMinPort,
+		maxPort:            defaultMaxPort,
+
+		healthCheckConcurrency: network.DefaultMaxConcurrency,
 	}
 	return net, nil
 }
@@ -362,7 +431,7 @@ func NewDefaultConfigNNodes(binaryPath string, numNodes uint32) (network.Config,
 			nodeConfig.StakingKey = string(stakingKey)
 			nodeConfig.StakingCert = string(stakingCert)
 			// replace api port in refNodeConfig.ConfigFile
-			apiPort, err := getFreePort()
+			apiPort, err := getFreePort(defaultMinPort, defaultMaxPort, nil)
 			if err != nil {
 				return netConfig, fmt.Errorf("couldn't get free API port: %w", err)
 			}
@@ -378,7 +447,13 @@ func NewDefaultConfigNNodes(binaryPath string, numNodes uint32) (network.Config,
 	return netConfig, nil
 }
 
-func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Config) error {
+// Starts the nodes of [networkConfig].
+// Returns ErrAlreadyInitialized if a config or snapshot was already loaded.
+func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Config, opts ...network.LoadConfigOption) error {
+	if ln.initialized() {
+		return network.ErrAlreadyInitialized
+	}
+	op := network.NewLoadConfigOp(opts...)
 	if err := networkConfig.Validate(); err != nil {
 		return fmt.Errorf("config failed validation: %w", err)
 	}
@@ -386,6 +461,13 @@ func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Co
 
 	ln.genesis = []byte(networkConfig.Genesis)
 
+	if networkConfig.RootDataDir != "" && networkConfig.RootDataDir != ln.rootDir {
+		if err := os.MkdirAll(networkConfig.RootDataDir, os.ModePerm); err != nil {
+			return fmt.Errorf("couldn't create root data dir %q: %w", networkConfig.RootDataDir, err)
+		}
+		ln.rootDir = networkConfig.RootDataDir
+	}
+
 	var err error
 	ln.networkID, err = utils.NetworkIDFromGenesis([]byte(networkConfig.Genesis))
 	if err != nil {
@@ -397,6 +479,16 @@ func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Co
 	ln.binaryPath = networkConfig.BinaryPath
 	ln.chainConfigFiles = networkConfig.ChainConfigFiles
 	ln.upgradeConfigFiles = networkConfig.UpgradeConfigFiles
+	ln.subnetConfigFiles = networkConfig.SubnetConfigFiles
+	if networkConfig.MinPort != 0 {
+		ln.minPort = networkConfig.MinPort
+	}
+	if networkConfig.MaxPort != 0 {
+		ln.maxPort = networkConfig.MaxPort
+	}
+	if networkConfig.HealthCheckConcurrency != 0 {
+		ln.healthCheckConcurrency = networkConfig.HealthCheckConcurrency
+	}
 
 	// Sort node configs so beacons start first
 	var nodeConfigs []node.Config
@@ -421,9 +513,102 @@ func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Co
 		}
 	}
 
+	if op.OnNodeHealthy != nil {
+		for _, node := range ln.nodes {
+			go ln.notifyNodeHealthy(node, op.OnNodeHealthy)
+		}
+	}
+
+	if err := ln.awaitReadiness(ctx, op); err != nil {
+		if err := ln.stop(ctx); err != nil {
+			ln.log.Debug("error stopping network", zap.Error(err))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Waits for the nodes to reach the readiness mode of [op], within its timeout.
+func (ln *localNetwork) awaitReadiness(ctx context.Context, op *network.LoadConfigOp) error {
+	if op.ReadinessMode == network.ReadinessNone {
+		return nil
+	}
+	ln.log.Info("waiting for network readiness", zap.Stringer("mode", op.ReadinessMode), zap.Duration("timeout", op.ReadinessTimeout))
+	ctx, cancel := context.WithTimeout(ctx, op.ReadinessTimeout)
+	defer cancel()
+	var err error
+	switch op.ReadinessMode {
+	case network.ReadinessBootstrapped:
+		err = ln.bootstrapped(ctx)
+	case network.ReadinessHealthy:
+		err = ln.healthy(ctx)
+	default:
+		return fmt.Errorf("unknown readiness mode %s", op.ReadinessMode)
+	}
+	if err != nil {
+		return fmt.Errorf("network not %s: %w", op.ReadinessMode, err)
+	}
 	return nil
 }
 
+// Assumes [ln.lock] is held.
+// Waits until the primary network chains of all the nodes are bootstrapped.
+func (ln *localNetwork) bootstrapped(ctx context.Context) error {
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+	return forEachNode(ctx, ln.nodes, network.DefaultMaxConcurrency, func(ctx context.Context, _ string, node *localNode) error {
+		return ln.awaitNodeBootstrapped(ctx, node)
+	})
+}
+
+// Every [healthCheckFreq], query [node] for the bootstrap status of the primary
+// network chains. Returns nil once all of them are bootstrapped, or an error if
+// the node stops or [ctx] is done first.
+func (ln *localNetwork) awaitNodeBootstrapped(ctx context.Context, node *localNode) error {
+	nodeName := node.GetName()
+	for {
+		if node.Status() != status.Running {
+			return fmt.Errorf("node %q stopped unexpectedly", nodeName)
+		}
+		bootstrapped := true
+		for _, chain := range primaryNetworkChains {
+			cctx, cancel := createNodeCtx(ctx, node)
+			chainBootstrapped, err := node.client.InfoAPI().IsBootstrapped(cctx, chain)
+			cancel()
+			if err != nil || !chainBootstrapped {
+				bootstrapped = false
+				break
+			}
+		}
+		if bootstrapped {
+			ln.log.Debug("node bootstrapped", zap.String("name", nodeName))
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %q failed to bootstrap within timeout, or network stopped", nodeName)
+		case <-node.process.Exited():
+		case <-time.After(healthCheckFreq):
+		}
+	}
+}
+
+// Calls [onNodeHealthy] with the name of [node] once it becomes healthy.
+// Gives up if the node or the network are stopped first.
+func (ln *localNetwork) notifyNodeHealthy(node *localNode, onNodeHealthy func(nodeName string)) {
+	ctx, cancel := ln.newStopAwareContext(context.Background())
+	defer cancel()
+	if err := ln.awaitNodeHealthy(ctx, node); err != nil {
+		ln.log.Debug("stopped waiting for node to become healthy", zap.String("name", node.GetName()), zap.Error(err))
+		return
+	}
+	onNodeHealthy(node.GetName())
+}
+
 // See network.Network
 func (ln *localNetwork) AddNode(nodeConfig node.Config) (node.Node, error) {
 	ln.lock.Lock()
@@ -455,15 +640,28 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 			nodeConfig.ChainConfigFiles[k] = v
 		}
 	}
+	if len(ln.upgradeConfigFiles) > 0 && nodeConfig.UpgradeConfigFiles == nil {
+		nodeConfig.UpgradeConfigFiles = map[string]string{}
+	}
 	for k, v := range ln.upgradeConfigFiles {
 		_, ok := nodeConfig.UpgradeConfigFiles[k]
 		if !ok {
 			nodeConfig.UpgradeConfigFiles[k] = v
 		}
 	}
+	if len(ln.subnetConfigFiles) > 0 && nodeConfig.SubnetConfigFiles == nil {
+		nodeConfig.SubnetConfigFiles = map[string]string{}
+	}
+	for k, v := range ln.subnetConfigFiles {
+		_, ok := nodeConfig.SubnetConfigFiles[k]
+		if !ok {
+			nodeConfig.SubnetConfigFiles[k] = v
+		}
+	}
 	addNetworkFlags(ln.log, ln.flags, nodeConfig.Flags)
 
-	// it shouldn't happen that just one is empty, most probably both,
+	// nodes without a given staking key and cert get new ones, and so a new node ID.
+	// it shouldn't happen that just one is empty, as configs are validated,
 	// but in any case if just one is empty it's unusable so we just assign a new one.
 	if nodeConfig.StakingCert == "" || nodeConfig.StakingKey == "" {
 		stakingCert, stakingKey, err := staking.NewCertAndKeyBytes()
@@ -496,14 +694,49 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 		return nil, err
 	}
 
+	// keep the full config given to the process, for reference
+	launchConfigBytes, err := launchConfig(configFile, nodeData.flags)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal launch config: %w", err)
+	}
+	launchConfigPath := filepath.Join(nodeDir, launchConfigFileName)
+	if err := createFileAndWrite(launchConfigPath, launchConfigBytes); err != nil {
+		return nil, fmt.Errorf("couldn't write launch config file %q: %w", launchConfigPath, err)
+	}
+
 	// Parse this node's ID
 	nodeID, err := utils.ToNodeID([]byte(nodeConfig.StakingKey), []byte(nodeConfig.StakingCert))
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get node ID: %w", err)
 	}
 
+	// Create a wrapper for this node so we can reference it later
+	node := &localNode{
+		name:             nodeConfig.Name,
+		nodeID:           nodeID,
+		networkID:        ln.networkID,
+		apiPort:          nodeData.apiPort,
+		p2pPort:          nodeData.p2pPort,
+		getConnFunc:      defaultGetConnFunc,
+		dbDir:            nodeData.dbDir,
+		logsDir:          nodeData.logsDir,
+		profileDir:       nodeData.profileDir,
+		config:           nodeConfig,
+		buildDir:         nodeData.buildDir,
+		httpHost:         nodeData.httpHost,
+		attachedPeers:    map[string]peer.Peer{},
+		launchConfigPath: launchConfigPath,
+	}
+	// the API clients are created before starting the node, so that it isn't left
+	// running if they can't be
+	node.httpClient = api.NewHTTPClient(nodeConfig.APIHeaders, nodeConfig.APITransport, node.GetAPITimeout())
+	node.client, err = ln.newAPIClientF(apiClientIP, nodeData.apiPort, node.httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create API clients: %w", err)
+	}
+
 	// Start the AvalancheGo node and pass it the flags defined above
-	nodeProcess, err := ln.nodeProcessCreator.NewNodeProcess(nodeConfig, nodeData.flags...)
+	node.process, err = ln.nodeProcessCreator.NewNodeProcess(nodeConfig, nodeData.flags...)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"couldn't create new node process with binary %q and flags %v: %w",
@@ -528,24 +761,8 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 		zap.Strings("flags", nodeData.flags),
 	)
 
-	// Create a wrapper for this node so we can reference it later
-	node := &localNode{
-		name:          nodeConfig.Name,
-		nodeID:        nodeID,
-		networkID:     ln.networkID,
-		client:        ln.newAPIClientF("localhost", nodeData.apiPort),
-		process:       nodeProcess,
-		apiPort:       nodeData.apiPort,
-		p2pPort:       nodeData.p2pPort,
-		getConnFunc:   defaultGetConnFunc,
-		dbDir:         nodeData.dbDir,
-		logsDir:       nodeData.logsDir,
-		config:        nodeConfig,
-		buildDir:      nodeData.buildDir,
-		httpHost:      nodeData.httpHost,
-		attachedPeers: map[string]peer.Peer{},
-	}
 	ln.nodes[node.name] = node
+	go ln.watchNodeExit(node)
 	// If this node is a beacon, add its IP/ID to the beacon lists.
 	// Note that we do this *after* we set this node's bootstrap IPs/IDs
 	// so this node won't try to use itself as a beacon.
@@ -559,13 +776,126 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 }
 
 // See network.Network
-func (ln *localNetwork) Healthy(ctx context.Context) error {
+func (ln *localNetwork) ScaleTo(ctx context.Context, numNodes uint32) (map[string]node.Node, error) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	addedNodeNames := []string{}
+	// remove the nodes added so far, so the network is left as it was
+	rollback := func() {
+		for _, nodeName := range addedNodeNames {
+			stopCtx, stopCtxCancel := context.WithTimeout(context.Background(), stopTimeout)
+			if err := ln.removeNode(stopCtx, nodeName); err != nil {
+				ln.log.Debug("error removing node", zap.String("name", nodeName), zap.Error(err))
+			}
+			stopCtxCancel()
+		}
+	}
+	for len(ln.nodes) < int(numNodes) {
+		// new nodes use the network defaults and bootstrap from the current beacons
+		newNode, err := ln.addNode(node.Config{})
+		if err != nil {
+			rollback()
+			return nil, fmt.Errorf("error adding node: %w", err)
+		}
+		addedNodeNames = append(addedNodeNames, newNode.GetName())
+	}
+	if err := ln.healthy(ctx); err != nil {
+		rollback()
+		return nil, err
+	}
+
+	nodesCopy := make(map[string]node.Node, len(ln.nodes))
+	for name, node := range ln.nodes {
+		nodesCopy[name] = node
+	}
+	return nodesCopy, nil
+}
+
+// See network.Network
+func (ln *localNetwork) Healthy(ctx context.Context, opts ...network.HealthOption) error {
 	ln.lock.RLock()
 	defer ln.lock.RUnlock()
+	op := network.NewHealthOp(opts...)
+	if op.MinHealthyDuration > 0 {
+		return ln.stablyHealthy(ctx, op.MinHealthyDuration)
+	}
 	return ln.healthy(ctx)
 }
 
+// Assumes [ln.lock] is held.
+// Waits until all the nodes are healthy, and then checks them every [healthCheckFreq]
+// until they have been healthy for [minHealthyDuration]. If a check finds an unhealthy
+// node, all the nodes are waited for again and the duration starts over.
+func (ln *localNetwork) stablyHealthy(ctx context.Context, minHealthyDuration time.Duration) error {
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+	for {
+		if err := ln.healthy(ctx); err != nil {
+			return err
+		}
+		healthySince := time.Now()
+		stable := true
+		for stable {
+			remaining := minHealthyDuration - time.Since(healthySince)
+			if remaining <= 0 {
+				return nil
+			}
+			if remaining > healthCheckFreq {
+				remaining = healthCheckFreq
+			}
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: network not healthy for %s", ctx.Err(), minHealthyDuration)
+			case <-time.After(remaining):
+			}
+			unhealthyNodes := ln.unhealthyNodes(ctx)
+			if len(unhealthyNodes) > 0 {
+				ln.log.Info("nodes became unhealthy, waiting for them again", zap.Strings("node-names", unhealthyNodes))
+				stable = false
+			}
+		}
+	}
+}
+
+// Assumes [ln.lock] is held.
+// Returns the sorted names of the nodes that fail a single health check.
+func (ln *localNetwork) unhealthyNodes(ctx context.Context) []string {
+	var (
+		lock      sync.Mutex
+		unhealthy = []string{}
+	)
+	_ = forEachNode(ctx, ln.nodes, ln.healthCheckConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		healthy := atomic.LoadUint32(&node.stopped) == 0 && node.Status() == status.Running
+		if healthy {
+			cctx, cancel := createNodeCtx(ctx, node)
+			health, err := node.client.HealthAPI().Health(cctx)
+			cancel()
+			healthy = err == nil && health.Healthy
+		}
+		if !healthy {
+			lock.Lock()
+			unhealthy = append(unhealthy, nodeName)
+			lock.Unlock()
+		}
+		return nil
+	})
+	sort.Strings(unhealthy)
+	return unhealthy
+}
+
+// Assumes [ln.lock] is held.
 func (ln *localNetwork) healthy(ctx context.Context) error {
+	return ln.healthyWithConcurrency(ctx, ln.healthCheckConcurrency)
+}
+
+// Assumes [ln.lock] is held.
+// Waits until all the nodes are healthy, checking at most [maxConcurrency] of them at a time.
+func (ln *localNetwork) healthyWithConcurrency(ctx context.Context, maxConcurrency uint32) error {
 	ln.log.Info("checking local network healthiness", zap.Int("num-of-nodes", len(ln.nodes)))
 
 	// Return unhealthy if the network is stopped
@@ -575,46 +905,81 @@ func (ln *localNetwork) healthy(ctx context.Context) error {
 
 	// Derive a new context that's cancelled when Stop is called,
 	// so that we calls to Healthy() below immediately return.
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := ln.newStopAwareContext(ctx)
 	defer cancel()
+
+	// Wait until all nodes are ready or timeout
+	return forEachNode(ctx, ln.nodes, maxConcurrency, func(ctx context.Context, _ string, node *localNode) error {
+		return ln.awaitNodeHealthy(ctx, node)
+	})
+}
+
+// Assumes [ln.lock] is held.
+// Waits until all the nodes, but the nodes stopped with StopNode, are healthy,
+// checking at most [maxConcurrency] of them at a time.
+// Used after restarting the nodes, which leaves the stopped ones stopped.
+func (ln *localNetwork) runningNodesHealthy(ctx context.Context, maxConcurrency uint32) error {
+	return forEachNode(ctx, ln.runningNodes(), maxConcurrency, func(ctx context.Context, _ string, node *localNode) error {
+		return ln.awaitNodeHealthy(ctx, node)
+	})
+}
+
+// Assumes [ln.lock] is held.
+// Saves [nodeConfig] as the config StartNode starts [node], stopped with StopNode, with,
+// so that the restarts skipping the stopped nodes still apply their changes to them.
+// [node] is replaced with a copy, instead of being modified, as it may be in use
+// without the lock.
+func (ln *localNetwork) setStoppedNodeConfig(node *localNode, nodeConfig node.Config) {
+	stoppedNode := *node
+	stoppedNode.config = nodeConfig
+	ln.nodes[node.name] = &stoppedNode
+}
+
+// Returns a context derived from [ctx] that is also cancelled when
+// [ln.Stop] is called.
+func (ln *localNetwork) newStopAwareContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
 	go func(ctx context.Context) {
 		// This goroutine runs until [ln.Stop] is called
-		// or this function returns.
+		// or the returned context is done.
 		select {
 		case <-ln.onStopCh:
 			cancel()
 		case <-ctx.Done():
 		}
 	}(ctx)
+	return ctx, cancel
+}
 
-	errGr, ctx := errgroup.WithContext(ctx)
-	for _, node := range ln.nodes {
-		node := node
-		nodeName := node.GetName()
-		errGr.Go(func() error {
-			// Every [healthCheckFreq], query node for health status.
-			// Do this until ctx timeout or network closed.
-			for {
-				if node.Status() != status.Running {
-					// If we had stopped this node ourselves, it wouldn't be in [ln.nodes].
-					// Since it is, it means the node stopped unexpectedly.
-					return fmt.Errorf("node %q stopped unexpectedly", nodeName)
-				}
-				health, err := node.client.HealthAPI().Health(ctx)
-				if err == nil && health.Healthy {
-					ln.log.Debug("node became healthy", zap.String("name", nodeName))
-					return nil
-				}
-				select {
-				case <-ctx.Done():
-					return fmt.Errorf("node %q failed to become healthy within timeout, or network stopped", nodeName)
-				case <-time.After(healthCheckFreq):
-				}
-			}
-		})
+// Every [healthCheckFreq], query [node] for health status.
+// Returns nil once the node is healthy, or an error if the node
+// stops or [ctx] is done first.
+func (ln *localNetwork) awaitNodeHealthy(ctx context.Context, node *localNode) error {
+	nodeName := node.GetName()
+	for {
+		if atomic.LoadUint32(&node.stopped) == 1 {
+			return fmt.Errorf("%w: node %q was stopped with StopNode", ErrNodeStopped, nodeName)
+		}
+		if node.Status() != status.Running {
+			// If we had stopped this node ourselves, it wouldn't be in [ln.nodes].
+			// Since it is, it means the node stopped unexpectedly.
+			return fmt.Errorf("node %q stopped unexpectedly", nodeName)
+		}
+		cctx, cancel := createNodeCtx(ctx, node)
+		health, err := node.client.HealthAPI().Health(cctx)
+		cancel()
+		if err == nil && health.Healthy {
+			ln.log.Debug("node became healthy", zap.String("name", nodeName))
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %q failed to become healthy within timeout, or network stopped", nodeName)
+		case <-node.process.Exited():
+			// checked on the next iteration
+		case <-time.After(healthCheckFreq):
+		}
 	}
-	// Wait until all nodes are ready or timeout
-	return errGr.Wait()
 }
 
 // See network.Network
@@ -633,6 +998,56 @@ func (ln *localNetwork) GetNode(nodeName string) (node.Node, error) {
 	return node, nil
 }
 
+// See network.Network
+func (ln *localNetwork) GetNodeByID(nodeID ids.NodeID) (node.Node, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	for _, node := range ln.nodes {
+		if node.GetNodeID() == nodeID {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: node ID %s", network.ErrNodeNotFound, nodeID)
+}
+
+// See network.Network
+func (ln *localNetwork) GetBuildInfo() network.BuildInfo {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	return ln.getBuildInfo(context.Background())
+}
+
+// Returns the build info of the network, querying the avalanchego binary
+// version from some node. Failing to query it is only logged.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) getBuildInfo(ctx context.Context) network.BuildInfo {
+	buildInfo := network.BuildInfo{}
+	buildInfo.RunnerVersion, buildInfo.AvalancheGoLibVersion = getModuleVersions()
+	if ln.stopCalled() {
+		return buildInfo
+	}
+	node := ln.getSomeNode()
+	if node == nil {
+		return buildInfo
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	reply, err := node.GetAPIClient().InfoAPI().GetNodeVersion(cctx)
+	cancel()
+	if err != nil {
+		ln.log.Warn("failure getting avalanchego version", zap.String("node", node.GetName()), zap.Error(err))
+		return buildInfo
+	}
+	buildInfo.AvalancheGoVersion = reply.Version
+	buildInfo.AvalancheGoCommit = reply.GitCommit
+	return buildInfo
+}
+
 // See network.Network
 func (ln *localNetwork) GetNodeNames() ([]string, error) {
 	ln.lock.RLock()
@@ -648,6 +1063,55 @@ func (ln *localNetwork) GetNodeNames() ([]string, error) {
 		names[i] = name
 		i++
 	}
+	utils.SortNodeNames(names)
+	return names, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetNodeNamesByRole(ctx context.Context, role network.NodeRole) ([]string, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	names := []string{}
+	switch role.Kind {
+	case network.NodeRoleBeacon:
+		for name, node := range ln.nodes {
+			if node.config.IsBeacon {
+				names = append(names, name)
+			}
+		}
+	case network.NodeRoleValidator, network.NodeRoleSubnetValidator:
+		subnetID := constants.PrimaryNetworkID
+		if role.Kind == network.NodeRoleSubnetValidator {
+			subnetID = role.SubnetID
+		}
+		node := ln.getSomeNode()
+		if node == nil {
+			return nil, errors.New("no nodes available to query the P-Chain")
+		}
+		cctx, cancel := createNodeCtx(ctx, node)
+		vs, err := node.GetAPIClient().PChainAPI().GetCurrentValidators(cctx, subnetID, nil)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failure getting subnet %s validators: %w", subnetID, err)
+		}
+		validatorIDs := map[ids.NodeID]struct{}{}
+		for _, v := range vs {
+			validatorIDs[v.NodeID] = struct{}{}
+		}
+		for name, node := range ln.nodes {
+			if _, ok := validatorIDs[node.nodeID]; ok {
+				names = append(names, name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown node role %q", role.Kind)
+	}
+	utils.SortNodeNames(names)
 	return names, nil
 }
 
@@ -667,50 +1131,484 @@ func (ln *localNetwork) GetAllNodes() (map[string]node.Node, error) {
 	return nodesCopy, nil
 }
 
-func (ln *localNetwork) Stop(ctx context.Context) error {
-	err := network.ErrStopped
-	ln.stopOnce.Do(
-		func() {
-			close(ln.onStopCh)
+// See network.Network
+func (ln *localNetwork) ForEachNode(ctx context.Context, concurrency int, fn func(node.Node) error) error {
+	if concurrency <= 0 {
+		return fmt.Errorf("concurrency must be greater than 0, got %d", concurrency)
+	}
 
-			ln.lock.Lock()
-			defer ln.lock.Unlock()
+	// [fn] runs without the lock held, so it can call the network
+	ln.lock.RLock()
+	if ln.stopCalled() {
+		ln.lock.RUnlock()
+		return network.ErrStopped
+	}
+	nodes := make(map[string]*localNode, len(ln.nodes))
+	for name, node := range ln.nodes {
+		nodes[name] = node
+	}
+	ln.lock.RUnlock()
 
-			err = ln.stop(ctx)
-		},
-	)
-	return err
+	var errsLock sync.Mutex
+	errs := []string{}
+	err := forEachNode(ctx, nodes, uint32(concurrency), func(_ context.Context, nodeName string, node *localNode) error {
+		if err := fn(node); err != nil {
+			errsLock.Lock()
+			errs = append(errs, fmt.Sprintf("node %q: %s", nodeName, err))
+			errsLock.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
 }
 
-// Assumes [ln.lock] is held.
-func (ln *localNetwork) stop(ctx context.Context) error {
-	errs := wrappers.Errs{}
-	for nodeName := range ln.nodes {
-		stopCtx, stopCtxCancel := context.WithTimeout(ctx, stopTimeout)
-		if err := ln.removeNode(stopCtx, nodeName); err != nil {
-			ln.log.Error("error stopping node", zap.String("name", nodeName), zap.Error(err))
-			errs.Add(err)
+// See network.Network
+func (ln *localNetwork) VerifyNodes(ctx context.Context, expected []ids.NodeID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	nodes, err := ln.GetAllNodes()
+	if err != nil {
+		return err
+	}
+	expectedSet := ids.NodeIDSet{}
+	expectedSet.Add(expected...)
+	currentSet := ids.NodeIDSet{}
+	extra := []string{}
+	for nodeName, node := range nodes {
+		nodeID := node.GetNodeID()
+		currentSet.Add(nodeID)
+		if !expectedSet.Contains(nodeID) {
+			extra = append(extra, fmt.Sprintf("%s (%s)", nodeID, nodeName))
 		}
-		stopCtxCancel()
 	}
-	ln.log.Info("done stopping network")
-	return errs.Err
+	missing := []string{}
+	for _, nodeID := range expectedSet.List() {
+		if !currentSet.Contains(nodeID) {
+			missing = append(missing, nodeID.String())
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return fmt.Errorf("network nodes don't match the expected ones: missing %v, extra %v", missing, extra)
 }
 
-// Sends a SIGTERM to the given node and removes it from this network.
-func (ln *localNetwork) RemoveNode(ctx context.Context, nodeName string) error {
-	ln.lock.Lock()
-	defer ln.lock.Unlock()
+// See network.Network
+func (ln *localNetwork) CollectMetrics(ctx context.Context) (map[string][]byte, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	var metricsLock sync.Mutex
+	metrics := make(map[string][]byte, len(ln.nodes))
+	err := forEachNode(ctx, ln.runningNodes(), network.DefaultMaxConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		nodeMetrics, err := scrapeMetrics(ctx, node.httpClient, node.GetMetricsURL())
+		if err != nil {
+			return fmt.Errorf("failure collecting metrics from node %q: %w", nodeName, err)
+		}
+		metricsLock.Lock()
+		metrics[nodeName] = nodeMetrics
+		metricsLock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// See network.Network
+func (ln *localNetwork) CheckClockSkew(ctx context.Context, maxSkew time.Duration) error {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
 	if ln.stopCalled() {
 		return network.ErrStopped
 	}
-	return ln.removeNode(ctx, nodeName)
+	return ln.checkClockSkew(ctx, maxSkew, network.DefaultMaxConcurrency)
 }
 
 // Assumes [ln.lock] is held.
-func (ln *localNetwork) removeNode(ctx context.Context, nodeName string) error {
-	ln.log.Debug("removing node", zap.String("name", nodeName))
-	node, ok := ln.nodes[nodeName]
+// Returns an error wrapping ErrClockSkew, describing all the nodes whose clock
+// differs from the local one by more than [maxSkew].
+func (ln *localNetwork) checkClockSkew(ctx context.Context, maxSkew time.Duration, maxConcurrency uint32) error {
+	var skewsLock sync.Mutex
+	skews := map[string]time.Duration{}
+	err := forEachNode(ctx, ln.runningNodes(), maxConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		infoURL := "http://" + utils.JoinHostPort(node.GetURL(), node.GetAPIPort()) + infoEndpoint
+		cctx, cancel := createNodeCtx(ctx, node)
+		nodeTime, sent, received, err := getNodeTime(cctx, node.httpClient, infoURL)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failure getting clock of node %q: %w", nodeName, err)
+		}
+		if skew := clockSkew(nodeTime, sent, received); skew > maxSkew {
+			skewsLock.Lock()
+			skews[nodeName] = skew
+			skewsLock.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(skews) == 0 {
+		return nil
+	}
+	nodeNames := make([]string, 0, len(skews))
+	for nodeName := range skews {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	errs := make([]string, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		errs = append(errs, fmt.Sprintf("node %q clock skew %s", nodeName, skews[nodeName]))
+	}
+	return fmt.Errorf("%w %s: %s", ErrClockSkew, maxSkew, strings.Join(errs, "; "))
+}
+
+// See network.Network
+func (ln *localNetwork) GetNodeConfigFile(nodeName string) ([]byte, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	launchConfig, err := os.ReadFile(node.launchConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read launch config file of node %q: %w", nodeName, err)
+	}
+	return launchConfig, nil
+}
+
+// Assumes [ln.lock] is held.
+// Returns an error wrapping ErrNetworkIDMismatch, naming all the nodes whose
+// network ID, as reported by their Info API, is not the one of the network,
+// as they wouldn't confirm the network transactions.
+func (ln *localNetwork) checkNetworkIDs(ctx context.Context, maxConcurrency uint32) error {
+	var mismatchesLock sync.Mutex
+	mismatches := map[string]uint32{}
+	err := forEachNode(ctx, ln.runningNodes(), maxConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		cctx, cancel := createNodeCtx(ctx, node)
+		networkID, err := node.client.InfoAPI().GetNetworkID(cctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failure getting network ID of node %q: %w", nodeName, err)
+		}
+		if networkID != ln.networkID {
+			mismatchesLock.Lock()
+			mismatches[nodeName] = networkID
+			mismatchesLock.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	nodeNames := make([]string, 0, len(mismatches))
+	for nodeName := range mismatches {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	errs := make([]string, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		errs = append(errs, fmt.Sprintf("node %q network ID %d", nodeName, mismatches[nodeName]))
+	}
+	return fmt.Errorf("%w %d: %s", ErrNetworkIDMismatch, ln.networkID, strings.Join(errs, "; "))
+}
+
+// See network.Network
+func (ln *localNetwork) SetLogLevel(ctx context.Context, nodeName string, level logging.Level) error {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	enabled, err := node.adminAPIEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("%w on node %q, it must be started with --%s=true", ErrAdminAPIDisabled, nodeName, config.AdminAPIEnabledKey)
+	}
+	cctx, cancel := createNodeCtx(ctx, node)
+	defer cancel()
+	// an empty logger name sets the level of all the node loggers
+	if err := node.GetAPIClient().AdminAPI().SetLoggerLevel(cctx, "", level.String(), level.String()); err != nil {
+		return fmt.Errorf("failure setting log level of node %q: %w", nodeName, err)
+	}
+	ln.log.Info("set node log level", zap.String("node-name", nodeName), zap.String("level", level.String()))
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetProfile(
+	ctx context.Context,
+	nodeName string,
+	kind network.ProfileKind,
+	cpuDuration time.Duration,
+) ([]byte, error) {
+	ln.lock.RLock()
+	if ln.stopCalled() {
+		ln.lock.RUnlock()
+		return nil, network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	ln.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	enabled, err := node.adminAPIEnabled()
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, fmt.Errorf("%w on node %q, it must be started with --%s=true", ErrAdminAPIDisabled, nodeName, config.AdminAPIEnabledKey)
+	}
+
+	// the lock is not held while profiling, so that a CPU profile doesn't block the network
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+
+	adminCli := node.GetAPIClient().AdminAPI()
+	var profileFileName string
+	switch kind {
+	case network.ProfileHeap:
+		profileFileName = memProfileFileName
+		cctx, cancel := createNodeCtx(ctx, node)
+		err = adminCli.MemoryProfile(cctx)
+		cancel()
+	case network.ProfileLock:
+		profileFileName = lockProfileFileName
+		cctx, cancel := createNodeCtx(ctx, node)
+		err = adminCli.LockProfile(cctx)
+		cancel()
+	case network.ProfileCPU:
+		if cpuDuration <= 0 {
+			return nil, errors.New("cpu profile duration must be greater than 0")
+		}
+		profileFileName = cpuProfileFileName
+		err = profileCPU(ctx, node, cpuDuration)
+	default:
+		return nil, fmt.Errorf("unknown profile kind %q", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failure getting %s profile of node %q: %w", kind, nodeName, err)
+	}
+	return os.ReadFile(filepath.Join(node.profileDir, profileFileName))
+}
+
+// Samples the CPU profile of [node] for [duration]. The profiler is stopped
+// even if [ctx] is done first, so that it can be started again.
+func profileCPU(ctx context.Context, node *localNode, duration time.Duration) error {
+	adminCli := node.GetAPIClient().AdminAPI()
+	cctx, cancel := createNodeCtx(ctx, node)
+	err := adminCli.StartCPUProfiler(cctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	var errs wrappers.Errs
+	select {
+	case <-ctx.Done():
+		errs.Add(ctx.Err())
+	case <-time.After(duration):
+	}
+	cctx, cancel = createNodeCtx(context.Background(), node)
+	errs.Add(adminCli.StopCPUProfiler(cctx))
+	cancel()
+	return errs.Err
+}
+
+// See network.Network
+// Concurrent callers block in [ln.stopOnce] until the first one is done
+// tearing down the nodes, so none of them returns before the network is stopped.
+func (ln *localNetwork) Stop(ctx context.Context) error {
+	err := network.ErrStopped
+	ln.stopOnce.Do(
+		func() {
+			close(ln.onStopCh)
+
+			ln.lock.Lock()
+			defer ln.lock.Unlock()
+
+			err = ln.stop(ctx)
+		},
+	)
+	return err
+}
+
+// See network.Network
+func (ln *localNetwork) GetRootDir() string {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	return ln.rootDir
+}
+
+// Returns true if a config or snapshot was loaded, as the genesis is then set.
+func (ln *localNetwork) initialized() bool {
+	return len(ln.genesis) != 0
+}
+
+// See network.Network
+func (ln *localNetwork) GetGenesis() ([]byte, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if !ln.initialized() {
+		return nil, network.ErrUndefined
+	}
+	genesis := make([]byte, len(ln.genesis))
+	copy(genesis, ln.genesis)
+	return genesis, nil
+}
+
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) stop(ctx context.Context) error {
+	errs := wrappers.Errs{}
+	for nodeName := range ln.nodes {
+		stopCtx, stopCtxCancel := context.WithTimeout(ctx, stopTimeout)
+		if err := ln.removeNode(stopCtx, nodeName); err != nil {
+			ln.log.Error("error stopping node", zap.String("name", nodeName), zap.Error(err))
+			errs.Add(err)
+		}
+		stopCtxCancel()
+	}
+	if err := ln.clearNetworkConditions(ctx); err != nil {
+		ln.log.Error("error removing network conditions", zap.Error(err))
+		errs.Add(err)
+	}
+	ln.log.Info("done stopping network", zap.String("root-dir", ln.rootDir))
+	return errs.Err
+}
+
+// Sends a SIGTERM to the given node and removes it from this network.
+func (ln *localNetwork) RemoveNode(ctx context.Context, nodeName string) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	if err := ln.replaceLastBeacon(ctx, nodeName); err != nil {
+		return err
+	}
+	return ln.removeNode(ctx, nodeName)
+}
+
+// See network.Network
+func (ln *localNetwork) DrainNode(ctx context.Context, nodeName string, timeout time.Duration) error {
+	ln.lock.RLock()
+	if ln.stopCalled() {
+		ln.lock.RUnlock()
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	ln.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	nodeID := node.GetNodeID()
+
+	// the lock is not held while waiting, so that the network can be used meanwhile
+	drainCtx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+	drainCtx, timeoutCancel := context.WithTimeout(drainCtx, timeout)
+	defer timeoutCancel()
+	for {
+		ln.lock.RLock()
+		subnetIDs, err := ln.getValidatedSubnets(drainCtx, nodeID)
+		ln.lock.RUnlock()
+		if err != nil {
+			return fmt.Errorf("couldn't drain node %q: %w", nodeName, err)
+		}
+		if len(subnetIDs) == 0 {
+			break
+		}
+		ln.log.Info("waiting for node to leave validator sets", zap.String("name", nodeName), zap.String("subnets", fmt.Sprint(subnetIDs)))
+		select {
+		case <-drainCtx.Done():
+			return fmt.Errorf("node %q still validates subnets %v after draining: %w", nodeName, subnetIDs, drainCtx.Err())
+		case <-time.After(drainCheckFreq):
+		}
+	}
+	ln.log.Info("node drained", zap.String("name", nodeName))
+	return ln.RemoveNode(ctx, nodeName)
+}
+
+// Assumes [ln.lock] is held.
+// If [nodeName] is the last beacon of the network, makes the first other
+// healthy node, by name, a beacon, so that the nodes added after [nodeName]
+// is removed can bootstrap.
+// Returns an error wrapping ErrNoBeacon if none of the other nodes is healthy.
+func (ln *localNetwork) replaceLastBeacon(ctx context.Context, nodeName string) error {
+	node, ok := ln.nodes[nodeName]
+	if !ok || !node.config.IsBeacon || ln.bootstraps.Len() > 1 || len(ln.nodes) == 1 {
+		return nil
+	}
+	nodeNames := make([]string, 0, len(ln.nodes)-1)
+	for otherNodeName := range ln.nodes {
+		if otherNodeName != nodeName {
+			nodeNames = append(nodeNames, otherNodeName)
+		}
+	}
+	sort.Strings(nodeNames)
+	for _, otherNodeName := range nodeNames {
+		otherNode := ln.nodes[otherNodeName]
+		if atomic.LoadUint32(&otherNode.stopped) == 1 || otherNode.Status() != status.Running {
+			continue
+		}
+		cctx, cancel := createNodeCtx(ctx, otherNode)
+		health, err := otherNode.client.HealthAPI().Health(cctx)
+		cancel()
+		if err != nil || !health.Healthy {
+			continue
+		}
+		if err := ln.bootstraps.Add(beacon.New(otherNode.nodeID, ips.IPPort{
+			IP:   net.IPv6loopback,
+			Port: otherNode.p2pPort,
+		})); err != nil {
+			return fmt.Errorf("couldn't make node %q a beacon: %w", otherNodeName, err)
+		}
+		otherNode.config.IsBeacon = true
+		ln.log.Info("replacing removed beacon", zap.String("removed", nodeName), zap.String("beacon", otherNodeName))
+		return nil
+	}
+	return fmt.Errorf("%w: can't remove node %q, the last beacon, as no other node is healthy", ErrNoBeacon, nodeName)
+}
+
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) removeNode(ctx context.Context, nodeName string) error {
+	ln.log.Debug("removing node", zap.String("name", nodeName))
+	node, ok := ln.nodes[nodeName]
 	if !ok {
 		return fmt.Errorf("node %q not found", nodeName)
 	}
@@ -719,6 +1617,7 @@ func (ln *localNetwork) removeNode(ctx context.Context, nodeName string) error {
 	_ = ln.bootstraps.RemoveByID(node.nodeID)
 
 	delete(ln.nodes, nodeName)
+	atomic.StoreUint32(&node.stopRequested, 1)
 	// cchain eth api uses a websocket connection and must be closed before stopping the node,
 	// to avoid errors logs at client
 	node.client.CChainEthAPI().Close()
@@ -728,6 +1627,98 @@ func (ln *localNetwork) removeNode(ctx context.Context, nodeName string) error {
 	return nil
 }
 
+// See network.Network
+func (ln *localNetwork) StopNode(ctx context.Context, nodeName string) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("node %q not found", nodeName)
+	}
+	if atomic.LoadUint32(&node.stopped) == 1 {
+		return fmt.Errorf("%w: node %q is already stopped", ErrNodeStopped, nodeName)
+	}
+	ln.log.Info("stopping node", zap.String("name", nodeName))
+	atomic.StoreUint32(&node.stopped, 1)
+	// nodes added while it is stopped must not bootstrap from it, and StartNode
+	// adds it back when restarting it
+	_ = ln.bootstraps.RemoveByID(node.nodeID)
+	atomic.StoreUint32(&node.stopRequested, 1)
+	// see removeNode
+	node.client.CChainEthAPI().Close()
+	if exitCode := node.process.Stop(ctx); exitCode != 0 {
+		return fmt.Errorf("node %q exited with exit code: %d", nodeName, exitCode)
+	}
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) StartNode(_ context.Context, nodeName string) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("node %q not found", nodeName)
+	}
+	if atomic.LoadUint32(&node.stopped) == 0 {
+		return fmt.Errorf("node %q is not stopped", nodeName)
+	}
+	ln.log.Info("starting stopped node", zap.String("name", nodeName))
+	nodeConfig := node.getConfig()
+	if nodeConfig.Flags == nil {
+		nodeConfig.Flags = map[string]interface{}{}
+	}
+	// keep same ports, dbdir in node flags
+	nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
+	nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+	nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+	// the process was already stopped by StopNode, which reported its exit code,
+	// so the node is replaced without stopping it again, as removeNode would
+	delete(ln.nodes, nodeName)
+	if _, err := ln.addNode(nodeConfig); err != nil {
+		// the node stays in the network, stopped
+		ln.nodes[nodeName] = node
+		return err
+	}
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) NodeExited() <-chan network.NodeExit {
+	return ln.nodeExits
+}
+
+// Reports the exit of the process of [node] to [ln.nodeExits],
+// unless the network stopped it.
+// Returns once the process exits or the network is stopped.
+func (ln *localNetwork) watchNodeExit(node *localNode) {
+	select {
+	case <-node.process.Exited():
+	case <-ln.onStopCh:
+		return
+	}
+	if atomic.LoadUint32(&node.stopRequested) == 1 {
+		return
+	}
+	// the process already exited, so this just gets its exit code
+	exit := network.NodeExit{
+		NodeName: node.GetName(),
+		ExitCode: node.process.Stop(context.Background()),
+	}
+	ln.log.Warn("node exited unexpectedly", zap.String("name", exit.NodeName), zap.Int("exit-code", exit.ExitCode))
+	select {
+	case ln.nodeExits <- exit:
+	default:
+		ln.log.Warn("dropped node exit notification, as none are being received", zap.String("name", exit.NodeName))
+	}
+}
+
 // Restart [nodeName] using the same config, optionally changing [binaryPath],
 // [buildDir], [whitelistedSubnets]
 func (ln *localNetwork) RestartNode(
@@ -746,7 +1737,7 @@ func (ln *localNetwork) RestartNode(
 		return fmt.Errorf("node %q not found", nodeName)
 	}
 
-	nodeConfig := node.GetConfig()
+	nodeConfig := node.getConfig()
 
 	if binaryPath != "" {
 		nodeConfig.BinaryPath = binaryPath
@@ -757,10 +1748,6 @@ func (ln *localNetwork) RestartNode(
 		nodeConfig.Flags[config.WhitelistedSubnetsKey] = whitelistedSubnets
 	}
 
-	// keep same ports, dbdir in node flags
-	nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
-	nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
-	nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
 	// apply chain configs
 	for k, v := range chainConfigs {
 		nodeConfig.ChainConfigFiles[k] = v
@@ -770,14 +1757,304 @@ func (ln *localNetwork) RestartNode(
 		nodeConfig.UpgradeConfigFiles[k] = v
 	}
 
+	_, err := ln.restartNode(ctx, node, nodeConfig)
+	return err
+}
+
+// See network.Network
+func (ln *localNetwork) UpgradeNode(ctx context.Context, nodeName string, newBinaryPath string) error {
+	if err := utils.CheckExecutable(newBinaryPath); err != nil {
+		return fmt.Errorf("invalid binary %q for node %q: %w", newBinaryPath, nodeName, err)
+	}
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+
+	nodeConfig := node.getConfig()
+	ln.log.Info("upgrading node", zap.String("name", nodeName), zap.String("from", nodeConfig.BinaryPath), zap.String("to", newBinaryPath))
+	nodeConfig.BinaryPath = newBinaryPath
+	nodeConfig.Flags[config.BuildDirKey] = filepath.Dir(newBinaryPath)
+	upgradedNode, err := ln.restartNode(ctx, node, nodeConfig)
+	if err != nil {
+		return fmt.Errorf("failure restarting node %q with binary %q: %w", nodeName, newBinaryPath, err)
+	}
+	return ln.awaitNodeHealthy(ctx, upgradedNode)
+}
+
+// See network.Network
+func (ln *localNetwork) ResetNodeState(ctx context.Context, nodeName string) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if atomic.LoadUint32(&node.stopped) == 1 {
+		return fmt.Errorf("%w: node %q was stopped with StopNode", ErrNodeStopped, nodeName)
+	}
+
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+
+	// the node bootstraps from the beacons, so without any it would start
+	// over from genesis instead of syncing the network state
+	beacons := ln.healthyBeacons(ctx, nodeName)
+	if len(beacons) == 0 {
+		return fmt.Errorf("%w: can't reset node %q, as no other beacon is healthy to bootstrap from", ErrNoBeacon, nodeName)
+	}
+
+	// keep the identity and ports of the node
+	nodeConfig := node.getConfig()
+	dbDir := node.GetDbDir()
+	nodeConfig.Flags[config.DBPathKey] = dbDir
+	nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+	nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+	ln.log.Info("resetting node state", zap.String("name", nodeName), zap.String("db-dir", dbDir), zap.Strings("beacons", beacons))
 	if err := ln.removeNode(ctx, nodeName); err != nil {
-		return err
+		return fmt.Errorf("failure stopping node %q: %w", nodeName, err)
+	}
+	if err := os.RemoveAll(dbDir); err != nil {
+		return fmt.Errorf("failure removing db dir of node %q: %w", nodeName, err)
+	}
+	if _, err := ln.addNode(nodeConfig); err != nil {
+		return fmt.Errorf("failure restarting node %q: %w", nodeName, err)
+	}
+	return ln.awaitNodeHealthy(ctx, ln.nodes[nodeName])
+}
+
+// Assumes [ln.lock] is held.
+// Returns the names of the beacons of the network, other than [nodeName],
+// that are healthy, sorted.
+func (ln *localNetwork) healthyBeacons(ctx context.Context, nodeName string) []string {
+	beacons := map[string]*localNode{}
+	for otherNodeName, otherNode := range ln.nodes {
+		if otherNodeName != nodeName && otherNode.config.IsBeacon && atomic.LoadUint32(&otherNode.stopped) == 0 {
+			beacons[otherNodeName] = otherNode
+		}
+	}
+	var (
+		lock    sync.Mutex
+		healthy = []string{}
+	)
+	_ = forEachNode(ctx, beacons, ln.healthCheckConcurrency, func(ctx context.Context, beaconName string, beacon *localNode) error {
+		if beacon.Status() != status.Running {
+			return nil
+		}
+		cctx, cancel := createNodeCtx(ctx, beacon)
+		health, err := beacon.client.HealthAPI().Health(cctx)
+		cancel()
+		if err == nil && health.Healthy {
+			lock.Lock()
+			healthy = append(healthy, beaconName)
+			lock.Unlock()
+		}
+		return nil
+	})
+	sort.Strings(healthy)
+	return healthy
+}
+
+// Assumes [ln.lock] is held.
+// Stops [node] and starts it again with [nodeConfig], keeping
+// the node's ports and db dir.
+func (ln *localNetwork) restartNode(
+	ctx context.Context,
+	node *localNode,
+	nodeConfig node.Config,
+) (*localNode, error) {
+	if nodeConfig.Flags == nil {
+		nodeConfig.Flags = map[string]interface{}{}
+	}
+	// keep same ports, dbdir in node flags
+	nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
+	nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+	nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+
+	if err := ln.removeNode(ctx, node.GetName()); err != nil {
+		return nil, err
 	}
 
 	if _, err := ln.addNode(nodeConfig); err != nil {
-		return err
+		return nil, err
+	}
+
+	return ln.nodes[nodeConfig.Name], nil
+}
+
+// See network.Network
+func (ln *localNetwork) Restart(ctx context.Context) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+
+	// the nodes stopped with StopNode are left stopped
+	runningNodes := ln.runningNodes()
+	nodeNames := make([]string, 0, len(runningNodes))
+	for nodeName := range runningNodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	// keep the identity, ports and db of each node
+	nodeConfigs := make([]node.Config, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		node := ln.nodes[nodeName]
+		nodeConfig := node.getConfig()
+		nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
+		nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+		nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+		nodeConfigs = append(nodeConfigs, nodeConfig)
+	}
+	// beacons are started first, as on network creation
+	sort.SliceStable(nodeConfigs, func(i, j int) bool {
+		return nodeConfigs[i].IsBeacon && !nodeConfigs[j].IsBeacon
+	})
+
+	ln.log.Info("stopping all nodes for network restart", zap.Int("num-of-nodes", len(nodeNames)))
+	for _, nodeName := range nodeNames {
+		// the node is removed even if it fails, e.g. with a non-zero exit code
+		// after crashing, and it is restarted like the others
+		if err := ln.removeNode(ctx, nodeName); err != nil {
+			ln.log.Warn("failure stopping node for network restart", zap.String("name", nodeName), zap.Error(err))
+		}
 	}
+	for _, nodeConfig := range nodeConfigs {
+		if _, err := ln.addNode(nodeConfig); err != nil {
+			return fmt.Errorf("failure restarting node %q: %w", nodeConfig.Name, err)
+		}
+	}
+	return ln.runningNodesHealthy(ctx, ln.healthCheckConcurrency)
+}
+
+// RollingRestart restarts the nodes of the network one at a time,
+// waiting for each node to become healthy before restarting the next one.
+// If [newConfig] is not nil, its binary path, flags, chain config files and
+// upgrade config files are applied on top of each node's current config.
+// The nodes stopped with StopNode are not restarted, but StartNode starts them
+// with the changes of [newConfig].
+// Returns an error naming the node that failed to restart or to become healthy.
+func (ln *localNetwork) RollingRestart(ctx context.Context, newConfig *node.Config) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
 
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+
+	nodeNames := make([]string, 0, len(ln.nodes))
+	for nodeName := range ln.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	for _, nodeName := range nodeNames {
+		node := ln.nodes[nodeName]
+		nodeConfig := node.getConfig()
+		if newConfig != nil {
+			if newConfig.BinaryPath != "" {
+				nodeConfig.BinaryPath = newConfig.BinaryPath
+			}
+			if nodeConfig.Flags == nil {
+				nodeConfig.Flags = map[string]interface{}{}
+			}
+			for k, v := range newConfig.Flags {
+				nodeConfig.Flags[k] = v
+			}
+			if nodeConfig.ChainConfigFiles == nil {
+				nodeConfig.ChainConfigFiles = map[string]string{}
+			}
+			for k, v := range newConfig.ChainConfigFiles {
+				nodeConfig.ChainConfigFiles[k] = v
+			}
+			if nodeConfig.UpgradeConfigFiles == nil {
+				nodeConfig.UpgradeConfigFiles = map[string]string{}
+			}
+			for k, v := range newConfig.UpgradeConfigFiles {
+				nodeConfig.UpgradeConfigFiles[k] = v
+			}
+		}
+		if atomic.LoadUint32(&node.stopped) == 1 {
+			ln.setStoppedNodeConfig(node, nodeConfig)
+			continue
+		}
+		ln.log.Info("rolling restart of node", zap.String("node-name", nodeName))
+		restartedNode, err := ln.restartNode(ctx, node, nodeConfig)
+		if err != nil {
+			return fmt.Errorf("failure restarting node %q: %w", nodeName, err)
+		}
+		if err := ln.awaitNodeHealthy(ctx, restartedNode); err != nil {
+			return fmt.Errorf("node %q did not become healthy after restart: %w", nodeName, err)
+		}
+	}
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) TrackSubnet(ctx context.Context, nodeName string, subnetID ids.ID) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("node %q not found", nodeName)
+	}
+	trackedSubnets, err := node.GetTrackedSubnets(ctx)
+	if err != nil {
+		return fmt.Errorf("failure getting tracked subnets of node %q: %w", nodeName, err)
+	}
+	whitelistedSubnetIDs := []string{}
+	for _, trackedSubnet := range trackedSubnets {
+		if trackedSubnet == subnetID {
+			return nil
+		}
+		whitelistedSubnetIDs = append(whitelistedSubnetIDs, trackedSubnet.String())
+	}
+	whitelistedSubnetIDs = append(whitelistedSubnetIDs, subnetID.String())
+	sort.Strings(whitelistedSubnetIDs)
+
+	ctx, cancel := ln.newStopAwareContext(ctx)
+	defer cancel()
+
+	nodeConfig := node.getConfig()
+	nodeConfig.Flags[config.WhitelistedSubnetsKey] = strings.Join(whitelistedSubnetIDs, ",")
+	ln.log.Info("restarting node to track subnet", zap.String("node-name", nodeName), zap.String("subnet-ID", subnetID.String()))
+	restartedNode, err := ln.restartNode(ctx, node, nodeConfig)
+	if err != nil {
+		return fmt.Errorf("failure restarting node %q: %w", nodeName, err)
+	}
+	if err := ln.awaitNodeHealthy(ctx, restartedNode); err != nil {
+		return fmt.Errorf("node %q did not become healthy after restart: %w", nodeName, err)
+	}
 	return nil
 }
 
@@ -812,13 +2089,14 @@ func (ln *localNetwork) setNodeName(nodeConfig *node.Config) error {
 }
 
 type buildFlagsReturn struct {
-	flags    []string
-	apiPort  uint16
-	p2pPort  uint16
-	dbDir    string
-	logsDir  string
-	buildDir string
-	httpHost string
+	flags      []string
+	apiPort    uint16
+	p2pPort    uint16
+	dbDir      string
+	logsDir    string
+	profileDir string
+	buildDir   string
+	httpHost   string
 }
 
 // buildFlags returns the:
@@ -857,15 +2135,32 @@ func (ln *localNetwork) buildFlags(
 		return buildFlagsReturn{}, err
 	}
 
-	// Use random free API port unless given in config file
-	apiPort, err := getPort(nodeConfig.Flags, configFile, config.HTTPPortKey)
+	// Tell the node to write the profiles to [nodeDir/profiles] unless given in config file,
+	// instead of a dir shared by all the nodes
+	profileDir, err := getConfigEntry(nodeConfig.Flags, configFile, config.ProfileDirKey, filepath.Join(nodeDir, defaultProfilesSubdir))
+	if err != nil {
+		return buildFlagsReturn{}, err
+	}
+
+	// ports of the other nodes, that may not be bound yet
+	usedPorts := map[uint16]struct{}{}
+	for _, node := range ln.nodes {
+		usedPorts[node.GetAPIPort()] = struct{}{}
+		usedPorts[node.GetP2PPort()] = struct{}{}
+	}
+	allocatePort := func() (uint16, error) {
+		return getFreePort(ln.minPort, ln.maxPort, usedPorts)
+	}
+
+	// Use random free API port unless given in node config, flags or config file
+	apiPort, err := getPort(nodeConfig.Flags, configFile, config.HTTPPortKey, nodeConfig.APIPort, usedPorts, allocatePort)
 	if err != nil {
 		return buildFlagsReturn{}, err
 	}
+	usedPorts[apiPort] = struct{}{}
 
-	// Use a random free P2P (staking) port unless given in config file
-	// Use random free API port unless given in config file
-	p2pPort, err := getPort(nodeConfig.Flags, configFile, config.StakingPortKey)
+	// Use a random free P2P (staking) port unless given in node config, flags or config file
+	p2pPort, err := getPort(nodeConfig.Flags, configFile, config.StakingPortKey, nodeConfig.P2PPort, usedPorts, allocatePort)
 	if err != nil {
 		return buildFlagsReturn{}, err
 	}
@@ -875,6 +2170,7 @@ func (ln *localNetwork) buildFlags(
 		fmt.Sprintf("--%s=%d", config.NetworkNameKey, ln.networkID),
 		fmt.Sprintf("--%s=%s", config.DBPathKey, dbDir),
 		fmt.Sprintf("--%s=%s", config.LogsDirKey, logsDir),
+		fmt.Sprintf("--%s=%s", config.ProfileDirKey, profileDir),
 		fmt.Sprintf("--%s=%d", config.HTTPPortKey, apiPort),
 		fmt.Sprintf("--%s=%d", config.StakingPortKey, p2pPort),
 		fmt.Sprintf("--%s=%s", config.BootstrapIPsKey, ln.bootstraps.IPsArg()),
@@ -888,6 +2184,12 @@ func (ln *localNetwork) buildFlags(
 	}
 	flags = append(flags, fileFlags...)
 
+	// flags set by the runner itself, that extra args can't change
+	managedFlags := map[string]struct{}{}
+	for _, flag := range flags {
+		managedFlags[node.ExtraArgName(flag)] = struct{}{}
+	}
+
 	// Add flags given in node config.
 	// Note these will overwrite existing flags if the same flag is given twice.
 	for flagName, flagVal := range nodeConfig.Flags {
@@ -897,13 +2199,26 @@ func (ln *localNetwork) buildFlags(
 		flags = append(flags, fmt.Sprintf("--%s=%v", flagName, flagVal))
 	}
 
+	// Add extra args given in node config, last so they take precedence
+	// over the node config flags.
+	if err := node.ValidateExtraArgs(nodeConfig.ExtraArgs); err != nil {
+		return buildFlagsReturn{}, err
+	}
+	for _, arg := range nodeConfig.ExtraArgs {
+		if _, ok := managedFlags[node.ExtraArgName(arg)]; ok {
+			return buildFlagsReturn{}, fmt.Errorf("extra arg %q sets flag %q which is managed by the network runner", arg, node.ExtraArgName(arg))
+		}
+		flags = append(flags, arg)
+	}
+
 	return buildFlagsReturn{
-		flags:    flags,
-		apiPort:  apiPort,
-		p2pPort:  p2pPort,
-		dbDir:    dbDir,
-		logsDir:  logsDir,
-		buildDir: buildDir,
-		httpHost: httpHost,
+		flags:      flags,
+		apiPort:    apiPort,
+		p2pPort:    p2pPort,
+		dbDir:      dbDir,
+		logsDir:    logsDir,
+		profileDir: profileDir,
+		buildDir:   buildDir,
+		httpHost:   httpHost,
 	}, nil
 }
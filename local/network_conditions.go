@@ -0,0 +1,241 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/avalanche-network-runner/network"
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+	"go.uber.org/zap"
+)
+
+// The network conditions between two nodes are injected with tc netem.
+// All the nodes communicate through the loopback interface, so the traffic
+// between two of them is told apart by the ports of their connections.
+const (
+	netConditionsDev = "lo"
+	// handle of the root htb qdisc, under which there is a class with a netem
+	// qdisc for each node pair. Unclassified traffic is not shaped.
+	netConditionsRoot = "1:"
+	// rate of the classes, high enough not to limit the loopback traffic
+	netConditionsClassRate = "100gbit"
+	maxLossPct             = 100
+)
+
+// tc setup applying the network conditions to the traffic between two nodes
+type netConditionsPair struct {
+	// minor ID of the htb class of the pair
+	classID uint16
+	// priorities of the filters sending the traffic of the pair to its class
+	filterPrios []uint16
+}
+
+// TCP connection between two nodes
+type nodeConnection struct {
+	ipv6      bool
+	localPort uint16
+	// staking port of the dialed node
+	remotePort uint16
+}
+
+// See network.Network
+func (ln *localNetwork) SetNetworkConditions(
+	ctx context.Context,
+	nodeNameA string,
+	nodeNameB string,
+	latency time.Duration,
+	lossPct float64,
+) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	if nodeNameA == nodeNameB {
+		return fmt.Errorf("can't set network conditions between node %q and itself", nodeNameA)
+	}
+	if latency < 0 {
+		return fmt.Errorf("negative latency %s", latency)
+	}
+	if lossPct < 0 || lossPct > maxLossPct {
+		return fmt.Errorf("packet loss percentage %g not in [0, %d]", lossPct, maxLossPct)
+	}
+	nodeA, ok := ln.nodes[nodeNameA]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeNameA)
+	}
+	nodeB, ok := ln.nodes[nodeNameB]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeNameB)
+	}
+
+	// the same conditions apply in both directions
+	pairNames := []string{nodeNameA, nodeNameB}
+	sort.Strings(pairNames)
+	pairKey := strings.Join(pairNames, "/")
+	if pair, ok := ln.netConditions[pairKey]; ok {
+		if err := runTCCmds(ctx, netConditionsClearCmds(pair)); err != nil {
+			return fmt.Errorf("couldn't clear network conditions between nodes %q and %q: %w", nodeNameA, nodeNameB, err)
+		}
+		delete(ln.netConditions, pairKey)
+	}
+	if latency == 0 && lossPct == 0 {
+		return nil
+	}
+
+	conns, err := getNodeConnections(ctx, nodeA, nodeB)
+	if err != nil {
+		return err
+	}
+	if len(conns) == 0 {
+		return fmt.Errorf("nodes %q and %q are not connected", nodeNameA, nodeNameB)
+	}
+	if ln.netConditions == nil {
+		if err := runTC(ctx, "qdisc", "add", "dev", netConditionsDev, "root", "handle", netConditionsRoot, "htb"); err != nil {
+			return fmt.Errorf("couldn't add root qdisc for network conditions: %w", err)
+		}
+		ln.netConditions = map[string]netConditionsPair{}
+	}
+	ln.nextNetConditionsClass++
+	pair := netConditionsPair{classID: ln.nextNetConditionsClass}
+	cmds := netConditionsCmds(&pair, conns, latency, lossPct)
+	if err := runTCCmds(ctx, cmds); err != nil {
+		_ = runTCCmds(ctx, netConditionsClearCmds(pair))
+		return fmt.Errorf("couldn't set network conditions between nodes %q and %q: %w", nodeNameA, nodeNameB, err)
+	}
+	ln.netConditions[pairKey] = pair
+	ln.log.Info(
+		"set network conditions",
+		zap.String("node-a", nodeNameA),
+		zap.String("node-b", nodeNameB),
+		zap.Duration("latency", latency),
+		zap.Float64("loss-pct", lossPct),
+	)
+	return nil
+}
+
+// Assumes [ln.lock] is held.
+// Removes all the network conditions set.
+func (ln *localNetwork) clearNetworkConditions(ctx context.Context) error {
+	if ln.netConditions == nil {
+		return nil
+	}
+	// removes the classes, netem qdiscs and filters under it
+	if err := runTC(ctx, "qdisc", "del", "dev", netConditionsDev, "root", "handle", netConditionsRoot); err != nil {
+		return fmt.Errorf("couldn't remove network conditions: %w", err)
+	}
+	ln.netConditions = nil
+	return nil
+}
+
+// Returns the tc commands sending the traffic of [conns], in both directions,
+// through a netem qdisc adding [latency] and [lossPct] to each packet.
+// Sets the filter priorities of [pair].
+func netConditionsCmds(pair *netConditionsPair, conns []nodeConnection, latency time.Duration, lossPct float64) [][]string {
+	classID := fmt.Sprintf("%s%x", netConditionsRoot, pair.classID)
+	cmds := [][]string{
+		{"class", "add", "dev", netConditionsDev, "parent", netConditionsRoot, "classid", classID, "htb", "rate", netConditionsClassRate},
+		{
+			"qdisc", "add", "dev", netConditionsDev, "parent", classID, "netem",
+			"delay", fmt.Sprintf("%dus", latency.Microseconds()),
+			"loss", fmt.Sprintf("%g%%", lossPct),
+		},
+	}
+	// filters of each protocol have their own priority
+	ipv4Prio, ipv6Prio := 2*pair.classID-1, 2*pair.classID
+	usedPrios := map[uint16]bool{}
+	for _, conn := range conns {
+		protocol, match, prio := "ip", "ip", ipv4Prio
+		if conn.ipv6 {
+			protocol, match, prio = "ipv6", "ip6", ipv6Prio
+		}
+		usedPrios[prio] = true
+		for _, ports := range [][2]uint16{{conn.localPort, conn.remotePort}, {conn.remotePort, conn.localPort}} {
+			cmds = append(cmds, []string{
+				"filter", "add", "dev", netConditionsDev, "parent", netConditionsRoot,
+				"protocol", protocol, "prio", fmt.Sprint(prio), "u32",
+				"match", match, "sport", fmt.Sprint(ports[0]), "0xffff",
+				"match", match, "dport", fmt.Sprint(ports[1]), "0xffff",
+				"flowid", classID,
+			})
+		}
+	}
+	pair.filterPrios = nil
+	for _, prio := range []uint16{ipv4Prio, ipv6Prio} {
+		if usedPrios[prio] {
+			pair.filterPrios = append(pair.filterPrios, prio)
+		}
+	}
+	return cmds
+}
+
+// Returns the tc commands removing the filters and class of [pair]
+func netConditionsClearCmds(pair netConditionsPair) [][]string {
+	cmds := [][]string{}
+	for _, prio := range pair.filterPrios {
+		cmds = append(cmds, []string{"filter", "del", "dev", netConditionsDev, "parent", netConditionsRoot, "prio", fmt.Sprint(prio)})
+	}
+	// also removes the netem qdisc of the class
+	classID := fmt.Sprintf("%s%x", netConditionsRoot, pair.classID)
+	return append(cmds, []string{"class", "del", "dev", netConditionsDev, "classid", classID})
+}
+
+// Runs each of [cmds] with tc, stopping at the first failure
+func runTCCmds(ctx context.Context, cmds [][]string) error {
+	for _, cmd := range cmds {
+		if err := runTC(ctx, cmd...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the established TCP connections between [nodeA] and [nodeB],
+// by the connections of their processes
+func getNodeConnections(ctx context.Context, nodeA *localNode, nodeB *localNode) ([]nodeConnection, error) {
+	connsA, err := getProcessConnections(ctx, nodeA)
+	if err != nil {
+		return nil, err
+	}
+	connsB, err := getProcessConnections(ctx, nodeB)
+	if err != nil {
+		return nil, err
+	}
+	return append(
+		dialedConnections(connsA, nodeB.GetP2PPort()),
+		dialedConnections(connsB, nodeA.GetP2PPort())...,
+	), nil
+}
+
+func getProcessConnections(ctx context.Context, node *localNode) ([]net.ConnectionStat, error) {
+	proc, err := process.NewProcessWithContext(ctx, int32(node.process.Pid()))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get process of node %q: %w", node.GetName(), err)
+	}
+	conns, err := proc.ConnectionsWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get connections of node %q: %w", node.GetName(), err)
+	}
+	return conns, nil
+}
+
+// Returns the established connections of [conns] to the staking port [p2pPort]
+func dialedConnections(conns []net.ConnectionStat, p2pPort uint16) []nodeConnection {
+	dialed := []nodeConnection{}
+	for _, conn := range conns {
+		if conn.Status != "ESTABLISHED" || conn.Raddr.Port != uint32(p2pPort) {
+			continue
+		}
+		dialed = append(dialed, nodeConnection{
+			ipv6:       strings.Contains(conn.Raddr.IP, ":"),
+			localPort:  uint16(conn.Laddr.Port),
+			remotePort: p2pPort,
+		})
+	}
+	return dialed
+}
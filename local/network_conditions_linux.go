@@ -0,0 +1,18 @@
+//go:build linux
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Runs tc with [args], which needs the CAP_NET_ADMIN capability
+func runTC(ctx context.Context, args ...string) error {
+	if out, err := exec.CommandContext(ctx, "tc", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("tc %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
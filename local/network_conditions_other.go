@@ -0,0 +1,10 @@
+//go:build !linux
+
+package local
+
+import "context"
+
+// tc netem is only available on Linux.
+func runTC(context.Context, ...string) error {
+	return ErrNetworkConditionsUnsupported
+}
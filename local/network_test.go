@@ -3,12 +3,23 @@ package local
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -19,16 +30,37 @@ import (
 	"github.com/ava-labs/avalanche-network-runner/network/node"
 	"github.com/ava-labs/avalanche-network-runner/network/node/status"
 	"github.com/ava-labs/avalanche-network-runner/utils"
+	"github.com/ava-labs/avalanchego/api/admin"
 	"github.com/ava-labs/avalanchego/api/health"
 	healthmocks "github.com/ava-labs/avalanchego/api/health/mocks"
+	"github.com/ava-labs/avalanchego/api/info"
 	"github.com/ava-labs/avalanchego/config"
+	"github.com/ava-labs/avalanchego/genesis"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/message"
+	"github.com/ava-labs/avalanchego/network/peer"
 	"github.com/ava-labs/avalanchego/snow/networking/router"
+	"github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/crypto"
+	"github.com/ava-labs/avalanchego/utils/formatting"
+	"github.com/ava-labs/avalanchego/utils/formatting/address"
+	avajson "github.com/ava-labs/avalanchego/utils/json"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/ava-labs/avalanchego/utils/rpc"
+	"github.com/ava-labs/avalanchego/utils/units"
+	"github.com/ava-labs/avalanchego/vms/components/avax"
+	"github.com/ava-labs/avalanchego/vms/platformvm"
+	platformvmstatus "github.com/ava-labs/avalanchego/vms/platformvm/status"
+	"github.com/ava-labs/avalanchego/vms/platformvm/txs"
+	"github.com/ava-labs/avalanchego/vms/platformvm/validator"
+	"github.com/ava-labs/avalanchego/vms/secp256k1fx"
+	"github.com/ava-labs/avalanchego/wallet/chain/p"
+	"github.com/ava-labs/avalanchego/wallet/subnet/primary"
+	"github.com/ava-labs/avalanchego/wallet/subnet/primary/common"
+	gopsutilnet "github.com/shirou/gopsutil/net"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
 )
 
 const defaultHealthyTimeout = 5 * time.Second
@@ -76,10 +108,11 @@ func (lt *localTestFlagCheckProcessCreator) NewNodeProcess(config node.Config, f
 // Returns an API client where:
 // * The Health API's Health method always returns healthy
 // * The CChainEthAPI's Close method may be called
-// * Only the above 2 methods may be called
+// * The Info API's IsBootstrapped method always returns true
+// * Only the above 3 methods may be called
 // TODO have this method return an API Client that has all
 // APIs and methods implemented
-func newMockAPISuccessful(ipAddr string, port uint16) api.Client {
+func newMockAPISuccessful(ipAddr string, port uint16, _ *http.Client) (api.Client, error) {
 	healthReply := &health.APIHealthReply{Healthy: true}
 	healthClient := &healthmocks.Client{}
 	healthClient.On("Health", mock.Anything).Return(healthReply, nil)
@@ -89,17 +122,49 @@ func newMockAPISuccessful(ipAddr string, port uint16) api.Client {
 	client := &apimocks.Client{}
 	client.On("HealthAPI").Return(healthClient)
 	client.On("CChainEthAPI").Return(ethClient)
-	return client
+	client.On("InfoAPI").Return(&bootstrappedInfoClient{bootstrapped: true})
+	return client, nil
+}
+
+// Info API client whose IsBootstrapped method always returns [bootstrapped] and [err],
+// and whose GetNodeVersion method returns [testNodeVersion].
+// Only IsBootstrapped and GetNodeVersion may be called.
+type bootstrappedInfoClient struct {
+	info.Client
+	bootstrapped bool
+	err          error
+}
+
+func (c *bootstrappedInfoClient) IsBootstrapped(context.Context, string, ...rpc.Option) (bool, error) {
+	return c.bootstrapped, c.err
+}
+
+var testNodeVersion = info.GetNodeVersionReply{Version: "avalanche/1.7.18", GitCommit: "0123456789abcdef"}
+
+func (*bootstrappedInfoClient) GetNodeVersion(context.Context, ...rpc.Option) (*info.GetNodeVersionReply, error) {
+	reply := testNodeVersion
+	return &reply, nil
+}
+
+// Info API client whose Peers method always returns [peers].
+// Only Peers may be called.
+type peersInfoClient struct {
+	info.Client
+	peers []info.Peer
+}
+
+func (c *peersInfoClient) Peers(context.Context, ...rpc.Option) ([]info.Peer, error) {
+	return c.peers, nil
 }
 
 // Returns an API client where the Health API's Health method always returns unhealthy
-func newMockAPIUnhealthy(ipAddr string, port uint16) api.Client {
+func newMockAPIUnhealthy(ipAddr string, port uint16, _ *http.Client) (api.Client, error) {
 	healthReply := &health.APIHealthReply{Healthy: false}
 	healthClient := &healthmocks.Client{}
 	healthClient.On("Health", mock.Anything).Return(healthReply, nil)
 	client := &apimocks.Client{}
 	client.On("HealthAPI").Return(healthClient)
-	return client
+	return client, nil
 }
 
 func newMockProcessUndef(node.Config, ...string) (NodeProcess, error) {
@@ -112,6 +177,7 @@ func newMockProcessSuccessful(node.Config, ...string) (NodeProcess, error) {
 	process.On("Wait").Return(nil)
 	process.On("Stop", mock.Anything).Return(0)
 	process.On("Status").Return(status.Running)
+	process.On("Exited").Return(make(<-chan struct{}))
 	return process, nil
 }
 
@@ -222,6 +288,74 @@ func TestNewNetworkFailToStartNode(t *testing.T) {
 	assert.Error(err)
 }
 
+// Returns an API client where the Health API's Health method always returns
+// unhealthy, and the Info API's IsBootstrapped method always returns [bootstrapped]
+func newMockAPIUnhealthyF(bootstrapped bool) api.NewAPIClientF {
+	return func(ipAddr string, port uint16, _ *http.Client) (api.Client, error) {
+		healthReply := &health.APIHealthReply{Healthy: false}
+		healthClient := &healthmocks.Client{}
+		healthClient.On("Health", mock.Anything).Return(healthReply, nil)
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("HealthAPI").Return(healthClient)
+		client.On("CChainEthAPI").Return(ethClient)
+		client.On("InfoAPI").Return(&bootstrappedInfoClient{bootstrapped: bootstrapped})
+		return client, nil
+	}
+}
+
+// Test that the network creation waits for its readiness mode
+func TestNewNetworkReadinessMode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		mode         network.ReadinessMode
+		bootstrapped bool
+		shouldErr    bool
+	}{
+		{name: "none", mode: network.ReadinessNone},
+		{name: "bootstrapped", mode: network.ReadinessBootstrapped, bootstrapped: true},
+		{name: "not bootstrapped", mode: network.ReadinessBootstrapped, shouldErr: true},
+		{name: "not healthy", mode: network.ReadinessHealthy, bootstrapped: true, shouldErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			networkConfig := testNetworkConfig(t)
+			net, err := newNetwork(
+				logging.NoLog{},
+				newMockAPIUnhealthyF(tt.bootstrapped),
+				&localTestSuccessfulNodeProcessCreator{},
+				"",
+				"",
+			)
+			assert.NoError(err)
+			err = net.loadConfig(
+				context.Background(),
+				networkConfig,
+				network.WithReadinessMode(tt.mode),
+				network.WithReadinessTimeout(500*time.Millisecond),
+			)
+			if tt.shouldErr {
+				assert.Error(err)
+				// the nodes are stopped if they don't become ready
+				names, err := net.GetNodeNames()
+				assert.NoError(err)
+				assert.Len(names, 0)
+				return
+			}
+			assert.NoError(err)
+			names, err := net.GetNodeNames()
+			assert.NoError(err)
+			assert.Len(names, len(networkConfig.NodeConfigs))
+			assert.NoError(net.Stop(context.Background()))
+		})
+	}
+}
+
 // Check configs that are expected to be invalid at network creation time
 func TestWrongNetworkConfigs(t *testing.T) {
 	t.Parallel()
@@ -674,6 +808,9 @@ func TestStoppedNetwork(t *testing.T) {
 	assert.EqualValues(awaitNetworkHealthy(net, defaultHealthyTimeout), network.ErrStopped)
 	_, err = net.GetAllNodes()
 	assert.EqualValues(err, network.ErrStopped)
+	// data is preserved after stop
+	_, err = os.Stat(filepath.Join(net.GetRootDir(), networkConfig.NodeConfigs[0].Name))
+	assert.NoError(err)
 }
 
 func TestGetAllNodes(t *testing.T) {
@@ -1047,12 +1184,18 @@ func TestGetConfigEntry(t *testing.T) {
 func TestGetPort(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
+	allocatePort := func() (uint16, error) {
+		return getFreePort(defaultMinPort, defaultMaxPort, nil)
+	}
 
 	// Case: port key present in config file
 	port, err := getPort(
 		map[string]interface{}{},
 		map[string]interface{}{"flag": float64(13)},
 		"flag",
+		0,
+		nil,
+		allocatePort,
 	)
 	assert.NoError(err)
 	assert.Equal(uint16(13), port)
@@ -1062,6 +1205,9 @@ func TestGetPort(t *testing.T) {
 		map[string]interface{}{"flag": 13},
 		map[string]interface{}{},
 		"flag",
+		0,
+		nil,
+		allocatePort,
 	)
 	assert.NoError(err)
 	assert.Equal(uint16(13), port)
@@ -1071,6 +1217,9 @@ func TestGetPort(t *testing.T) {
 		map[string]interface{}{"flag": 13},
 		map[string]interface{}{"flag": float64(14)},
 		"flag",
+		0,
+		nil,
+		allocatePort,
 	)
 	assert.NoError(err)
 	assert.Equal(uint16(13), port)
@@ -1080,8 +1229,95 @@ func TestGetPort(t *testing.T) {
 		map[string]interface{}{},
 		map[string]interface{}{},
 		"flag",
+		0,
+		nil,
+		allocatePort,
+	)
+	assert.NoError(err)
+
+	// Case: requested port takes precedence
+	port, err = getPort(
+		map[string]interface{}{"flag": 13},
+		map[string]interface{}{"flag": float64(14)},
+		"flag",
+		15,
+		nil,
+		allocatePort,
+	)
+	assert.NoError(err)
+	assert.Equal(uint16(15), port)
+
+	// Case: port not present is allocated
+	port, err = getPort(
+		map[string]interface{}{},
+		map[string]interface{}{},
+		"flag",
+		0,
+		nil,
+		func() (uint16, error) { return 16, nil },
+	)
+	assert.NoError(err)
+	assert.Equal(uint16(16), port)
+
+	// Case: requested port used by another node
+	_, err = getPort(
+		map[string]interface{}{},
+		map[string]interface{}{},
+		"flag",
+		17,
+		map[uint16]struct{}{17: {}},
+		allocatePort,
+	)
+	assert.ErrorIs(err, ErrPortInUse)
+
+	// Case: requested port already bound
+	l, err := net.Listen("tcp", ":0")
+	assert.NoError(err)
+	defer l.Close()
+	_, err = getPort(
+		map[string]interface{}{},
+		map[string]interface{}{},
+		"flag",
+		uint16(l.Addr().(*net.TCPAddr).Port),
+		nil,
+		allocatePort,
 	)
+	assert.ErrorIs(err, ErrPortInUse)
+}
+
+func TestPortRange(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.MinPort = 20000
+	networkConfig.MaxPort = 20100
+	// don't take ports from the default node configs
+	for i := range networkConfig.NodeConfigs {
+		networkConfig.NodeConfigs[i].ConfigFile = ""
+		delete(networkConfig.NodeConfigs[i].Flags, config.HTTPPortKey)
+		delete(networkConfig.NodeConfigs[i].Flags, config.StakingPortKey)
+	}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
 	assert.NoError(err)
+	usedPorts := map[uint16]struct{}{}
+	for _, node := range net.nodes {
+		for _, port := range []uint16{node.GetAPIPort(), node.GetP2PPort()} {
+			assert.GreaterOrEqual(port, networkConfig.MinPort)
+			assert.LessOrEqual(port, networkConfig.MaxPort)
+			assert.NotContains(usedPorts, port)
+			usedPorts[port] = struct{}{}
+		}
+	}
+	// explicit ports
+	newNode, err := net.AddNode(node.Config{APIPort: 30000, P2PPort: 30001})
+	assert.NoError(err)
+	assert.EqualValues(30000, newNode.GetAPIPort())
+	assert.EqualValues(30001, newNode.GetP2PPort())
+	_, err = net.AddNode(node.Config{APIPort: 30000})
+	assert.ErrorIs(err, ErrPortInUse)
+	assert.NoError(net.Stop(context.Background()))
 }
 
 func TestCreateFileAndWrite(t *testing.T) {
@@ -1098,6 +1334,275 @@ func TestCreateFileAndWrite(t *testing.T) {
 	assert.Equal(contents, gotBytes)
 }
 
+// Records the flags each node process is started with
+type localTestArgsRecorderProcessCreator struct {
+	lock sync.Mutex
+	args map[string][]string
+}
+
+func (lt *localTestArgsRecorderProcessCreator) NewNodeProcess(config node.Config, flags ...string) (NodeProcess, error) {
+	lt.lock.Lock()
+	lt.args[config.Name] = flags
+	lt.lock.Unlock()
+	return newMockProcessSuccessful(config, flags...)
+}
+
+// The process of node [exitingNodeName] exits with [exitCode] once [exitCh] is closed
+type localTestExitingProcessCreator struct {
+	exitingNodeName string
+	exitCode        int
+	exitCh          chan struct{}
+}
+
+func (lt *localTestExitingProcessCreator) NewNodeProcess(config node.Config, flags ...string) (NodeProcess, error) {
+	if config.Name != lt.exitingNodeName {
+		return newMockProcessSuccessful(config, flags...)
+	}
+	exited := func() bool {
+		select {
+		case <-lt.exitCh:
+			return true
+		default:
+			return false
+		}
+	}
+	process := &mocks.NodeProcess{}
+	process.On("Stop", mock.Anything).Return(func(context.Context) int {
+		if exited() {
+			return lt.exitCode
+		}
+		return 0
+	})
+	process.On("Status").Return(func() status.Status {
+		if exited() {
+			return status.Stopped
+		}
+		return status.Running
+	})
+	process.On("Exited").Return((<-chan struct{})(lt.exitCh))
+	return process, nil
+}
+
+func TestNodeExited(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	processCreator := &localTestExitingProcessCreator{
+		exitingNodeName: "node1",
+		exitCode:        2,
+		exitCh:          make(chan struct{}),
+	}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, processCreator, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// nodes stopped by the network are not reported
+	assert.NoError(net.RemoveNode(context.Background(), "node2"))
+	select {
+	case exit := <-net.NodeExited():
+		assert.FailNow("unexpected node exit", exit.NodeName)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(processCreator.exitCh)
+	select {
+	case exit := <-net.NodeExited():
+		assert.Equal(network.NodeExit{NodeName: "node1", ExitCode: 2}, exit)
+	case <-time.After(10 * time.Second):
+		assert.FailNow("node exit not reported")
+	}
+	// waiting for the exited node to be healthy fails right away
+	start := time.Now()
+	err = net.awaitNodeHealthy(context.Background(), net.nodes["node1"])
+	assert.Error(err)
+	assert.Less(time.Since(start), healthCheckFreq)
+	// the exit code of the exited node is still reported on stop
+	err = net.Stop(context.Background())
+	assert.Error(err)
+	assert.Contains(err.Error(), "exit code: 2")
+}
+
+func TestExtraArgs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	processCreator := &localTestArgsRecorderProcessCreator{args: map[string][]string{}}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, processCreator, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	nodeConfig := node.Config{
+		Name:      "extra",
+		Flags:     map[string]interface{}{"experimental-flag": "from-flags"},
+		ExtraArgs: []string{"--experimental-flag=from-extra-args", "--experimental-bool-flag"},
+	}
+	_, err = net.AddNode(nodeConfig)
+	assert.NoError(err)
+	args := processCreator.args["extra"]
+	// extra args are appended last, after the node config flags
+	assert.Equal(nodeConfig.ExtraArgs, args[len(args)-2:])
+	assert.Contains(args, "--experimental-flag=from-flags")
+	// runner managed flags can't be changed
+	_, err = net.AddNode(node.Config{Name: "bad1", ExtraArgs: []string{"--db-dir=/tmp/db"}})
+	assert.Error(err)
+	_, err = net.AddNode(node.Config{Name: "bad2", ExtraArgs: []string{fmt.Sprintf("--%s=1", config.StakingPortKey)}})
+	assert.Error(err)
+	// not a flag
+	_, err = net.AddNode(node.Config{Name: "bad3", ExtraArgs: []string{"value"}})
+	assert.Error(err)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetNodeConfigFile(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	_, err = net.AddNode(node.Config{
+		Name:       "configured",
+		ConfigFile: `{"log-level":"debug","snow-sample-size":10}`,
+		Flags:      map[string]interface{}{config.LogLevelKey: "info"},
+	})
+	assert.NoError(err)
+	launchConfigBytes, err := net.GetNodeConfigFile("configured")
+	assert.NoError(err)
+	var launchConfig map[string]interface{}
+	assert.NoError(json.Unmarshal(launchConfigBytes, &launchConfig))
+	// config file entries are overridden by the flags
+	assert.Equal(10.0, launchConfig["snow-sample-size"])
+	assert.Equal("info", launchConfig[config.LogLevelKey])
+	// flags set by the runner are included
+	node := net.nodes["configured"]
+	assert.Equal(fmt.Sprint(node.GetAPIPort()), launchConfig[config.HTTPPortKey])
+	assert.Equal(fmt.Sprint(node.GetP2PPort()), launchConfig[config.StakingPortKey])
+	assert.Equal(filepath.Join(net.rootDir, "configured", configFileName), launchConfig[config.ConfigFileKey])
+	_, err = net.GetNodeConfigFile("unknown")
+	assert.ErrorIs(err, network.ErrNodeNotFound)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetNodeConfigFile("configured")
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestLaunchConfig(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	launchConfigBytes, err := launchConfig(
+		map[string]interface{}{"log-level": "debug", "http-port": 9650.0},
+		[]string{"--http-port=9652", "--api-admin-enabled"},
+	)
+	assert.NoError(err)
+	var config map[string]interface{}
+	assert.NoError(json.Unmarshal(launchConfigBytes, &config))
+	assert.Equal(map[string]interface{}{
+		"log-level":         "debug",
+		"http-port":         "9652",
+		"api-admin-enabled": true,
+	}, config)
+}
+
+func TestSetSubnetConfigs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Error(validateSetupOp(network.NewSetupOp(network.WithSubnetConfig([]byte("{invalid")))))
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	subnetID := ids.GenerateTestID()
+	subnetConfig := `{"validatorOnly":true}`
+	// no subnet config, no subnet config dir
+	net.setSubnetConfigs([]ids.ID{subnetID}, network.NewSetupOp())
+	assert.Empty(net.subnetConfigFiles)
+	net.setSubnetConfigs([]ids.ID{subnetID}, network.NewSetupOp(network.WithSubnetConfig([]byte(subnetConfig))))
+	// restarted and added nodes get the subnet config
+	nodeName := networkConfig.NodeConfigs[0].Name
+	restartedNode := net.nodes[nodeName]
+	_, err = net.restartNode(context.Background(), restartedNode, restartedNode.getConfig())
+	assert.NoError(err)
+	_, err = net.AddNode(node.Config{Name: "added"})
+	assert.NoError(err)
+	for _, nodeName := range []string{nodeName, "added"} {
+		subnetConfigPath := filepath.Join(net.rootDir, nodeName, subnetConfigSubDir, subnetID.String()+subnetConfigExt)
+		subnetConfigBytes, err := os.ReadFile(subnetConfigPath)
+		assert.NoError(err)
+		assert.Equal(subnetConfig, string(subnetConfigBytes))
+		assert.Equal(subnetConfig, net.nodes[nodeName].getConfig().SubnetConfigFiles[subnetID.String()])
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestResourceLimitValues(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Equal("150000 100000", cgroupCPUMax(1.5))
+	assert.Equal("50000 100000", cgroupCPUMax(0.5))
+	// the kernel rejects quotas under 1ms
+	assert.Equal("1000 100000", cgroupCPUMax(0.001))
+	assert.Equal("536870912", cgroupMemoryMax(512))
+}
+
+func TestNetworkConditionsCmds(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	pair := netConditionsPair{classID: 10}
+	conns := []nodeConnection{
+		{ipv6: true, localPort: 50000, remotePort: 9651},
+		{ipv6: true, localPort: 50001, remotePort: 9653},
+	}
+	cmds := netConditionsCmds(&pair, conns, 200*time.Millisecond, 1.5)
+	assert.Len(cmds, 6)
+	assert.Equal("class add dev lo parent 1: classid 1:a htb rate 100gbit", strings.Join(cmds[0], " "))
+	assert.Equal("qdisc add dev lo parent 1:a netem delay 200000us loss 1.5%", strings.Join(cmds[1], " "))
+	// both directions of each connection
+	assert.Equal(
+		"filter add dev lo parent 1: protocol ipv6 prio 20 u32 match ip6 sport 50000 0xffff match ip6 dport 9651 0xffff flowid 1:a",
+		strings.Join(cmds[2], " "),
+	)
+	assert.Equal(
+		"filter add dev lo parent 1: protocol ipv6 prio 20 u32 match ip6 sport 9651 0xffff match ip6 dport 50000 0xffff flowid 1:a",
+		strings.Join(cmds[3], " "),
+	)
+	assert.Equal([]uint16{20}, pair.filterPrios)
+	clearCmds := netConditionsClearCmds(pair)
+	assert.Equal([][]string{
+		{"filter", "del", "dev", "lo", "parent", "1:", "prio", "20"},
+		{"class", "del", "dev", "lo", "classid", "1:a"},
+	}, clearCmds)
+
+	// only the established connections to the given staking port
+	dialed := dialedConnections([]gopsutilnet.ConnectionStat{
+		{Status: "ESTABLISHED", Laddr: gopsutilnet.Addr{IP: "::1", Port: 50000}, Raddr: gopsutilnet.Addr{IP: "::1", Port: 9651}},
+		{Status: "ESTABLISHED", Laddr: gopsutilnet.Addr{IP: "127.0.0.1", Port: 50001}, Raddr: gopsutilnet.Addr{IP: "127.0.0.1", Port: 9651}},
+		{Status: "ESTABLISHED", Laddr: gopsutilnet.Addr{IP: "::1", Port: 50002}, Raddr: gopsutilnet.Addr{IP: "::1", Port: 9653}},
+		{Status: "LISTEN", Laddr: gopsutilnet.Addr{IP: "::1", Port: 9651}},
+	}, 9651)
+	assert.Equal([]nodeConnection{
+		{ipv6: true, localPort: 50000, remotePort: 9651},
+		{ipv6: false, localPort: 50001, remotePort: 9651},
+	}, dialed)
+}
+
+func TestSetNetworkConditionsValidation(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	assert.Error(net.SetNetworkConditions(context.Background(), "node0", "node0", time.Second, 0))
+	assert.Error(net.SetNetworkConditions(context.Background(), "node0", "node1", -time.Second, 0))
+	assert.Error(net.SetNetworkConditions(context.Background(), "node0", "node1", 0, 101))
+	assert.ErrorIs(net.SetNetworkConditions(context.Background(), "node0", "node3", time.Second, 0), network.ErrNodeNotFound)
+	// removing conditions never set does nothing
+	assert.NoError(net.SetNetworkConditions(context.Background(), "node0", "node1", 0, 0))
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.SetNetworkConditions(context.Background(), "node0", "node1", time.Second, 0), network.ErrStopped)
+}
+
 func TestWriteFiles(t *testing.T) {
 	t.Parallel()
 	stakingKey := "stakingKey"
@@ -1241,57 +1746,4015 @@ func TestRemoveBeacon(t *testing.T) {
 	assert.Equal(0, net.bootstraps.Len())
 }
 
-// Returns an API client where:
-// * The Health API's Health method always returns an error after the
-//   given context is cancelled.
-// * The CChainEthAPI's Close method may be called
-// * Only the above 2 methods may be called
-func newMockAPIHealthyBlocks(ipAddr string, port uint16) api.Client {
-	healthClient := &healthmocks.Client{}
-	healthClient.On("Health", mock.MatchedBy(func(_ context.Context) bool { return true }), mock.Anything).Return(
-		func(ctx context.Context, _ ...rpc.Option) *health.APIHealthReply {
-			<-ctx.Done()
-			return nil
-		},
-		func(ctx context.Context, _ ...rpc.Option) error {
-			<-ctx.Done()
-			return ctx.Err()
-		},
-	)
-	// ethClient used when removing nodes, to close websocket connection
-	ethClient := &apimocks.EthClient{}
-	ethClient.On("Close").Return()
-	client := &apimocks.Client{}
-	client.On("HealthAPI").Return(healthClient)
-	client.On("CChainEthAPI").Return(ethClient)
-	return client
+// Health API client whose Health method always returns healthy, after keeping
+// track of the maximum number of concurrent calls to it, shared by all the
+// clients with the same [inFlight] and [maxInFlight].
+type concurrencyHealthClient struct {
+	health.Client
+	inFlight    *int32
+	maxInFlight *int32
 }
 
-// Assert that if the network's Stop method is called while
-// a call to Healthy is ongoing, Healthy returns immediately.
-func TestHealthyDuringNetworkStop(t *testing.T) {
+func (c *concurrencyHealthClient) Health(context.Context, ...rpc.Option) (*health.APIHealthReply, error) {
+	inFlight := atomic.AddInt32(c.inFlight, 1)
+	defer atomic.AddInt32(c.inFlight, -1)
+	for {
+		maxInFlight := atomic.LoadInt32(c.maxInFlight)
+		if inFlight <= maxInFlight || atomic.CompareAndSwapInt32(c.maxInFlight, maxInFlight, inFlight) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return &health.APIHealthReply{Healthy: true}, nil
+}
+
+// Test that the health checks query at most the configured number of nodes at a time
+func TestHealthCheckConcurrency(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig, err := NewDefaultConfigNNodes("pepito", 5)
+	assert.NoError(err)
+	networkConfig.HealthCheckConcurrency = 2
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	assert.EqualValues(network.DefaultMaxConcurrency, net.healthCheckConcurrency)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	var inFlight, maxInFlight int32
+	for _, node := range net.nodes {
+		client := &apimocks.Client{}
+		client.On("HealthAPI").Return(&concurrencyHealthClient{inFlight: &inFlight, maxInFlight: &maxInFlight})
+		node.client = client
+	}
+	assert.NoError(net.Healthy(context.Background()))
+	assert.EqualValues(2, atomic.LoadInt32(&maxInFlight))
+}
+
+// Test that removing the last beacon makes another healthy node a beacon
+func TestRemoveLastBeacon(t *testing.T) {
+	t.Parallel()
 	assert := assert.New(t)
 	networkConfig := testNetworkConfig(t)
-	// Calls to a node's Healthy() function blocks until context cancelled
-	net, err := newNetwork(logging.NoLog{}, newMockAPIHealthyBlocks, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	for i := 1; i < len(networkConfig.NodeConfigs); i++ {
+		networkConfig.NodeConfigs[i].IsBeacon = false
+	}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
 	assert.NoError(err)
 	err = net.loadConfig(context.Background(), networkConfig)
 	assert.NoError(err)
+	assert.Equal(1, net.bootstraps.Len())
 
-	healthyChan := make(chan error)
-	go func() {
-		healthyChan <- net.Healthy(context.Background())
-	}()
-	// Wait to make sure we're actually blocking on Health API call
-	time.Sleep(500 * time.Millisecond)
-	err = net.Stop(context.Background())
+	// the first other healthy node by name replaces the beacon
+	net.nodes["node1"].client, err = newMockAPIUnhealthyF(true)("", 0, nil)
 	assert.NoError(err)
-	select {
-	case err := <-healthyChan:
-		assert.Error(err)
-	case <-time.After(1 * time.Second):
-		// Since [net.Stop] was called, [net.Healthy] should immediately return.
-		// We assume that it will do so within 1 second.
-		assert.Fail("Healthy should've returned immediately because network closed")
-	}
+	assert.NoError(net.RemoveNode(context.Background(), "node0"))
+	assert.Equal(1, net.bootstraps.Len())
+	assert.Equal(net.nodes["node2"].nodeID.String(), net.bootstraps.IDsArg())
+	assert.True(net.nodes["node2"].GetConfig().IsBeacon)
+	assert.False(net.nodes["node1"].GetConfig().IsBeacon)
+
+	// the last beacon is not removed if no other node is healthy
+	err = net.RemoveNode(context.Background(), "node2")
+	assert.ErrorIs(err, ErrNoBeacon)
+	_, err = net.GetNode("node2")
+	assert.NoError(err)
+	assert.Equal(1, net.bootstraps.Len())
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestUpgradeNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	binDir := t.TempDir()
+	newBinaryPath := filepath.Join(binDir, "avalanchego")
+	assert.NoError(os.WriteFile(newBinaryPath, nil, 0o755))
+	nonExecPath := filepath.Join(binDir, "not-executable")
+	assert.NoError(os.WriteFile(nonExecPath, nil, 0o644))
+
+	oldNode := net.nodes["node1"]
+	assert.ErrorIs(net.UpgradeNode(context.Background(), "node1", filepath.Join(binDir, "missing")), utils.ErrNotExists)
+	assert.ErrorIs(net.UpgradeNode(context.Background(), "node1", nonExecPath), utils.ErrNotExecutable)
+	assert.ErrorIs(net.UpgradeNode(context.Background(), "node3", newBinaryPath), network.ErrNodeNotFound)
+	// the node is not restarted if the binary is invalid
+	assert.Same(oldNode, net.nodes["node1"])
+
+	assert.NoError(net.UpgradeNode(context.Background(), "node1", newBinaryPath))
+	upgradedNode := net.nodes["node1"]
+	assert.NotSame(oldNode, upgradedNode)
+	assert.Equal(newBinaryPath, upgradedNode.GetBinaryPath())
+	assert.Equal(binDir, upgradedNode.GetConfig().Flags[config.BuildDirKey])
+	assert.Equal(oldNode.GetNodeID(), upgradedNode.GetNodeID())
+	assert.Equal(oldNode.GetDbDir(), upgradedNode.GetDbDir())
+	assert.Equal(oldNode.GetAPIPort(), upgradedNode.GetAPIPort())
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.UpgradeNode(context.Background(), "node1", newBinaryPath), network.ErrStopped)
+}
+
+func TestResetNodeState(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	assert.ErrorIs(net.ResetNodeState(context.Background(), "node3"), network.ErrNodeNotFound)
+
+	oldNode := net.nodes["node1"]
+	dbFile := filepath.Join(oldNode.GetDbDir(), constants.NetworkName(net.networkID), "000001.log")
+	assert.NoError(os.MkdirAll(filepath.Dir(dbFile), os.ModePerm))
+	assert.NoError(os.WriteFile(dbFile, []byte("db"), 0o600))
+	assert.NoError(net.ResetNodeState(context.Background(), "node1"))
+	resetNode := net.nodes["node1"]
+	assert.NotSame(oldNode, resetNode)
+	assert.NoFileExists(dbFile)
+	assert.Equal(oldNode.GetNodeID(), resetNode.GetNodeID())
+	assert.Equal(oldNode.GetDbDir(), resetNode.GetDbDir())
+	assert.Equal(oldNode.GetAPIPort(), resetNode.GetAPIPort())
+	assert.Equal(oldNode.GetP2PPort(), resetNode.GetP2PPort())
+
+	// the node is not stopped if there is no other beacon to bootstrap from
+	for nodeName, node := range net.nodes {
+		if nodeName != "node1" {
+			node.config.IsBeacon = false
+		}
+	}
+	assert.ErrorIs(net.ResetNodeState(context.Background(), "node1"), ErrNoBeacon)
+	assert.Same(resetNode, net.nodes["node1"])
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.ResetNodeState(context.Background(), "node1"), network.ErrStopped)
+}
+
+func TestGetNodeByID(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	for nodeName, node := range net.nodes {
+		gotNode, err := net.GetNodeByID(node.GetNodeID())
+		assert.NoError(err)
+		assert.Equal(nodeName, gotNode.GetName())
+	}
+	_, err = net.GetNodeByID(ids.GenerateTestNodeID())
+	assert.ErrorIs(err, network.ErrNodeNotFound)
+	nodeID := net.nodes["node0"].GetNodeID()
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetNodeByID(nodeID)
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+// Test that a node stopped with StopNode while being waited for, without holding
+// the network lock, ends the wait
+func TestStopNodeWhileAwaitingHealthy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPIUnhealthyF(true), &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- net.awaitNodeHealthy(context.Background(), net.nodes["node1"])
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(net.StopNode(context.Background(), "node1"))
+	select {
+	case err := <-errCh:
+		assert.ErrorIs(err, ErrNodeStopped)
+	case <-time.After(5 * healthCheckFreq):
+		assert.Fail("stopped node still waited for")
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestStopStartNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	stoppedNode := net.nodes["node1"]
+	nodeConfig := stoppedNode.GetConfig()
+
+	// a stopped node stays in the network, reported as unhealthy
+	assert.NoError(net.StopNode(context.Background(), "node1"))
+	_, err = net.GetNode("node1")
+	assert.NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err = net.Healthy(ctx)
+	cancel()
+	assert.ErrorIs(err, ErrNodeStopped)
+	// it is not a beacon for the nodes added meanwhile, and is not queried
+	assert.Equal(len(networkConfig.NodeConfigs)-1, net.bootstraps.Len())
+	assert.NotContains(net.runningNodes(), "node1")
+	for i := 0; i < 10; i++ {
+		assert.NotEqual("node1", net.getSomeNode().GetName())
+	}
+	assert.ErrorIs(net.StopNode(context.Background(), "node1"), ErrNodeStopped)
+	assert.Error(net.StartNode(context.Background(), "node0"))
+	assert.Error(net.StopNode(context.Background(), "node3"))
+
+	// it is started again with the same config, ports and data dir
+	assert.NoError(net.StartNode(context.Background(), "node1"))
+	startedNode := net.nodes["node1"]
+	assert.NotSame(stoppedNode, startedNode)
+	assert.Zero(atomic.LoadUint32(&startedNode.stopped))
+	assert.Equal(stoppedNode.GetNodeID(), startedNode.GetNodeID())
+	assert.Equal(stoppedNode.GetAPIPort(), startedNode.GetAPIPort())
+	assert.Equal(stoppedNode.GetP2PPort(), startedNode.GetP2PPort())
+	assert.Equal(stoppedNode.GetDbDir(), startedNode.GetDbDir())
+	assert.Equal(nodeConfig.IsBeacon, startedNode.GetConfig().IsBeacon)
+	assert.Equal(len(networkConfig.NodeConfigs), net.bootstraps.Len())
+	assert.NoError(net.Healthy(context.Background()))
+
+	// a node whose process exited with a non zero exit code is also started again
+	process := &mocks.NodeProcess{}
+	process.On("Stop", mock.Anything).Return(1)
+	process.On("Status").Return(status.Stopped)
+	net.nodes["node2"].process = process
+	assert.Error(net.StopNode(context.Background(), "node2"))
+	assert.NoError(net.StartNode(context.Background(), "node2"))
+	assert.Contains(net.nodes, "node2")
+	assert.NotSame(process, net.nodes["node2"].process)
+	assert.Zero(atomic.LoadUint32(&net.nodes["node2"].stopped))
+	assert.NoError(net.Healthy(context.Background()))
+
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.StopNode(context.Background(), "node1"), network.ErrStopped)
+	assert.ErrorIs(net.StartNode(context.Background(), "node1"), network.ErrStopped)
+}
+
+// Returns an API client where:
+// * The Health API's Health method always returns an error after the
+//   given context is cancelled.
+// * The CChainEthAPI's Close method may be called
+// * Only the above 2 methods may be called
+func newMockAPIHealthyBlocks(ipAddr string, port uint16, _ *http.Client) (api.Client, error) {
+	healthClient := &healthmocks.Client{}
+	healthClient.On("Health", mock.MatchedBy(func(_ context.Context) bool { return true }), mock.Anything).Return(
+		func(ctx context.Context, _ ...rpc.Option) *health.APIHealthReply {
+			<-ctx.Done()
+			return nil
+		},
+		func(ctx context.Context, _ ...rpc.Option) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	)
+	// ethClient used when removing nodes, to close websocket connection
+	ethClient := &apimocks.EthClient{}
+	ethClient.On("Close").Return()
+	client := &apimocks.Client{}
+	client.On("HealthAPI").Return(healthClient)
+	client.On("CChainEthAPI").Return(ethClient)
+	return client, nil
+}
+
+// Check that concurrent Stop calls stop the network once, and that the
+// callers not stopping it get ErrStopped after the nodes are removed
+func TestConcurrentStop(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	numCallers := 10
+	errCh := make(chan error, numCallers)
+	var wg sync.WaitGroup
+	for i := 0; i < numCallers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := net.Stop(context.Background())
+			if err != nil {
+				// the network is already stopped when the error is returned
+				net.lock.RLock()
+				assert.Empty(net.nodes)
+				net.lock.RUnlock()
+			}
+			errCh <- err
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	numStopped := 0
+	for err := range errCh {
+		if err == nil {
+			numStopped++
+			continue
+		}
+		assert.ErrorIs(err, network.ErrStopped)
+	}
+	assert.Equal(1, numStopped)
+	assert.ErrorIs(net.Stop(context.Background()), network.ErrStopped)
+}
+
+// Assert that if the network's Stop method is called while
+// a call to Healthy is ongoing, Healthy returns immediately.
+func TestHealthyDuringNetworkStop(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	// Calls to a node's Healthy() function blocks until context cancelled
+	net, err := newNetwork(logging.NoLog{}, newMockAPIHealthyBlocks, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+
+	healthyChan := make(chan error)
+	go func() {
+		healthyChan <- net.Healthy(context.Background())
+	}()
+	// Wait to make sure we're actually blocking on Health API call
+	time.Sleep(500 * time.Millisecond)
+	err = net.Stop(context.Background())
+	assert.NoError(err)
+	select {
+	case err := <-healthyChan:
+		assert.Error(err)
+	case <-time.After(1 * time.Second):
+		// Since [net.Stop] was called, [net.Healthy] should immediately return.
+		// We assume that it will do so within 1 second.
+		assert.Fail("Healthy should've returned immediately because network closed")
+	}
+}
+
+func TestHealthyMinDuration(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// node0 is unhealthy on its second check, which restarts the duration
+	flappingNode := net.nodes["node0"]
+	healthClient := &healthmocks.Client{}
+	healthClient.On("Health", mock.Anything).Return(&health.APIHealthReply{Healthy: true}, nil).Once()
+	healthClient.On("Health", mock.Anything).Return(&health.APIHealthReply{Healthy: false}, nil).Once()
+	healthClient.On("Health", mock.Anything).Return(&health.APIHealthReply{Healthy: true}, nil)
+	ethClient := &apimocks.EthClient{}
+	ethClient.On("Close").Return()
+	client := &apimocks.Client{}
+	client.On("HealthAPI").Return(healthClient)
+	client.On("CChainEthAPI").Return(ethClient)
+	flappingNode.client = client
+	start := time.Now()
+	err = net.Healthy(context.Background(), network.WithMinHealthyDuration(time.Second))
+	assert.NoError(err)
+	assert.GreaterOrEqual(time.Since(start), 2*time.Second)
+	healthClient.AssertNumberOfCalls(t, "Health", 4)
+	// a network unhealthy during the whole duration times out
+	flappingNode.client, err = newMockAPIUnhealthy(flappingNode.GetURL(), flappingNode.GetAPIPort(), nil)
+	assert.NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = net.Healthy(ctx, network.WithMinHealthyDuration(time.Second))
+	assert.Error(err)
+	flappingNode.client = client
+	assert.NoError(net.Stop(context.Background()))
+}
+
+// Assert that RollingRestart restarts all nodes, keeping their ports
+// and applying the given config changes
+func TestRollingRestart(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	apiPorts := map[string]uint16{}
+	for name, node := range net.nodes {
+		apiPorts[name] = node.GetAPIPort()
+	}
+	// the nodes were created without chain configs
+	err = net.RollingRestart(context.Background(), &node.Config{
+		Flags: map[string]interface{}{
+			"rolling-restart-flag": "value",
+		},
+		ChainConfigFiles: map[string]string{"C": `{"log-level":"debug"}`},
+	})
+	assert.NoError(err)
+	assert.Len(net.nodes, len(networkConfig.NodeConfigs))
+	for name, node := range net.nodes {
+		assert.EqualValues(apiPorts[name], node.GetAPIPort())
+		assert.EqualValues("value", node.GetConfig().Flags["rolling-restart-flag"])
+		assert.Equal(`{"log-level":"debug"}`, node.GetConfig().ChainConfigFiles["C"])
+	}
+	assert.NoError(net.Stop(context.Background()))
+	assert.EqualValues(network.ErrStopped, net.RollingRestart(context.Background(), nil))
+}
+
+func TestRestart(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	oldNodes := map[string]*localNode{}
+	for name, node := range net.nodes {
+		oldNodes[name] = node
+	}
+	assert.NoError(net.Restart(context.Background()))
+	assert.Len(net.nodes, len(oldNodes))
+	for name, node := range net.nodes {
+		oldNode := oldNodes[name]
+		assert.NotSame(oldNode.process, node.process)
+		assert.Equal(oldNode.GetNodeID(), node.GetNodeID())
+		assert.EqualValues(oldNode.GetAPIPort(), node.GetAPIPort())
+		assert.EqualValues(oldNode.GetP2PPort(), node.GetP2PPort())
+		assert.Equal(oldNode.GetDbDir(), node.GetDbDir())
+		assert.Equal(oldNode.GetConfig().IsBeacon, node.GetConfig().IsBeacon)
+	}
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.Restart(context.Background()), network.ErrStopped)
+}
+
+// Creates node processes exiting with the code given for the name of their node,
+// only for the first process of each node, and otherwise succeeding
+type exitCodeProcessCreator struct {
+	lock      sync.Mutex
+	exitCodes map[string]int
+}
+
+func (c *exitCodeProcessCreator) NewNodeProcess(config node.Config, flags ...string) (NodeProcess, error) {
+	c.lock.Lock()
+	exitCode, ok := c.exitCodes[config.Name]
+	delete(c.exitCodes, config.Name)
+	c.lock.Unlock()
+	if !ok {
+		return newMockProcessSuccessful(config, flags...)
+	}
+	process := &mocks.NodeProcess{}
+	process.On("Wait").Return(nil)
+	process.On("Stop", mock.Anything).Return(exitCode)
+	process.On("Status").Return(status.Running)
+	process.On("Exited").Return(make(<-chan struct{}))
+	return process, nil
+}
+
+// Test that a node exiting with an error, e.g. after crashing, doesn't stop a restart
+func TestRestartCrashedNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	processCreator := &exitCodeProcessCreator{exitCodes: map[string]int{"node0": 1}}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, processCreator, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	oldProcesses := map[string]NodeProcess{}
+	for name, node := range net.nodes {
+		oldProcesses[name] = node.process
+	}
+	assert.NoError(net.Restart(context.Background()))
+	assert.Len(net.nodes, len(oldProcesses))
+	for name, node := range net.nodes {
+		assert.NotSame(oldProcesses[name], node.process)
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+// Test that the restarts leave the nodes stopped with StopNode stopped, saving the
+// config changes StartNode applies to them, and that the VM plugins are not
+// reloaded on them
+func TestRestartsWithStoppedNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	assert.NoError(net.StopNode(context.Background(), "node1"))
+	stoppedProcess := net.nodes["node1"].process
+	// Returns the processes of the nodes, checking that node1 is still stopped
+	processes := func() map[string]NodeProcess {
+		assert.Len(net.nodes, len(networkConfig.NodeConfigs))
+		assert.Equal(uint32(1), atomic.LoadUint32(&net.nodes["node1"].stopped))
+		assert.Same(stoppedProcess, net.nodes["node1"].process)
+		processes := map[string]NodeProcess{}
+		for nodeName, node := range net.nodes {
+			processes[nodeName] = node.process
+		}
+		return processes
+	}
+	// Asserts that the running nodes are restarted since [oldProcesses]
+	assertRestarted := func(oldProcesses map[string]NodeProcess) {
+		for nodeName, node := range net.nodes {
+			if nodeName != "node1" {
+				assert.NotSame(oldProcesses[nodeName], node.process)
+			}
+		}
+	}
+
+	oldProcesses := processes()
+	assert.NoError(net.Restart(context.Background()))
+	assertRestarted(oldProcesses)
+
+	oldProcesses = processes()
+	err = net.RollingRestart(context.Background(), &node.Config{
+		Flags: map[string]interface{}{"rolling-restart-flag": "value"},
+	})
+	assert.NoError(err)
+	assertRestarted(oldProcesses)
+	assert.Equal("value", net.nodes["node1"].getConfig().Flags["rolling-restart-flag"])
+
+	oldProcesses = processes()
+	blockchainID := ids.GenerateTestID()
+	chainSpecs := []network.BlockchainSpec{{VmName: "vm1", Genesis: []byte(`{}`), ChainConfig: []byte(`{}`)}}
+	assert.NoError(net.restartNodesWithChainConfigs(context.Background(), chainSpecs, []ids.ID{blockchainID}))
+	assertRestarted(oldProcesses)
+	assert.Equal(`{}`, net.nodes["node1"].getConfig().ChainConfigFiles[blockchainID.String()])
+
+	oldProcesses = processes()
+	net.nodes["node1"].config.Flags[config.WhitelistedSubnetsKey] = "old"
+	for _, node := range net.runningNodes() {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(&subnetsPlatformClient{})
+	}
+	subnetID := ids.GenerateTestID()
+	assert.NoError(net.restartNodesWithWhitelistedSubnets(context.Background(), []ids.ID{subnetID}, network.NewSetupOp()))
+	assertRestarted(oldProcesses)
+	assert.NotContains(net.nodes["node1"].getConfig().Flags, config.WhitelistedSubnetsKey)
+	assert.Equal(subnetID.String(), net.flags[config.WhitelistedSubnetsKey])
+
+	// the plugins are only reloaded on the running nodes
+	adminCli := &loadVMsAdminClient{}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("AdminAPI").Return(adminCli)
+	}
+	assert.NoError(net.reloadVMPlugins(context.Background()))
+	assert.EqualValues(len(networkConfig.NodeConfigs)-1, atomic.LoadUint32(&adminCli.loads))
+
+	// node1 is started with the saved changes
+	assert.NoError(net.StartNode(context.Background(), "node1"))
+	startedConfig := net.nodes["node1"].getConfig()
+	assert.Equal("value", startedConfig.Flags["rolling-restart-flag"])
+	assert.Equal(`{}`, startedConfig.ChainConfigFiles[blockchainID.String()])
+	assert.Equal(subnetID.String(), startedConfig.Flags[config.WhitelistedSubnetsKey])
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetGenesis(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	_, err = net.GetGenesis()
+	assert.ErrorIs(err, network.ErrUndefined)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	genesis, err := net.GetGenesis()
+	assert.NoError(err)
+	assert.EqualValues(networkConfig.Genesis, genesis)
+	assert.NoError(net.Stop(context.Background()))
+	genesis, err = net.GetGenesis()
+	assert.NoError(err)
+	assert.EqualValues(networkConfig.Genesis, genesis)
+}
+
+func TestLoadAlreadyInitialized(t *testing.T) {
+	assert := assert.New(t)
+	snapshotsDir := t.TempDir()
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	nodes := map[string]*localNode{}
+	for nodeName, node := range net.nodes {
+		nodes[nodeName] = node
+		assert.NoError(os.MkdirAll(filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID)), os.ModePerm))
+	}
+	// no other set of nodes is started
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.ErrorIs(err, network.ErrAlreadyInitialized)
+	assert.Equal(nodes, net.nodes)
+	// snapshots can't be loaded into it either, even once stopped
+	_, err = net.SaveSnapshot(context.Background(), "snapshot")
+	assert.NoError(err)
+	err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil)
+	assert.ErrorIs(err, network.ErrAlreadyInitialized)
+	// nor configs into a network loaded from a snapshot
+	net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil)
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.ErrorIs(err, network.ErrAlreadyInitialized)
+	err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil)
+	assert.ErrorIs(err, network.ErrAlreadyInitialized)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetFundedAddressesAndKeys(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	_, err = net.GetFundedAddresses()
+	assert.ErrorIs(err, network.ErrUndefined)
+	_, err = net.GetFundedKeys()
+	assert.ErrorIs(err, network.ErrUndefined)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// the default genesis funds the ewoq key and another address
+	ewoqAddr, err := address.Format("X", constants.GetHRP(net.networkID), genesis.EWOQKey.PublicKey().Address().Bytes())
+	assert.NoError(err)
+	addrs, err := net.GetFundedAddresses()
+	assert.NoError(err)
+	assert.Len(addrs, 2)
+	assert.Contains(addrs, ewoqAddr)
+	assert.NoError(net.Stop(context.Background()))
+	keys, err := net.GetFundedKeys()
+	assert.NoError(err)
+	assert.Equal([]string{genesis.EWOQKey.String()}, keys)
+}
+
+func TestRootDataDir(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.RootDataDir = filepath.Join(t.TempDir(), "network")
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	assert.Equal(networkConfig.RootDataDir, net.GetRootDir())
+	for nodeName, node := range net.nodes {
+		assert.Equal(filepath.Join(networkConfig.RootDataDir, nodeName), filepath.Dir(node.GetDbDir()))
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetTrackedSubnets(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	subnetID1 := ids.GenerateTestID()
+	subnetID2 := ids.GenerateTestID()
+	node := &localNode{config: node.Config{}}
+	subnetIDs, err := node.GetTrackedSubnets(context.Background())
+	assert.NoError(err)
+	assert.Empty(subnetIDs)
+	// from config file
+	node.config.ConfigFile = fmt.Sprintf(`{"%s":"%s"}`, config.WhitelistedSubnetsKey, subnetID1)
+	subnetIDs, err = node.GetTrackedSubnets(context.Background())
+	assert.NoError(err)
+	assert.Equal([]ids.ID{subnetID1}, subnetIDs)
+	// flags take precedence over config file
+	node.config.Flags = map[string]interface{}{
+		config.WhitelistedSubnetsKey: fmt.Sprintf("%s,%s", subnetID1, subnetID2),
+	}
+	subnetIDs, err = node.GetTrackedSubnets(context.Background())
+	assert.NoError(err)
+	assert.Equal([]ids.ID{subnetID1, subnetID2}, subnetIDs)
+	// invalid subnet ID
+	node.config.Flags[config.WhitelistedSubnetsKey] = "invalid"
+	_, err = node.GetTrackedSubnets(context.Background())
+	assert.Error(err)
+}
+
+func TestTrackSubnet(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	node := net.nodes["node1"]
+	nodeID, apiPort, dbDir := node.GetNodeID(), node.GetAPIPort(), node.GetDbDir()
+	subnetID := ids.GenerateTestID()
+	assert.NoError(net.TrackSubnet(context.Background(), "node1", subnetID))
+	node = net.nodes["node1"]
+	trackedSubnets, err := node.GetTrackedSubnets(context.Background())
+	assert.NoError(err)
+	assert.Equal([]ids.ID{subnetID}, trackedSubnets)
+	// identity, ports and db are kept
+	assert.Equal(nodeID, node.GetNodeID())
+	assert.Equal(apiPort, node.GetAPIPort())
+	assert.Equal(dbDir, node.GetDbDir())
+	// other nodes are not affected
+	trackedSubnets, err = net.nodes["node0"].GetTrackedSubnets(context.Background())
+	assert.NoError(err)
+	assert.Empty(trackedSubnets)
+	// tracking an already tracked subnet is a no-op
+	assert.NoError(net.TrackSubnet(context.Background(), "node1", subnetID))
+	assert.Error(net.TrackSubnet(context.Background(), "unknown", subnetID))
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.TrackSubnet(context.Background(), "node1", subnetID), network.ErrStopped)
+}
+
+func TestVerifyNodes(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	nodeIDs := []ids.NodeID{}
+	for _, node := range net.nodes {
+		nodeIDs = append(nodeIDs, node.GetNodeID())
+	}
+	assert.NoError(net.VerifyNodes(context.Background(), nodeIDs))
+	// missing node
+	missingNodeID := ids.GenerateTestNodeID()
+	err = net.VerifyNodes(context.Background(), append(nodeIDs, missingNodeID))
+	assert.Error(err)
+	assert.Contains(err.Error(), missingNodeID.String())
+	// extra node
+	err = net.VerifyNodes(context.Background(), nodeIDs[1:])
+	assert.Error(err)
+	assert.Contains(err.Error(), nodeIDs[0].String())
+	// cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(net.VerifyNodes(ctx, nodeIDs), context.Canceled)
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.VerifyNodes(context.Background(), nodeIDs), network.ErrStopped)
+}
+
+func TestForEachNodeNetwork(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	var lock sync.Mutex
+	visited := []string{}
+	err = net.ForEachNode(context.Background(), 2, func(node node.Node) error {
+		lock.Lock()
+		visited = append(visited, node.GetName())
+		lock.Unlock()
+		return nil
+	})
+	assert.NoError(err)
+	assert.Len(visited, len(networkConfig.NodeConfigs))
+	// all the nodes are visited, and all the errors are reported
+	visited = []string{}
+	err = net.ForEachNode(context.Background(), 2, func(node node.Node) error {
+		lock.Lock()
+		visited = append(visited, node.GetName())
+		lock.Unlock()
+		if node.GetName() == networkConfig.NodeConfigs[0].Name || node.GetName() == networkConfig.NodeConfigs[1].Name {
+			return errors.New("version mismatch")
+		}
+		return nil
+	})
+	assert.Len(visited, len(networkConfig.NodeConfigs))
+	assert.Error(err)
+	assert.Contains(err.Error(), networkConfig.NodeConfigs[0].Name)
+	assert.Contains(err.Error(), networkConfig.NodeConfigs[1].Name)
+	assert.NotContains(err.Error(), networkConfig.NodeConfigs[2].Name)
+	// the function can call the network
+	err = net.ForEachNode(context.Background(), 2, func(node node.Node) error {
+		_, err := net.GetNode(node.GetName())
+		return err
+	})
+	assert.NoError(err)
+	assert.Error(net.ForEachNode(context.Background(), 0, func(node.Node) error { return nil }))
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.ForEachNode(context.Background(), 2, func(node.Node) error { return nil }), network.ErrStopped)
+}
+
+func TestScaleTo(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	nodes, err := net.ScaleTo(context.Background(), 5)
+	assert.NoError(err)
+	assert.Len(nodes, 5)
+	// scaling to fewer nodes than the network has is a no-op
+	nodes, err = net.ScaleTo(context.Background(), 2)
+	assert.NoError(err)
+	assert.Len(nodes, 5)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.ScaleTo(context.Background(), 6)
+	assert.EqualValues(network.ErrStopped, err)
+}
+
+func TestScaleToUnhealthy(t *testing.T) {
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// nodes added from now on never become healthy
+	net.newAPIClientF = func(ipAddr string, port uint16, _ *http.Client) (api.Client, error) {
+		healthClient := &healthmocks.Client{}
+		healthClient.On("Health", mock.Anything).Return(&health.APIHealthReply{Healthy: false}, nil)
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("HealthAPI").Return(healthClient)
+		client.On("CChainEthAPI").Return(ethClient)
+		return client, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = net.ScaleTo(ctx, 5)
+	assert.Error(err)
+	// added nodes are removed on failure
+	assert.Len(net.nodes, len(networkConfig.NodeConfigs))
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestRemoveSnapshotInUse(t *testing.T) {
+	assert := assert.New(t)
+	snapshotsDir := t.TempDir()
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// the mocked nodes do not write a db, so create the dirs to be saved
+	for _, node := range net.nodes {
+		err := os.MkdirAll(filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID)), os.ModePerm)
+		assert.NoError(err)
+	}
+	_, err = net.SaveSnapshot(context.Background(), "snapshot")
+	assert.NoError(err)
+	net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil)
+	assert.NoError(err)
+	err = net.RemoveSnapshot("snapshot")
+	assert.ErrorIs(err, ErrSnapshotInUse)
+	assert.NoError(net.RemoveSnapshotForce("snapshot"))
+	assert.ErrorIs(net.RemoveSnapshot("snapshot"), ErrSnapshotNotFound)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestSaveSnapshotCompression(t *testing.T) {
+	assert := assert.New(t)
+	snapshotsDir := t.TempDir()
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	_, err = net.SaveSnapshot(context.Background(), "snapshot", network.WithSnapshotCompression("unknown"))
+	assert.Error(err)
+	// the mocked nodes do not write a db, so create some db files to be saved
+	dbContents := map[string][]byte{}
+	for nodeName, node := range net.nodes {
+		dbDir := filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID), "v1.4.5")
+		assert.NoError(os.MkdirAll(dbDir, os.ModePerm))
+		dbContents[nodeName] = []byte("db of " + nodeName)
+		assert.NoError(os.WriteFile(filepath.Join(dbDir, "000001.log"), dbContents[nodeName], 0o600))
+	}
+	snapshotDir, err := net.SaveSnapshot(context.Background(), "snapshot", network.WithSnapshotCompression(network.SnapshotCompressionGzip))
+	assert.NoError(err)
+	for nodeName := range dbContents {
+		_, err := os.Stat(filepath.Join(snapshotDir, defaultDbSubdir, nodeName+gzipArchiveSuffix))
+		assert.NoError(err)
+	}
+	metadataJSON, err := os.ReadFile(filepath.Join(snapshotDir, snapshotMetadataFileName))
+	assert.NoError(err)
+	assert.Contains(string(metadataJSON), network.SnapshotCompressionGzip)
+	// loading decompresses the dbs
+	net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil)
+	assert.NoError(err)
+	for nodeName, contents := range dbContents {
+		node, ok := net.nodes[nodeName]
+		assert.True(ok)
+		loaded, err := os.ReadFile(filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID), "v1.4.5", "000001.log"))
+		assert.NoError(err)
+		assert.Equal(contents, loaded)
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestSaveSnapshotCancelled(t *testing.T) {
+	assert := assert.New(t)
+	snapshotsDir := t.TempDir()
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	for nodeName, node := range net.nodes {
+		dbDir := filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID))
+		assert.NoError(os.MkdirAll(dbDir, os.ModePerm))
+		assert.NoError(os.WriteFile(filepath.Join(dbDir, "000001.log"), []byte("db of "+nodeName), 0o600))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = net.SaveSnapshot(ctx, "snapshot")
+	assert.ErrorIs(err, context.Canceled)
+	// the network is left stopped, and no partial snapshot is left behind
+	assert.Empty(net.nodes)
+	entries, err := os.ReadDir(snapshotsDir)
+	assert.NoError(err)
+	assert.Empty(entries)
+	snapshotNames, err := net.GetSnapshotNames()
+	assert.NoError(err)
+	assert.Empty(snapshotNames)
+	// compression also aborts on cancellation
+	srcDir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(srcDir, "000001.log"), []byte("db"), 0o600))
+	err = compressDir(ctx, srcDir, filepath.Join(t.TempDir(), "db"+gzipArchiveSuffix))
+	assert.ErrorIs(err, context.Canceled)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestSaveSnapshotConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	for _, compression := range []string{network.SnapshotCompressionNone, network.SnapshotCompressionGzip} {
+		snapshotsDir := t.TempDir()
+		networkConfig := testNetworkConfig(t)
+		net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+		assert.NoError(err)
+		err = net.loadConfig(context.Background(), networkConfig)
+		assert.NoError(err)
+		_, err = net.SaveSnapshot(context.Background(), "snapshot", network.WithSnapshotMaxConcurrency(0))
+		assert.Error(err)
+		dbContents := map[string][]byte{}
+		for nodeName, node := range net.nodes {
+			dbDir := filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID), "v1.4.5")
+			assert.NoError(os.MkdirAll(dbDir, os.ModePerm))
+			dbContents[nodeName] = []byte("db of " + nodeName)
+			assert.NoError(os.WriteFile(filepath.Join(dbDir, "000001.log"), dbContents[nodeName], 0o600))
+		}
+		assert.Greater(len(dbContents), 2)
+		_, err = net.SaveSnapshot(
+			context.Background(),
+			"snapshot",
+			network.WithSnapshotCompression(compression),
+			network.WithSnapshotMaxConcurrency(2),
+		)
+		assert.NoError(err)
+		// only the final snapshot dir is left
+		entries, err := os.ReadDir(snapshotsDir)
+		assert.NoError(err)
+		assert.Len(entries, 1)
+		net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+		assert.NoError(err)
+		err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil)
+		assert.NoError(err)
+		assert.Len(net.nodes, len(dbContents))
+		for nodeName, contents := range dbContents {
+			node, ok := net.nodes[nodeName]
+			assert.True(ok)
+			loaded, err := os.ReadFile(filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID), "v1.4.5", "000001.log"))
+			assert.NoError(err)
+			assert.Equal(contents, loaded)
+		}
+		assert.NoError(net.Stop(context.Background()))
+	}
+}
+
+func TestGetBuildInfo(t *testing.T) {
+	assert := assert.New(t)
+	snapshotsDir := t.TempDir()
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	buildInfo := net.GetBuildInfo()
+	assert.NotEmpty(buildInfo.AvalancheGoLibVersion)
+	assert.Equal(testNodeVersion.Version, buildInfo.AvalancheGoVersion)
+	assert.Equal(testNodeVersion.GitCommit, buildInfo.AvalancheGoCommit)
+	// the build info is saved in the snapshot metadata
+	for _, node := range net.nodes {
+		assert.NoError(os.MkdirAll(filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID)), os.ModePerm))
+	}
+	snapshotDir, err := net.SaveSnapshot(context.Background(), "snapshot")
+	assert.NoError(err)
+	metadataJSON, err := os.ReadFile(filepath.Join(snapshotDir, snapshotMetadataFileName))
+	assert.NoError(err)
+	metadata := snapshotMetadata{}
+	assert.NoError(json.Unmarshal(metadataJSON, &metadata))
+	assert.Equal(&buildInfo, metadata.BuildInfo)
+	// no node is queried once stopped
+	buildInfo = net.GetBuildInfo()
+	assert.NotEmpty(buildInfo.AvalancheGoLibVersion)
+	assert.Empty(buildInfo.AvalancheGoVersion)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestLoadSnapshotPortOverrides(t *testing.T) {
+	assert := assert.New(t)
+	snapshotsDir := t.TempDir()
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// the mocked nodes do not write a db, so create the dirs to be saved
+	for _, node := range net.nodes {
+		err := os.MkdirAll(filepath.Join(node.GetDbDir(), constants.NetworkName(net.networkID)), os.ModePerm)
+		assert.NoError(err)
+	}
+	_, err = net.SaveSnapshot(context.Background(), "snapshot")
+	assert.NoError(err)
+	// overrides must refer to snapshot nodes
+	net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil,
+		network.WithPortOverrides(map[string]network.PortOverride{"unknown": {APIPort: 31000}}),
+	)
+	assert.Error(err)
+	// one node gets explicit ports, the others get new ports from the given range
+	overriddenNodeName := networkConfig.NodeConfigs[0].Name
+	net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", snapshotsDir)
+	assert.NoError(err)
+	err = net.loadSnapshot(context.Background(), "snapshot", "", "", nil, nil, nil,
+		network.WithPortOverrides(map[string]network.PortOverride{overriddenNodeName: {APIPort: 31000, P2PPort: 31001}}),
+		network.WithSnapshotPortRange(21000, 21100),
+	)
+	assert.NoError(err)
+	assert.Len(net.nodes, len(networkConfig.NodeConfigs))
+	for nodeName, node := range net.nodes {
+		if nodeName == overriddenNodeName {
+			assert.EqualValues(31000, node.GetAPIPort())
+			assert.EqualValues(31001, node.GetP2PPort())
+			continue
+		}
+		for _, port := range []uint16{node.GetAPIPort(), node.GetP2PPort()} {
+			assert.GreaterOrEqual(port, uint16(21000))
+			assert.LessOrEqual(port, uint16(21100))
+		}
+	}
+	// beacon addresses follow the new ports
+	assert.Contains(net.bootstraps.IPsArg(), ":31001")
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestRunPhase(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.NoError(runPhase(context.Background(), "phase", time.Second, func(context.Context) error { return nil }))
+	errPhase := errors.New("phase error")
+	err := runPhase(context.Background(), "phase", time.Second, func(context.Context) error { return errPhase })
+	assert.ErrorIs(err, errPhase)
+	assert.Contains(err.Error(), "phase phase failed")
+	err = runPhase(context.Background(), "phase", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Contains(err.Error(), "phase phase timed out")
+	// without a budget, the phase is only limited by the given context
+	err = runPhase(context.Background(), "phase", 0, func(ctx context.Context) error {
+		_, ok := ctx.Deadline()
+		assert.False(ok)
+		return nil
+	})
+	assert.NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = runPhase(ctx, "phase", 0, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Contains(err.Error(), "phase phase failed")
+}
+
+// Logger recording the fields of each Info and Warn line
+type fieldsRecordingLogger struct {
+	logging.NoLog
+	lines [][]zap.Field
+}
+
+func (l *fieldsRecordingLogger) Info(_ string, fields ...zap.Field) {
+	l.lines = append(l.lines, fields)
+}
+
+func (l *fieldsRecordingLogger) Warn(_ string, fields ...zap.Field) {
+	l.lines = append(l.lines, fields)
+}
+
+func TestSetupLogger(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	recorder := &fieldsRecordingLogger{}
+	// without request ID, the logger is left as is
+	assert.Equal(recorder, setupLogger(recorder, network.NewSetupOp()))
+	log := setupLogger(recorder, network.NewSetupOp(network.WithRequestID("req-1")))
+	log.Info("created subnet tx", zap.String("subnet-ID", "subnet"))
+	log.Warn("txs not confirmed by some nodes")
+	assert.Equal([][]zap.Field{
+		{zap.String("request-ID", "req-1"), zap.String("subnet-ID", "subnet")},
+		{zap.String("request-ID", "req-1")},
+	}, recorder.lines)
+	// the request ID is added to the lines of the setup helpers given the logger
+	recorder.lines = nil
+	_, err := createSubnets(context.Background(), 0, nil, ids.ShortEmpty, log, network.NewSetupOp())
+	assert.NoError(err)
+	assert.Equal([][]zap.Field{
+		{zap.String("request-ID", "req-1"), zap.Uint32("num-subnets", 0)},
+	}, recorder.lines)
+}
+
+func TestPollBackoff(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	backoff := newPollBackoff(time.Second)
+	assert.Equal(time.Second, backoff.next(false))
+	// doubled on each failure, up to the max factor
+	assert.Equal(2*time.Second, backoff.next(true))
+	assert.Equal(4*time.Second, backoff.next(true))
+	assert.Equal(8*time.Second, backoff.next(true))
+	assert.Equal(maxPollBackoffFactor*time.Second, backoff.next(true))
+	// reset on success
+	assert.Equal(time.Second, backoff.next(false))
+	assert.Equal(2*time.Second, backoff.next(true))
+}
+
+func TestRetryTransient(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	op := network.NewSetupOp(network.WithIssueRetries(2, time.Millisecond))
+	transientErr := fmt.Errorf("failed to issue request: %w", syscall.ECONNRESET)
+	// succeeds after transient errors
+	calls := 0
+	err := retryTransient(context.Background(), logging.NoLog{}, op, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return transientErr
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(3, calls)
+	// gives up after the given retries
+	calls = 0
+	err = retryTransient(context.Background(), logging.NoLog{}, op, func(context.Context) error {
+		calls++
+		return transientErr
+	})
+	assert.ErrorIs(err, syscall.ECONNRESET)
+	assert.Equal(3, calls)
+	// definitive errors are not retried
+	calls = 0
+	errRejected := errors.New("tx rejected")
+	err = retryTransient(context.Background(), logging.NoLog{}, op, func(context.Context) error {
+		calls++
+		return errRejected
+	})
+	assert.ErrorIs(err, errRejected)
+	assert.Equal(1, calls)
+}
+
+type transientWallet struct {
+	primary.Wallet
+	pWallet *transientPWallet
+}
+
+func (w *transientWallet) P() p.Wallet {
+	return w.pWallet
+}
+
+// P-Chain wallet failing the first subnet tx issuance with a transient error
+type transientPWallet struct {
+	p.Wallet
+	// whether each issuance waited for the tx to be decided
+	waitedDecided []bool
+}
+
+func (w *transientPWallet) IssueCreateSubnetTx(_ *secp256k1fx.OutputOwners, options ...common.Option) (ids.ID, error) {
+	w.waitedDecided = append(w.waitedDecided, !common.NewOptions(options).AssumeDecided())
+	if len(w.waitedDecided) == 1 {
+		return ids.Empty, fmt.Errorf("failed to issue request: %w", syscall.ECONNRESET)
+	}
+	return ids.GenerateTestID(), nil
+}
+
+// Test that only the issuance of the setup txs is retried, without waiting for them
+func TestCreateSubnetsRetriesIssuance(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	op := network.NewSetupOp(network.WithIssueRetries(1, time.Millisecond))
+	pWallet := &transientPWallet{}
+	subnetIDs, err := createSubnets(context.Background(), 1, &transientWallet{pWallet: pWallet}, ids.GenerateTestShortID(), logging.NoLog{}, op)
+	assert.NoError(err)
+	assert.Len(subnetIDs, 1)
+	assert.Equal([]bool{false, false}, pWallet.waitedDecided)
+}
+
+func TestForEachNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	nodes := map[string]*localNode{}
+	for i := 0; i < 50; i++ {
+		nodeName := fmt.Sprintf("node%d", i)
+		nodes[nodeName] = &localNode{name: nodeName}
+	}
+	// every node is visited once, with no more than the given concurrency
+	var (
+		lock             sync.Mutex
+		visited          = map[string]int{}
+		running          int
+		maxRunning       int
+		maxConcurrency   = uint32(4)
+		errNodeUnhealthy = errors.New("node unhealthy")
+	)
+	err := forEachNode(context.Background(), nodes, maxConcurrency, func(_ context.Context, nodeName string, node *localNode) error {
+		lock.Lock()
+		visited[nodeName]++
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		assert.Equal(nodeName, node.GetName())
+		return nil
+	})
+	assert.NoError(err)
+	assert.Len(visited, len(nodes))
+	for _, count := range visited {
+		assert.Equal(1, count)
+	}
+	assert.LessOrEqual(maxRunning, int(maxConcurrency))
+	// the first error is returned
+	err = forEachNode(context.Background(), nodes, maxConcurrency, func(_ context.Context, nodeName string, _ *localNode) error {
+		if nodeName == "node7" {
+			return errNodeUnhealthy
+		}
+		return nil
+	})
+	assert.ErrorIs(err, errNodeUnhealthy)
+	// a done context is reported even if no node was visited
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = forEachNode(ctx, nodes, maxConcurrency, func(context.Context, string, *localNode) error {
+		return nil
+	})
+	assert.ErrorIs(err, context.Canceled)
+	// concurrency must be positive
+	err = forEachNode(context.Background(), nodes, 0, func(context.Context, string, *localNode) error {
+		return nil
+	})
+	assert.Error(err)
+}
+
+func TestSubnetOwners(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	testKeyAddr := ids.GenerateTestShortID()
+	otherAddr := ids.GenerateTestShortID()
+	testKey, err := address.Format("P", constants.LocalHRP, testKeyAddr.Bytes())
+	assert.NoError(err)
+	otherKey, err := address.Format("P", constants.LocalHRP, otherAddr.Bytes())
+	assert.NoError(err)
+	// default single key
+	owners, err := subnetOwners(network.NewSetupOp(), testKeyAddr)
+	assert.NoError(err)
+	assert.EqualValues(1, owners.Threshold)
+	assert.Equal([]ids.ShortID{testKeyAddr}, owners.Addrs)
+	// multiple keys
+	owners, err = subnetOwners(network.NewSetupOp(network.WithSubnetControlKeys([]string{testKey, otherKey}, 2)), testKeyAddr)
+	assert.NoError(err)
+	assert.EqualValues(2, owners.Threshold)
+	assert.ElementsMatch([]ids.ShortID{testKeyAddr, otherAddr}, owners.Addrs)
+	// threshold greater than number of keys
+	_, err = subnetOwners(network.NewSetupOp(network.WithSubnetControlKeys([]string{testKey}, 2)), testKeyAddr)
+	assert.Error(err)
+	// zero threshold
+	_, err = subnetOwners(network.NewSetupOp(network.WithSubnetControlKeys([]string{testKey}, 0)), testKeyAddr)
+	assert.Error(err)
+	// repeated keys
+	_, err = subnetOwners(network.NewSetupOp(network.WithSubnetControlKeys([]string{testKey, testKey}, 1)), testKeyAddr)
+	assert.Error(err)
+	// invalid key
+	_, err = subnetOwners(network.NewSetupOp(network.WithSubnetControlKeys([]string{"invalid"}, 1)), testKeyAddr)
+	assert.Error(err)
+}
+
+func TestSetupKey(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	// default pre-funded key
+	key, err := setupKey(network.NewSetupOp())
+	assert.NoError(err)
+	assert.Equal(genesis.EWOQKey.Bytes(), key.Bytes())
+	// given key
+	factory := crypto.FactorySECP256K1R{}
+	fundedKeyIntf, err := factory.NewPrivateKey()
+	assert.NoError(err)
+	fundedKey := fundedKeyIntf.(*crypto.PrivateKeySECP256K1R)
+	key, err = setupKey(network.NewSetupOp(network.WithFundedKey(fundedKey.String())))
+	assert.NoError(err)
+	assert.Equal(fundedKey.Bytes(), key.Bytes())
+	// invalid key is rejected before any tx is issued
+	assert.Error(validateSetupOp(network.NewSetupOp(network.WithFundedKey("PrivateKey-invalid"))))
+	assert.Error(validateSetupOp(network.NewSetupOp(network.WithFundedKey(fundedKey.PublicKey().Address().String()))))
+}
+
+func TestSubnetSigningKeys(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	factory := crypto.FactorySECP256K1R{}
+	otherKeyIntf, err := factory.NewPrivateKey()
+	assert.NoError(err)
+	otherKey := otherKeyIntf.(*crypto.PrivateKeySECP256K1R)
+	testAddr, err := address.Format("P", constants.LocalHRP, genesis.EWOQKey.PublicKey().Address().Bytes())
+	assert.NoError(err)
+	otherAddr, err := address.Format("P", constants.LocalHRP, otherKey.PublicKey().Address().Bytes())
+	assert.NoError(err)
+	controlKeys := network.WithSubnetControlKeys([]string{testAddr, otherAddr}, 2)
+	// threshold not reachable with the funded key only
+	err = validateSetupOp(network.NewSetupOp(controlKeys))
+	assert.ErrorIs(err, ErrNotEnoughSigners)
+	// threshold reachable with the signing keys
+	op := network.NewSetupOp(controlKeys, network.WithSubnetSigningKeys([]string{otherKey.String()}))
+	assert.NoError(validateSetupOp(op))
+	keychain, _, err := setupKeychain(op)
+	assert.NoError(err)
+	assert.Len(keychain.Keys, 2)
+	// invalid signing key
+	err = validateSetupOp(network.NewSetupOp(controlKeys, network.WithSubnetSigningKeys([]string{"invalid"})))
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrNotEnoughSigners)
+	// existing subnets
+	subnetID := ids.GenerateTestID()
+	platformCli := &subnetsPlatformClient{
+		subnets: []platformvm.ClientSubnet{{
+			ID:          subnetID,
+			ControlKeys: []ids.ShortID{genesis.EWOQKey.PublicKey().Address(), otherKey.PublicKey().Address()},
+			Threshold:   2,
+		}},
+	}
+	err = checkSubnetSigners(context.Background(), platformCli, []ids.ID{subnetID}, network.NewSetupOp())
+	assert.ErrorIs(err, ErrNotEnoughSigners)
+	assert.NoError(checkSubnetSigners(context.Background(), platformCli, []ids.ID{subnetID}, op))
+}
+
+// P-Chain API client whose GetSubnets method returns the requested ones of [subnets],
+// and whose GetBlockchains method always returns [blockchains].
+// Only GetSubnets and GetBlockchains may be called.
+type subnetsPlatformClient struct {
+	platformvm.Client
+	subnets     []platformvm.ClientSubnet
+	blockchains []platformvm.APIBlockchain
+}
+
+func (c *subnetsPlatformClient) GetSubnets(_ context.Context, subnetIDs []ids.ID, _ ...rpc.Option) ([]platformvm.ClientSubnet, error) {
+	// as the P-Chain API, returns all the subnets if none is given
+	if len(subnetIDs) == 0 {
+		return c.subnets, nil
+	}
+	subnets := []platformvm.ClientSubnet{}
+	for _, subnet := range c.subnets {
+		for _, subnetID := range subnetIDs {
+			if subnet.ID == subnetID {
+				subnets = append(subnets, subnet)
+			}
+		}
+	}
+	return subnets, nil
+}
+
+func (c *subnetsPlatformClient) GetBlockchains(context.Context, ...rpc.Option) ([]platformvm.APIBlockchain, error) {
+	return c.blockchains, nil
+}
+
+// Info API client whose Uptime method always returns [uptime] and [err].
+// Only Uptime may be called.
+type uptimeInfoClient struct {
+	info.Client
+	uptime float64
+	err    error
+}
+
+func (c *uptimeInfoClient) Uptime(context.Context, ...rpc.Option) (*info.UptimeResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &info.UptimeResponse{WeightedAveragePercentage: avajson.Float64(c.uptime)}, nil
+}
+
+// Info API client whose GetTxFee method always returns [fees].
+// Only GetTxFee may be called.
+type txFeeInfoClient struct {
+	info.Client
+	fees info.GetTxFeeResponse
+}
+
+func (c *txFeeInfoClient) GetTxFee(context.Context, ...rpc.Option) (*info.GetTxFeeResponse, error) {
+	fees := c.fees
+	return &fees, nil
+}
+
+// P-Chain API client whose GetCurrentValidators method returns the
+// given validators of [validators], by subnet, or all of them if none is given.
+// Only GetCurrentValidators may be called.
+type validatorsPlatformClient struct {
+	platformvm.Client
+	validators map[ids.ID][]platformvm.ClientPrimaryValidator
+}
+
+func (c *validatorsPlatformClient) GetCurrentValidators(
+	_ context.Context,
+	subnetID ids.ID,
+	nodeIDs []ids.NodeID,
+	_ ...rpc.Option,
+) ([]platformvm.ClientPrimaryValidator, error) {
+	if len(nodeIDs) == 0 {
+		return c.validators[subnetID], nil
+	}
+	vs := []platformvm.ClientPrimaryValidator{}
+	for _, v := range c.validators[subnetID] {
+		for _, nodeID := range nodeIDs {
+			if v.NodeID == nodeID {
+				vs = append(vs, v)
+			}
+		}
+	}
+	return vs, nil
+}
+
+// P-Chain API client with the subnets of [subnetsPlatformClient] and the current
+// validators of [validatorsPlatformClient], and no pending validators.
+type drainPlatformClient struct {
+	platformvm.Client
+	subnets    *subnetsPlatformClient
+	validators *validatorsPlatformClient
+}
+
+func (c *drainPlatformClient) GetSubnets(ctx context.Context, subnetIDs []ids.ID, options ...rpc.Option) ([]platformvm.ClientSubnet, error) {
+	return c.subnets.GetSubnets(ctx, subnetIDs, options...)
+}
+
+func (c *drainPlatformClient) GetCurrentValidators(
+	ctx context.Context,
+	subnetID ids.ID,
+	nodeIDs []ids.NodeID,
+	options ...rpc.Option,
+) ([]platformvm.ClientPrimaryValidator, error) {
+	return c.validators.GetCurrentValidators(ctx, subnetID, nodeIDs, options...)
+}
+
+func (c *drainPlatformClient) GetPendingValidators(context.Context, ids.ID, []ids.NodeID, ...rpc.Option) ([]interface{}, []interface{}, error) {
+	return nil, nil, nil
+}
+
+func TestDrainNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	subnetID := ids.GenerateTestID()
+	platformCli := &drainPlatformClient{
+		subnets: &subnetsPlatformClient{
+			subnets: []platformvm.ClientSubnet{{ID: constants.PrimaryNetworkID}, {ID: subnetID}},
+		},
+		validators: &validatorsPlatformClient{
+			validators: map[ids.ID][]platformvm.ClientPrimaryValidator{
+				subnetID: {{ClientStaker: platformvm.ClientStaker{NodeID: net.nodes["node1"].GetNodeID()}}},
+			},
+		},
+	}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	// a node not validating is removed right away
+	assert.NoError(net.DrainNode(context.Background(), "node2", time.Minute))
+	_, err = net.GetNode("node2")
+	assert.ErrorIs(err, network.ErrNodeNotFound)
+	// a node still validating is not removed
+	err = net.DrainNode(context.Background(), "node1", 100*time.Millisecond)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Contains(err.Error(), subnetID.String())
+	_, err = net.GetNode("node1")
+	assert.NoError(err)
+	assert.ErrorIs(net.DrainNode(context.Background(), "node3", time.Minute), network.ErrNodeNotFound)
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.DrainNode(context.Background(), "node1", time.Minute), network.ErrStopped)
+}
+
+func TestEstimateSetupCost(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	infoCli := &txFeeInfoClient{fees: info.GetTxFeeResponse{
+		TxFee:                 1_000_000,
+		CreateSubnetTxFee:     100_000_000,
+		CreateBlockchainTxFee: 200_000_000,
+	}}
+	for _, node := range net.nodes {
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("InfoAPI").Return(infoCli)
+		client.On("CChainEthAPI").Return(ethClient)
+		node.client = client
+	}
+	// a new subnet is created
+	cost, err := net.EstimateSetupCost(context.Background(), network.BlockchainSpec{VmName: "vm"}, 3)
+	assert.NoError(err)
+	assert.EqualValues(303_000_000, cost)
+	// the subnet already exists
+	subnetID := ids.GenerateTestID().String()
+	cost, err = net.EstimateSetupCost(context.Background(), network.BlockchainSpec{VmName: "vm", SubnetId: &subnetID}, 3)
+	assert.NoError(err)
+	assert.EqualValues(203_000_000, cost)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.EstimateSetupCost(context.Background(), network.BlockchainSpec{VmName: "vm"}, 3)
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestGetPrimaryValidators(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	validatorID := net.nodes["node0"].GetNodeID()
+	externalID := ids.GenerateTestNodeID()
+	stakeAmount, connected := uint64(2000), true
+	platformCli := &validatorsPlatformClient{
+		validators: map[ids.ID][]platformvm.ClientPrimaryValidator{
+			constants.PrimaryNetworkID: {
+				{
+					ClientStaker: platformvm.ClientStaker{NodeID: validatorID, StartTime: 10, EndTime: 20, StakeAmount: &stakeAmount},
+					Connected:    &connected,
+				},
+				{ClientStaker: platformvm.ClientStaker{NodeID: externalID}},
+			},
+		},
+	}
+	for _, node := range net.nodes {
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("PChainAPI").Return(platformCli)
+		client.On("CChainEthAPI").Return(ethClient)
+		node.client = client
+	}
+	validators, err := net.GetPrimaryValidators(context.Background())
+	assert.NoError(err)
+	assert.Len(validators, 2)
+	for _, validator := range validators {
+		if validator.NodeID == externalID {
+			assert.Empty(validator.NodeName)
+			continue
+		}
+		assert.Equal(network.ValidatorInfo{
+			NodeID:      validatorID,
+			NodeName:    "node0",
+			StartTime:   time.Unix(10, 0),
+			EndTime:     time.Unix(20, 0),
+			StakeAmount: stakeAmount,
+			Connected:   true,
+		}, validator)
+	}
+	assert.True(validators[0].NodeID.String() < validators[1].NodeID.String())
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetPrimaryValidators(context.Background())
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestGetSubnetValidatorDetails(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	subnetID := ids.GenerateTestID()
+	validatorID := net.nodes["node1"].GetNodeID()
+	weight := uint64(30)
+	platformCli := &validatorsPlatformClient{
+		validators: map[ids.ID][]platformvm.ClientPrimaryValidator{
+			constants.PrimaryNetworkID: {
+				{ClientStaker: platformvm.ClientStaker{NodeID: net.nodes["node0"].GetNodeID()}},
+			},
+			subnetID: {
+				{ClientStaker: platformvm.ClientStaker{NodeID: validatorID, StartTime: 10, EndTime: 20, Weight: &weight}},
+			},
+		},
+	}
+	for _, node := range net.nodes {
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("PChainAPI").Return(platformCli)
+		client.On("CChainEthAPI").Return(ethClient)
+		node.client = client
+	}
+	validators, err := net.GetSubnetValidatorDetails(context.Background(), subnetID)
+	assert.NoError(err)
+	assert.Equal([]network.SubnetValidatorDetail{
+		{
+			NodeID:    validatorID,
+			NodeName:  "node1",
+			Weight:    weight,
+			StartTime: time.Unix(10, 0),
+			EndTime:   time.Unix(20, 0),
+		},
+	}, validators)
+	validators, err = net.GetSubnetValidatorDetails(context.Background(), ids.GenerateTestID())
+	assert.NoError(err)
+	assert.Empty(validators)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetSubnetValidatorDetails(context.Background(), subnetID)
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestGetNodeNamesByRole(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	beacons := []string{}
+	for name, node := range net.nodes {
+		if node.config.IsBeacon {
+			beacons = append(beacons, name)
+		}
+	}
+	utils.SortNodeNames(beacons)
+	subnetID := ids.GenerateTestID()
+	platformCli := &validatorsPlatformClient{
+		validators: map[ids.ID][]platformvm.ClientPrimaryValidator{
+			constants.PrimaryNetworkID: {
+				{ClientStaker: platformvm.ClientStaker{NodeID: net.nodes["node2"].GetNodeID()}},
+				{ClientStaker: platformvm.ClientStaker{NodeID: net.nodes["node0"].GetNodeID()}},
+				// validators not in the network are ignored
+				{ClientStaker: platformvm.ClientStaker{NodeID: ids.GenerateTestNodeID()}},
+			},
+			subnetID: {
+				{ClientStaker: platformvm.ClientStaker{NodeID: net.nodes["node1"].GetNodeID()}},
+			},
+		},
+	}
+	for _, node := range net.nodes {
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("PChainAPI").Return(platformCli)
+		client.On("CChainEthAPI").Return(ethClient)
+		node.client = client
+	}
+	names, err := net.GetNodeNamesByRole(context.Background(), network.BeaconRole())
+	assert.NoError(err)
+	assert.Equal(beacons, names)
+	names, err = net.GetNodeNamesByRole(context.Background(), network.ValidatorRole())
+	assert.NoError(err)
+	assert.Equal([]string{"node0", "node2"}, names)
+	names, err = net.GetNodeNamesByRole(context.Background(), network.SubnetValidatorRole(subnetID))
+	assert.NoError(err)
+	assert.Equal([]string{"node1"}, names)
+	names, err = net.GetNodeNamesByRole(context.Background(), network.SubnetValidatorRole(ids.GenerateTestID()))
+	assert.NoError(err)
+	assert.Empty(names)
+	_, err = net.GetNodeNamesByRole(context.Background(), network.NodeRole{Kind: "unknown"})
+	assert.Error(err)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetNodeNamesByRole(context.Background(), network.BeaconRole())
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestGetPChainHeight(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	expected := map[string]uint64{}
+	platformClis := map[string]*heightPlatformClient{}
+	for nodeName, node := range net.nodes {
+		expected[nodeName] = uint64(len(expected) + 10)
+		platformClis[nodeName] = &heightPlatformClient{height: expected[nodeName]}
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("PChainAPI").Return(platformClis[nodeName])
+		client.On("CChainEthAPI").Return(ethClient)
+		node.client = client
+	}
+	heights, err := net.GetPChainHeight(context.Background())
+	assert.NoError(err)
+	assert.Equal(expected, heights)
+	// a node failing to report its height fails the query
+	errUnreachable := errors.New("connection refused")
+	platformClis["node1"].err = errUnreachable
+	_, err = net.GetPChainHeight(context.Background())
+	assert.ErrorIs(err, errUnreachable)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetPChainHeight(context.Background())
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestGetUptime(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	subnetID := ids.GenerateTestID()
+	validatorID := net.nodes["node0"].GetNodeID()
+	subnetValidatorID := net.nodes["node1"].GetNodeID()
+	primaryUptime, subnetUptime := float32(0.75), float32(0.5)
+	platformCli := &validatorsPlatformClient{
+		validators: map[ids.ID][]platformvm.ClientPrimaryValidator{
+			constants.PrimaryNetworkID: {
+				{ClientStaker: platformvm.ClientStaker{NodeID: validatorID}, Uptime: &primaryUptime},
+				{ClientStaker: platformvm.ClientStaker{NodeID: subnetValidatorID}, Uptime: &subnetUptime},
+			},
+			subnetID: {
+				{ClientStaker: platformvm.ClientStaker{NodeID: subnetValidatorID}},
+			},
+		},
+	}
+	errNotValidator := errors.New("node is not a validator")
+	for nodeName, node := range net.nodes {
+		infoCli := &uptimeInfoClient{uptime: 90}
+		if nodeName == "node2" {
+			infoCli = &uptimeInfoClient{err: errNotValidator}
+		}
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("InfoAPI").Return(infoCli)
+		client.On("PChainAPI").Return(platformCli)
+		client.On("CChainEthAPI").Return(ethClient)
+		node.client = client
+	}
+
+	uptime, err := net.GetUptime(context.Background(), "node0")
+	assert.NoError(err)
+	assert.EqualValues(90, uptime)
+	_, err = net.GetUptime(context.Background(), "node2")
+	assert.ErrorIs(err, errNotValidator)
+	_, err = net.GetUptime(context.Background(), "node3")
+	assert.ErrorIs(err, network.ErrNodeNotFound)
+
+	// reported as a percentage
+	uptime, err = net.GetSubnetValidatorUptime(context.Background(), constants.PrimaryNetworkID, validatorID)
+	assert.NoError(err)
+	assert.EqualValues(75, uptime)
+	// subnet validators have the uptime of their primary network validation
+	uptime, err = net.GetSubnetValidatorUptime(context.Background(), subnetID, subnetValidatorID)
+	assert.NoError(err)
+	assert.EqualValues(50, uptime)
+	_, err = net.GetSubnetValidatorUptime(context.Background(), subnetID, validatorID)
+	assert.ErrorIs(err, network.ErrNotValidator)
+	_, err = net.GetSubnetValidatorUptime(context.Background(), constants.PrimaryNetworkID, net.nodes["node2"].GetNodeID())
+	assert.ErrorIs(err, network.ErrNotValidator)
+
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetUptime(context.Background(), "node0")
+	assert.ErrorIs(err, network.ErrStopped)
+	_, err = net.GetSubnetValidatorUptime(context.Background(), subnetID, subnetValidatorID)
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestAwaitVMBlock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	blockchainID := ids.GenerateTestID()
+	var height uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/ext/bc/%s/rpc", blockchainID) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"height":%d}}`, atomic.AddUint64(&height, 1))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(err)
+	port, err := strconv.ParseUint(serverURL.Port(), 10, 16)
+	assert.NoError(err)
+
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs = networkConfig.NodeConfigs[:1]
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	for _, node := range net.nodes {
+		node.apiPort = uint16(port)
+	}
+	query := network.VMBlockQuery{
+		Method: "vm.getLastAccepted",
+		ParseHeight: func(result json.RawMessage) (uint64, error) {
+			reply := struct {
+				Height uint64 `json:"height"`
+			}{}
+			err := json.Unmarshal(result, &reply)
+			return reply.Height, err
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(net.AwaitVMBlock(ctx, blockchainID, 2, "/rpc", query))
+	assert.GreaterOrEqual(atomic.LoadUint64(&height), uint64(2))
+	// wrong path
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = net.AwaitVMBlock(ctx, blockchainID, 1, "/other", query)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	// query without parser
+	assert.Error(net.AwaitVMBlock(context.Background(), blockchainID, 1, "/rpc", network.VMBlockQuery{Method: "vm.getLastAccepted"}))
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.AwaitVMBlock(context.Background(), blockchainID, 1, "/rpc", query), network.ErrStopped)
+}
+
+// Test that the network can be modified while AwaitVMBlock waits for a lagging node,
+// and that the nodes stopped meanwhile are no longer waited for
+func TestAwaitVMBlockConcurrentNodeOps(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	blockchainID := ids.GenerateTestID()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"height":1}}`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(err)
+	port, err := strconv.ParseUint(serverURL.Port(), 10, 16)
+	assert.NoError(err)
+	// no server listens on the port of the lagging node
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachableURL, err := url.Parse(unreachable.URL)
+	assert.NoError(err)
+	unreachablePort, err := strconv.ParseUint(unreachableURL.Port(), 10, 16)
+	assert.NoError(err)
+	unreachable.Close()
+
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs = networkConfig.NodeConfigs[:2]
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	net.nodes["node0"].apiPort = uint16(port)
+	net.nodes["node1"].apiPort = uint16(unreachablePort)
+	query := network.VMBlockQuery{
+		Method: "vm.getLastAccepted",
+		ParseHeight: func(result json.RawMessage) (uint64, error) {
+			reply := struct {
+				Height uint64 `json:"height"`
+			}{}
+			err := json.Unmarshal(result, &reply)
+			return reply.Height, err
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- net.AwaitVMBlock(ctx, blockchainID, 1, "/rpc", query)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	// the nodes can be added and stopped while waiting
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newNode, err := net.AddNode(node.Config{Name: "new"})
+		assert.NoError(err)
+		assert.NoError(net.StopNode(context.Background(), newNode.GetName()))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * waitForChainHeightPullFrequency):
+		assert.Fail("network lock held while waiting for the VM block")
+	}
+	select {
+	case err := <-errCh:
+		assert.Fail("returned while node1 lags", err)
+	default:
+	}
+	// once the lagging node is stopped, it is no longer waited for
+	assert.NoError(net.StopNode(context.Background(), "node1"))
+	select {
+	case err := <-errCh:
+		assert.NoError(err)
+	case <-time.After(5 * waitForChainHeightPullFrequency):
+		assert.Fail("VM block not awaited")
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetPeers(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// each node is connected to all the others, and node0 also to an external node
+	externalNodeID := ids.GenerateTestNodeID()
+	for nodeName, node := range net.nodes {
+		peers := []info.Peer{}
+		for otherNodeName, otherNode := range net.nodes {
+			if otherNodeName != nodeName {
+				peers = append(peers, info.Peer{Info: peer.Info{ID: otherNode.GetNodeID(), IP: "127.0.0.1"}})
+			}
+		}
+		if nodeName == "node0" {
+			peers = append(peers, info.Peer{Info: peer.Info{ID: externalNodeID}})
+		}
+		client := &apimocks.Client{}
+		client.On("InfoAPI").Return(&peersInfoClient{peers: peers})
+		node.client = client
+	}
+	peerInfos, err := net.GetPeers(context.Background(), "node0")
+	assert.NoError(err)
+	assert.Len(peerInfos, 3)
+	peerNames := map[ids.NodeID]string{}
+	for _, peerInfo := range peerInfos {
+		peerNames[peerInfo.NodeID] = peerInfo.NodeName
+	}
+	assert.Equal("node1", peerNames[net.nodes["node1"].GetNodeID()])
+	assert.Equal("node2", peerNames[net.nodes["node2"].GetNodeID()])
+	assert.Equal("", peerNames[externalNodeID])
+	_, err = net.GetPeers(context.Background(), "node3")
+	assert.ErrorIs(err, network.ErrNodeNotFound)
+
+	matrix, err := net.GetConnectivityMatrix(context.Background())
+	assert.NoError(err)
+	assert.Len(matrix, 3)
+	assert.Len(matrix["node0"], 3)
+	expected := []ids.NodeID{net.nodes["node0"].GetNodeID(), net.nodes["node2"].GetNodeID()}
+	ids.SortNodeIDs(expected)
+	assert.Equal(expected, matrix["node1"])
+}
+
+func TestGetSubnetInfo(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	subnetID := ids.GenerateTestID()
+	controlKey := ids.GenerateTestShortID()
+	platformCli := &subnetsPlatformClient{
+		subnets: []platformvm.ClientSubnet{{
+			ID:          subnetID,
+			ControlKeys: []ids.ShortID{controlKey},
+			Threshold:   1,
+		}},
+		blockchains: []platformvm.APIBlockchain{
+			{ID: ids.GenerateTestID(), Name: "b", SubnetID: subnetID},
+			{ID: ids.GenerateTestID(), Name: "other", SubnetID: ids.GenerateTestID()},
+			{ID: ids.GenerateTestID(), Name: "a", SubnetID: subnetID},
+		},
+	}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	subnetInfo, err := net.GetSubnetInfo(context.Background(), subnetID)
+	assert.NoError(err)
+	assert.Equal(subnetID, subnetInfo.ID)
+	assert.EqualValues(1, subnetInfo.Threshold)
+	expectedControlKey, err := address.Format("P", constants.GetHRP(net.networkID), controlKey.Bytes())
+	assert.NoError(err)
+	assert.Equal([]string{expectedControlKey}, subnetInfo.ControlKeys)
+	assert.Len(subnetInfo.Blockchains, 2)
+	assert.Equal("a", subnetInfo.Blockchains[0].Name)
+	assert.Equal("b", subnetInfo.Blockchains[1].Name)
+	// unknown subnet
+	_, err = net.GetSubnetInfo(context.Background(), ids.GenerateTestID())
+	assert.ErrorIs(err, network.ErrSubnetNotFound)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetSubnetInfo(context.Background(), subnetID)
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+// P-Chain API client whose GetBlockchains method always returns [blockchains].
+// Only GetBlockchains may be called.
+type blockchainsPlatformClient struct {
+	platformvm.Client
+	blockchains []platformvm.APIBlockchain
+}
+
+func (c *blockchainsPlatformClient) GetBlockchains(context.Context, ...rpc.Option) ([]platformvm.APIBlockchain, error) {
+	return c.blockchains, nil
+}
+
+type txStatusPlatformClient struct {
+	platformvm.Client
+	status platformvmstatus.Status
+	err    error
+	// number of tx status queries
+	statusCalls uint32
+}
+
+func (c *txStatusPlatformClient) GetTxStatus(context.Context, ids.ID, ...rpc.Option) (*platformvm.GetTxStatusResponse, error) {
+	atomic.AddUint32(&c.statusCalls, 1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &platformvm.GetTxStatusResponse{Status: c.status}, nil
+}
+
+func (c *txStatusPlatformClient) GetHeight(context.Context, ...rpc.Option) (uint64, error) {
+	return 0, c.err
+}
+
+// P-Chain API client whose GetHeight method always returns [height] and [err]
+type heightPlatformClient struct {
+	platformvm.Client
+	height uint64
+	err    error
+}
+
+func (c *heightPlatformClient) GetHeight(context.Context, ...rpc.Option) (uint64, error) {
+	return c.height, c.err
+}
+
+// P-Chain API client whose GetHeight method returns [height], set atomically,
+// and whose GetBlockchains method always returns [blockchains]
+type chainHeightPlatformClient struct {
+	platformvm.Client
+	height      uint64
+	blockchains []platformvm.APIBlockchain
+}
+
+func (c *chainHeightPlatformClient) GetHeight(context.Context, ...rpc.Option) (uint64, error) {
+	return atomic.LoadUint64(&c.height), nil
+}
+
+func (c *chainHeightPlatformClient) GetBlockchains(context.Context, ...rpc.Option) ([]platformvm.APIBlockchain, error) {
+	return c.blockchains, nil
+}
+
+func TestAwaitChainHeight(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	timestampVMID, err := utils.VMID("timestampvm")
+	assert.NoError(err)
+	nonEVMChainID := ids.GenerateTestID()
+	platformCli := &chainHeightPlatformClient{
+		blockchains: []platformvm.APIBlockchain{{ID: nonEVMChainID, VMID: timestampVMID}},
+	}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	// the heights of non EVM blockchains can't be queried
+	err = net.AwaitChainHeight(context.Background(), nonEVMChainID, 1)
+	assert.ErrorIs(err, errNotEVMChain)
+	_, err = net.GetChainHeight(context.Background(), nonEVMChainID)
+	assert.ErrorIs(err, errNotEVMChain)
+	_, err = net.GetChainHeight(context.Background(), ids.GenerateTestID())
+	assert.Error(err)
+	assert.Contains(err.Error(), "not found")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- net.AwaitChainHeight(context.Background(), constants.PlatformChainID, 1)
+	}()
+	// the network can be modified while waiting
+	time.Sleep(100 * time.Millisecond)
+	locked := make(chan struct{})
+	go func() {
+		net.lock.Lock()
+		net.lock.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * waitForChainHeightPullFrequency):
+		assert.Fail("network lock held while waiting for the chain height")
+	}
+	select {
+	case err := <-errCh:
+		assert.Fail("returned before the height was reached", err)
+	default:
+	}
+	atomic.StoreUint64(&platformCli.height, 1)
+	select {
+	case err := <-errCh:
+		assert.NoError(err)
+	case <-time.After(5 * waitForChainHeightPullFrequency):
+		assert.Fail("height not awaited")
+	}
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.AwaitChainHeight(context.Background(), constants.PlatformChainID, 1), network.ErrStopped)
+}
+
+// Admin API client counting the VM loads
+type loadVMsAdminClient struct {
+	admin.Client
+	loads uint32
+}
+
+func (c *loadVMsAdminClient) LoadVMs(context.Context, ...rpc.Option) (map[ids.ID][]string, map[ids.ID]string, error) {
+	atomic.AddUint32(&c.loads, 1)
+	return nil, nil, nil
+}
+
+type loggerLevelAdminClient struct {
+	admin.Client
+	logLevel     string
+	displayLevel string
+}
+
+func (c *loggerLevelAdminClient) SetLoggerLevel(_ context.Context, _ string, logLevel string, displayLevel string, _ ...rpc.Option) error {
+	c.logLevel = logLevel
+	c.displayLevel = displayLevel
+	return nil
+}
+
+// Admin API client writing each requested profile to [dir], with the kind as contents.
+// Only the profiler methods may be called.
+type profilerAdminClient struct {
+	admin.Client
+	dir        string
+	cpuRunning bool
+}
+
+func (c *profilerAdminClient) MemoryProfile(context.Context, ...rpc.Option) error {
+	return os.WriteFile(filepath.Join(c.dir, memProfileFileName), []byte(network.ProfileHeap), 0o600)
+}
+
+func (c *profilerAdminClient) LockProfile(context.Context, ...rpc.Option) error {
+	return os.WriteFile(filepath.Join(c.dir, lockProfileFileName), []byte(network.ProfileLock), 0o600)
+}
+
+func (c *profilerAdminClient) StartCPUProfiler(context.Context, ...rpc.Option) error {
+	c.cpuRunning = true
+	return nil
+}
+
+func (c *profilerAdminClient) StopCPUProfiler(context.Context, ...rpc.Option) error {
+	c.cpuRunning = false
+	return os.WriteFile(filepath.Join(c.dir, cpuProfileFileName), []byte(network.ProfileCPU), 0o600)
+}
+
+// Returns as current validators the given nodes, adding one more node on each call
+type currentValidatorsPlatformClient struct {
+	platformvm.Client
+	lock    sync.Mutex
+	nodeIDs []ids.NodeID
+	active  int
+}
+
+func (c *currentValidatorsPlatformClient) GetCurrentValidators(context.Context, ids.ID, []ids.NodeID, ...rpc.Option) ([]platformvm.ClientPrimaryValidator, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	vs := []platformvm.ClientPrimaryValidator{}
+	for _, nodeID := range c.nodeIDs[:c.active] {
+		vs = append(vs, platformvm.ClientPrimaryValidator{ClientStaker: platformvm.ClientStaker{NodeID: nodeID}})
+	}
+	if c.active < len(c.nodeIDs) {
+		c.active++
+	}
+	return vs, nil
+}
+
+// the nodes not active yet are pending
+func (c *currentValidatorsPlatformClient) GetPendingValidators(context.Context, ids.ID, []ids.NodeID, ...rpc.Option) ([]interface{}, []interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	vs := []interface{}{}
+	for _, nodeID := range c.nodeIDs[c.active:] {
+		vs = append(vs, map[string]interface{}{"nodeID": nodeID.String()})
+	}
+	return vs, nil, nil
+}
+
+// Returns the given current and pending validators of every subnet
+type subnetValidatorsPlatformClient struct {
+	platformvm.Client
+	current []ids.NodeID
+	pending []ids.NodeID
+}
+
+func (c *subnetValidatorsPlatformClient) GetCurrentValidators(context.Context, ids.ID, []ids.NodeID, ...rpc.Option) ([]platformvm.ClientPrimaryValidator, error) {
+	vs := []platformvm.ClientPrimaryValidator{}
+	for _, nodeID := range c.current {
+		vs = append(vs, platformvm.ClientPrimaryValidator{ClientStaker: platformvm.ClientStaker{NodeID: nodeID}})
+	}
+	return vs, nil
+}
+
+func (c *subnetValidatorsPlatformClient) GetPendingValidators(context.Context, ids.ID, []ids.NodeID, ...rpc.Option) ([]interface{}, []interface{}, error) {
+	vs := []interface{}{}
+	for _, nodeID := range c.pending {
+		vs = append(vs, map[string]interface{}{"nodeID": nodeID.String()})
+	}
+	return vs, nil, nil
+}
+
+func TestAddSubnetValidatorsSkipsValidators(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	subnetID := ids.GenerateTestID()
+	// the first node doesn't track the subnet
+	for i := range networkConfig.NodeConfigs[1:] {
+		networkConfig.NodeConfigs[i+1].Flags[config.WhitelistedSubnetsKey] = subnetID.String()
+	}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	nonTrackingNodeID := net.nodes[networkConfig.NodeConfigs[0].Name].GetNodeID()
+	nodeIDs := []ids.NodeID{nonTrackingNodeID}
+	for _, node := range net.nodes {
+		if node.GetNodeID() != nonTrackingNodeID {
+			nodeIDs = append(nodeIDs, node.GetNodeID())
+		}
+	}
+	// no tx is issued, so no wallet is needed, and the tracked subnets of the
+	// skipped nodes are not checked
+	platformCli := &subnetValidatorsPlatformClient{current: nodeIDs[:1], pending: nodeIDs[1:]}
+	err = net.addSubnetValidators(context.Background(), platformCli, nil, []ids.ID{subnetID}, net.nodes, network.NewSetupOp())
+	assert.NoError(err)
+	// the nodes that would get a tx must track the subnet
+	platformCli = &subnetValidatorsPlatformClient{pending: nodeIDs[1:]}
+	err = net.addSubnetValidators(context.Background(), platformCli, nil, []ids.ID{subnetID}, net.nodes, network.NewSetupOp())
+	assert.ErrorContains(err, "is not configured to track subnet")
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestWhitelistSubnet(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	subnetID, otherSubnetID := ids.GenerateTestID(), ids.GenerateTestID()
+	// added to the network default
+	net.flags = map[string]interface{}{config.WhitelistedSubnetsKey: otherSubnetID.String()}
+	nodeConfig := node.Config{Name: "new"}
+	assert.NoError(net.whitelistSubnet(&nodeConfig, subnetID))
+	whitelistedSubnetIDs := []string{otherSubnetID.String(), subnetID.String()}
+	sort.Strings(whitelistedSubnetIDs)
+	assert.Equal(strings.Join(whitelistedSubnetIDs, ","), nodeConfig.Flags[config.WhitelistedSubnetsKey])
+	assert.Equal(otherSubnetID.String(), net.flags[config.WhitelistedSubnetsKey])
+	// already tracked by the config file
+	net.flags = map[string]interface{}{}
+	nodeConfig = node.Config{Name: "new", ConfigFile: fmt.Sprintf(`{%q:%q}`, config.WhitelistedSubnetsKey, subnetID)}
+	assert.NoError(net.whitelistSubnet(&nodeConfig, subnetID))
+	assert.NotContains(nodeConfig.Flags, config.WhitelistedSubnetsKey)
+	// the node flag takes precedence over the config file
+	nodeConfig = node.Config{
+		Name:       "new",
+		ConfigFile: fmt.Sprintf(`{%q:%q}`, config.WhitelistedSubnetsKey, otherSubnetID),
+		Flags:      map[string]interface{}{config.WhitelistedSubnetsKey: ""},
+	}
+	assert.NoError(net.whitelistSubnet(&nodeConfig, subnetID))
+	assert.Equal(subnetID.String(), nodeConfig.Flags[config.WhitelistedSubnetsKey])
+}
+
+func TestAddSubnetValidatorNodeValidation(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	_, err = net.AddSubnetValidatorNode(context.Background(), node.Config{Name: "new"}, constants.PrimaryNetworkID, "")
+	assert.Error(err)
+	_, err = net.AddSubnetValidatorNode(context.Background(), node.Config{Name: "new"}, ids.GenerateTestID(), "PrivateKey-invalid")
+	assert.Error(err)
+	// no node is added on invalid arguments
+	assert.NotContains(net.nodes, "new")
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.AddSubnetValidatorNode(context.Background(), node.Config{Name: "new"}, ids.GenerateTestID(), "")
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+// P-Chain API client giving the validators added by [validatorsPWallet] txs,
+// with all the txs committed
+type addedValidatorsPlatformClient struct {
+	platformvm.Client
+	lock sync.Mutex
+	// control key of every subnet
+	subnetOwner ids.ShortID
+	// subnet ID --> node ID --> validation end time
+	current map[ids.ID]map[ids.NodeID]uint64
+	pending map[ids.ID]map[ids.NodeID]uint64
+	// start time of every pending validation
+	pendingStartTime uint64
+}
+
+func (c *addedValidatorsPlatformClient) GetCurrentValidators(_ context.Context, subnetID ids.ID, _ []ids.NodeID, _ ...rpc.Option) ([]platformvm.ClientPrimaryValidator, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	vs := []platformvm.ClientPrimaryValidator{}
+	for nodeID, endTime := range c.current[subnetID] {
+		vs = append(vs, platformvm.ClientPrimaryValidator{ClientStaker: platformvm.ClientStaker{NodeID: nodeID, EndTime: endTime}})
+	}
+	return vs, nil
+}
+
+func (c *addedValidatorsPlatformClient) GetPendingValidators(_ context.Context, subnetID ids.ID, _ []ids.NodeID, _ ...rpc.Option) ([]interface{}, []interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	vs := []interface{}{}
+	for nodeID, endTime := range c.pending[subnetID] {
+		vs = append(vs, map[string]interface{}{
+			"nodeID":    nodeID.String(),
+			"startTime": strconv.FormatUint(c.pendingStartTime, 10),
+			"endTime":   strconv.FormatUint(endTime, 10),
+		})
+	}
+	return vs, nil, nil
+}
+
+func (c *addedValidatorsPlatformClient) GetSubnets(_ context.Context, subnetIDs []ids.ID, _ ...rpc.Option) ([]platformvm.ClientSubnet, error) {
+	subnets := []platformvm.ClientSubnet{}
+	for _, subnetID := range subnetIDs {
+		subnets = append(subnets, platformvm.ClientSubnet{ID: subnetID, ControlKeys: []ids.ShortID{c.subnetOwner}, Threshold: 1})
+	}
+	return subnets, nil
+}
+
+func (*addedValidatorsPlatformClient) GetTxStatus(context.Context, ids.ID, ...rpc.Option) (*platformvm.GetTxStatusResponse, error) {
+	return &platformvm.GetTxStatusResponse{Status: platformvmstatus.Committed}, nil
+}
+
+func (*addedValidatorsPlatformClient) GetHeight(context.Context, ...rpc.Option) (uint64, error) {
+	return 0, nil
+}
+
+func (c *addedValidatorsPlatformClient) addValidator(validators map[ids.ID]map[ids.NodeID]uint64, subnetID ids.ID, nodeID ids.NodeID, endTime uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if validators[subnetID] == nil {
+		validators[subnetID] = map[ids.NodeID]uint64{}
+	}
+	validators[subnetID][nodeID] = endTime
+}
+
+// Wallet issuing validator txs to [platformCli]: primary network validators
+// are pending, and subnet validators current at once
+type validatorsWallet struct {
+	primary.Wallet
+	pWallet *validatorsPWallet
+}
+
+func (w *validatorsWallet) P() p.Wallet {
+	return w.pWallet
+}
+
+type validatorsPWallet struct {
+	p.Wallet
+	platformCli *addedValidatorsPlatformClient
+	// node IDs of the validator txs issued
+	primaryValidators []ids.NodeID
+	subnetValidators  []validator.SubnetValidator
+	delegators        []validator.Validator
+}
+
+func (*validatorsPWallet) AVAXAssetID() ids.ID {
+	return ids.Empty
+}
+
+func (*validatorsPWallet) Builder() p.Builder {
+	return &balanceBuilder{}
+}
+
+func (w *validatorsPWallet) IssueAddValidatorTx(vdr *validator.Validator, _ *secp256k1fx.OutputOwners, _ uint32, _ ...common.Option) (ids.ID, error) {
+	w.primaryValidators = append(w.primaryValidators, vdr.NodeID)
+	w.platformCli.addValidator(w.platformCli.pending, constants.PrimaryNetworkID, vdr.NodeID, vdr.End)
+	return ids.GenerateTestID(), nil
+}
+
+func (w *validatorsPWallet) IssueAddSubnetValidatorTx(vdr *validator.SubnetValidator, _ ...common.Option) (ids.ID, error) {
+	w.subnetValidators = append(w.subnetValidators, *vdr)
+	w.platformCli.addValidator(w.platformCli.current, vdr.Subnet, vdr.NodeID, vdr.End)
+	return ids.GenerateTestID(), nil
+}
+
+func (w *validatorsPWallet) IssueAddDelegatorTx(vdr *validator.Validator, _ *secp256k1fx.OutputOwners, _ ...common.Option) (ids.ID, error) {
+	w.delegators = append(w.delegators, *vdr)
+	return ids.GenerateTestID(), nil
+}
+
+// P-Chain builder with enough AVAX for the setup
+type balanceBuilder struct {
+	p.Builder
+}
+
+func (*balanceBuilder) GetBalance(...common.Option) (map[ids.ID]uint64, error) {
+	return map[ids.ID]uint64{ids.Empty: 1000 * units.Avax}, nil
+}
+
+func TestAddDelegators(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	pendingStartTime := time.Now().Add(time.Hour)
+	platformCli := &addedValidatorsPlatformClient{
+		current:          map[ids.ID]map[ids.NodeID]uint64{},
+		pending:          map[ids.ID]map[ids.NodeID]uint64{},
+		pendingStartTime: uint64(pendingStartTime.Unix()),
+	}
+	currentEndTime := uint64(time.Now().Add(2 * time.Hour).Unix())
+	pendingEndTime := uint64(time.Now().Add(3 * time.Hour).Unix())
+	// node1 is a current validator, node2 a pending one, and node0 no validator
+	platformCli.addValidator(platformCli.current, constants.PrimaryNetworkID, net.nodes["node1"].GetNodeID(), currentEndTime)
+	platformCli.addValidator(platformCli.pending, constants.PrimaryNetworkID, net.nodes["node2"].GetNodeID(), pendingEndTime)
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	addDelegators := func(delegators []network.DelegatorSpec) ([]validator.Validator, error) {
+		pWallet := &validatorsPWallet{platformCli: platformCli}
+		err := net.addDelegators(context.Background(), platformCli, &validatorsWallet{pWallet: pWallet}, ids.GenerateTestShortID(), delegators, network.NewSetupOp())
+		return pWallet.delegators, err
+	}
+
+	// no tx is issued without delegators
+	delegators, err := addDelegators(nil)
+	assert.NoError(err)
+	assert.Empty(delegators)
+	// current and pending validators are delegated to
+	delegators, err = addDelegators([]network.DelegatorSpec{
+		{NodeName: "node1", Weight: units.Avax},
+		{NodeName: "node2", Weight: 2 * units.Avax},
+	})
+	assert.NoError(err)
+	assert.Len(delegators, 2)
+	assert.Equal(net.nodes["node1"].GetNodeID(), delegators[0].NodeID)
+	assert.Equal(currentEndTime, delegators[0].End)
+	assert.EqualValues(units.Avax, delegators[0].Wght)
+	assert.Equal(net.nodes["node2"].GetNodeID(), delegators[1].NodeID)
+	assert.Equal(pendingEndTime, delegators[1].End)
+	// the delegation to a pending validator doesn't start before the validation
+	assert.Equal(uint64(pendingStartTime.Unix()), delegators[1].Start)
+	// the delegations to unknown nodes, nodes not validating, or without weight are rejected
+	for _, tt := range []struct {
+		delegator   network.DelegatorSpec
+		expectedErr string
+	}{
+		{network.DelegatorSpec{NodeName: "unknown", Weight: units.Avax}, "not found"},
+		{network.DelegatorSpec{NodeName: "node0", Weight: units.Avax}, "is not a primary network validator"},
+		{network.DelegatorSpec{NodeName: "node1"}, "zero weight"},
+	} {
+		delegators, err = addDelegators([]network.DelegatorSpec{tt.delegator})
+		assert.Error(err)
+		assert.Contains(err.Error(), tt.expectedErr)
+		assert.Empty(delegators)
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestAddSubnetValidatorNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	_, fundedKey, err := setupKeychain(network.NewSetupOp())
+	assert.NoError(err)
+	platformCli := &addedValidatorsPlatformClient{
+		subnetOwner: fundedKey.PublicKey().Address(),
+		current:     map[ids.ID]map[ids.NodeID]uint64{},
+		pending:     map[ids.ID]map[ids.NodeID]uint64{},
+	}
+	primaryEndTime := uint64(time.Now().Add(time.Hour).Unix())
+	// the other nodes are primary network validators, and don't track the subnet
+	for _, node := range net.nodes {
+		platformCli.addValidator(platformCli.current, constants.PrimaryNetworkID, node.GetNodeID(), primaryEndTime)
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	net.newAPIClientF = func(ipAddr string, port uint16, _ *http.Client) (api.Client, error) {
+		client, err := newMockAPISuccessful(ipAddr, port, nil)
+		if err != nil {
+			return nil, err
+		}
+		client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+		return client, nil
+	}
+	wallet := &validatorsWallet{pWallet: &validatorsPWallet{platformCli: platformCli}}
+	net.newWalletF = func(context.Context, api.Client, *secp256k1fx.Keychain, ...ids.ID) (primary.Wallet, error) {
+		return wallet, nil
+	}
+	subnetID := ids.GenerateTestID()
+	newNode, err := net.AddSubnetValidatorNode(context.Background(), node.Config{Name: "new"}, subnetID, "", network.WithPlatformClient(platformCli))
+	assert.NoError(err)
+	nodeID := newNode.GetNodeID()
+	// only the new node is added as validator
+	assert.Equal([]ids.NodeID{nodeID}, wallet.pWallet.primaryValidators)
+	assert.Len(wallet.pWallet.subnetValidators, 1)
+	subnetValidator := wallet.pWallet.subnetValidators[0]
+	assert.Equal(nodeID, subnetValidator.NodeID)
+	assert.Equal(subnetID, subnetValidator.Subnet)
+	// ends with the pending primary network validation
+	assert.Equal(platformCli.pending[constants.PrimaryNetworkID][nodeID], subnetValidator.End)
+	assert.NotZero(subnetValidator.End)
+	trackedSubnets, err := newNode.GetTrackedSubnets(context.Background())
+	assert.NoError(err)
+	assert.Contains(trackedSubnets, subnetID)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestWaitSubnetValidators(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	nodeIDs := []ids.NodeID{}
+	for _, node := range net.nodes {
+		nodeIDs = append(nodeIDs, node.GetNodeID())
+	}
+	subnetIDs := []ids.ID{ids.GenerateTestID()}
+	// returns once all the nodes are active validators
+	platformCli := &currentValidatorsPlatformClient{nodeIDs: nodeIDs}
+	err = net.waitSubnetValidators(context.Background(), platformCli, subnetIDs, net.nodes, network.NewSetupOp())
+	assert.NoError(err)
+	assert.Equal(len(nodeIDs), platformCli.active)
+	// reports the nodes not active on timeout
+	platformCli = &currentValidatorsPlatformClient{nodeIDs: nodeIDs[:1]}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*waitForValidatorsPullFrequency)
+	defer cancel()
+	err = net.waitSubnetValidators(ctx, platformCli, subnetIDs, net.nodes, network.NewSetupOp())
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Contains(err.Error(), subnetIDs[0].String())
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetExistingBlockchainIDs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	vmID, err := utils.VMID("vm")
+	assert.NoError(err)
+	subnetID, otherSubnetID := ids.GenerateTestID(), ids.GenerateTestID()
+	blockchainID := ids.GenerateTestID()
+	platformCli := &blockchainsPlatformClient{
+		blockchains: []platformvm.APIBlockchain{
+			{ID: blockchainID, Name: "vm", SubnetID: subnetID, VMID: vmID},
+			// same name but another VM
+			{ID: ids.GenerateTestID(), Name: "vm", SubnetID: otherSubnetID, VMID: ids.GenerateTestID()},
+		},
+	}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	subnetIDStr, otherSubnetIDStr := subnetID.String(), otherSubnetID.String()
+	blockchainIDs, err := net.getExistingBlockchainIDs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm", SubnetId: &subnetIDStr},
+		{VmName: "vm", SubnetId: &otherSubnetIDStr},
+		{VmName: "other", SubnetId: &subnetIDStr},
+	})
+	assert.NoError(err)
+	assert.Equal([]ids.ID{blockchainID, ids.Empty, ids.Empty}, blockchainIDs)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetBlockchainID(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	blockchainID := ids.GenerateTestID()
+	platformCli := &blockchainsPlatformClient{
+		blockchains: []platformvm.APIBlockchain{
+			{ID: blockchainID, Name: "unique"},
+			{ID: ids.GenerateTestID(), Name: "repeated"},
+			{ID: ids.GenerateTestID(), Name: "repeated"},
+		},
+	}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	id, err := net.GetBlockchainID(context.Background(), "unique")
+	assert.NoError(err)
+	assert.Equal(blockchainID, id)
+	_, err = net.GetBlockchainID(context.Background(), "repeated")
+	assert.Error(err)
+	_, err = net.GetBlockchainID(context.Background(), "unknown")
+	assert.Error(err)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetBlockchainID(context.Background(), "unique")
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestGetBlockchains(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	platformCli := &blockchainsPlatformClient{
+		blockchains: []platformvm.APIBlockchain{
+			{ID: ids.GenerateTestID(), Name: "C-Chain", SubnetID: constants.PrimaryNetworkID, VMID: ids.GenerateTestID()},
+			{ID: ids.GenerateTestID(), Name: "subnetevm", SubnetID: ids.GenerateTestID(), VMID: ids.GenerateTestID()},
+		},
+	}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	blockchains, err := net.GetBlockchains(context.Background())
+	assert.NoError(err)
+	assert.Len(blockchains, len(platformCli.blockchains))
+	for i, blockchain := range platformCli.blockchains {
+		assert.Equal(network.BlockchainInfo{
+			Name:     blockchain.Name,
+			ID:       blockchain.ID,
+			SubnetID: blockchain.SubnetID,
+			VMID:     blockchain.VMID,
+		}, blockchains[i])
+	}
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetBlockchains(context.Background())
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestGetPendingSubnetValidators(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	nodeIDs := []ids.NodeID{}
+	for _, node := range net.nodes {
+		nodeIDs = append(nodeIDs, node.GetNodeID())
+	}
+	ids.SortNodeIDs(nodeIDs)
+	// first node is active, the others are pending
+	platformCli := &currentValidatorsPlatformClient{nodeIDs: nodeIDs, active: 1}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	pendingNodeIDs, err := net.GetPendingSubnetValidators(context.Background(), ids.GenerateTestID())
+	assert.NoError(err)
+	assert.Equal(nodeIDs[1:], pendingNodeIDs)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetPendingSubnetValidators(context.Background(), ids.GenerateTestID())
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestAwaitTxsCommitted(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	clients := map[string]api.Client{}
+	for nodeName, node := range net.nodes {
+		clients[nodeName] = node.client
+	}
+	setPlatformClients := func(unreachableStatus *txStatusPlatformClient) {
+		for nodeName, node := range net.nodes {
+			client := &apimocks.Client{}
+			if nodeName == "node1" {
+				client.On("PChainAPI").Return(unreachableStatus)
+			} else {
+				client.On("PChainAPI").Return(&txStatusPlatformClient{status: platformvmstatus.Committed})
+			}
+			node.client = client
+		}
+	}
+	txIDs := []ids.ID{ids.GenerateTestID(), ids.GenerateTestID()}
+	// all nodes commit the txs
+	setPlatformClients(&txStatusPlatformClient{status: platformvmstatus.Committed})
+	assert.NoError(net.awaitTxsCommitted(context.Background(), txIDs, network.NewSetupOp()))
+	// by default all nodes must confirm the txs
+	setPlatformClients(&txStatusPlatformClient{err: errors.New("connection refused")})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = net.awaitTxsCommitted(ctx, txIDs, network.NewSetupOp())
+	assert.Error(err)
+	assert.Contains(err.Error(), "node \"node1\"")
+	assert.Contains(err.Error(), "connection refused")
+	// an unreachable node can be tolerated
+	assert.NoError(net.awaitTxsCommitted(context.Background(), txIDs, network.NewSetupOp(network.WithMaxUnconfirmedNodes(1))))
+	// a rejected tx fails the wait even if tolerated
+	for _, node := range net.nodes {
+		client := &apimocks.Client{}
+		client.On("PChainAPI").Return(&txStatusPlatformClient{status: platformvmstatus.Dropped})
+		node.client = client
+	}
+	err = net.awaitTxsCommitted(context.Background(), txIDs, network.NewSetupOp(network.WithMaxUnconfirmedNodes(1)))
+	assert.ErrorIs(err, errTxRejected)
+	// some node must confirm the txs, even if all may be unconfirmed
+	for _, node := range net.nodes {
+		client := &apimocks.Client{}
+		client.On("PChainAPI").Return(&txStatusPlatformClient{err: errors.New("connection refused")})
+		node.client = client
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = net.awaitTxsCommitted(ctx, txIDs, network.NewSetupOp(network.WithMaxUnconfirmedNodes(uint32(len(net.nodes)))))
+	assert.Error(err)
+	assert.Contains(err.Error(), "1 required")
+	// the statuses of pending txs are not queried again until the height changes
+	processingStatus := &txStatusPlatformClient{status: platformvmstatus.Processing}
+	setPlatformClients(processingStatus)
+	ctx, cancel = context.WithTimeout(context.Background(), 3*waitForTxCommittedPullFrequency)
+	defer cancel()
+	err = net.awaitTxsCommitted(ctx, txIDs, network.NewSetupOp())
+	assert.Error(err)
+	assert.Contains(err.Error(), "Processing")
+	assert.EqualValues(len(txIDs), atomic.LoadUint32(&processingStatus.statusCalls))
+	for nodeName, node := range net.nodes {
+		node.client = clients[nodeName]
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestAdminAPIEnabled(t *testing.T) {
+	t.Parallel()
+	enabledArg := "--" + config.AdminAPIEnabledKey
+	tests := []struct {
+		name     string
+		config   node.Config
+		expected bool
+	}{
+		{"default", node.Config{}, false},
+		{"config file", node.Config{ConfigFile: `{"api-admin-enabled": true}`}, true},
+		{
+			"flags over config file",
+			node.Config{
+				Flags:      map[string]interface{}{config.AdminAPIEnabledKey: false},
+				ConfigFile: `{"api-admin-enabled": true}`,
+			},
+			false,
+		},
+		{
+			"extra args over flags",
+			node.Config{
+				Flags:     map[string]interface{}{config.AdminAPIEnabledKey: false},
+				ExtraArgs: []string{enabledArg + "=true"},
+			},
+			true,
+		},
+		{"extra arg without value", node.Config{ExtraArgs: []string{enabledArg}}, true},
+		{
+			"last extra arg",
+			node.Config{ExtraArgs: []string{enabledArg, "--log-level=debug", enabledArg + "=false"}},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			node := &localNode{config: tt.config}
+			enabled, err := node.adminAPIEnabled()
+			assert.NoError(err)
+			assert.Equal(tt.expected, enabled)
+		})
+	}
+	node := &localNode{config: node.Config{ExtraArgs: []string{enabledArg + "=maybe"}}}
+	_, err := node.adminAPIEnabled()
+	assert.Error(t, err)
+}
+
+func TestSetLogLevel(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs[1].Flags = map[string]interface{}{
+		config.AdminAPIEnabledKey: false,
+	}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	adminCli := &loggerLevelAdminClient{}
+	net.nodes["node0"].client.(*apimocks.Client).On("AdminAPI").Return(adminCli)
+	assert.NoError(net.SetLogLevel(context.Background(), "node0", logging.Debug))
+	assert.Equal(logging.Debug.String(), adminCli.logLevel)
+	assert.Equal(logging.Debug.String(), adminCli.displayLevel)
+	err = net.SetLogLevel(context.Background(), "node1", logging.Debug)
+	assert.ErrorIs(err, ErrAdminAPIDisabled)
+	err = net.SetLogLevel(context.Background(), "unknown", logging.Debug)
+	assert.ErrorIs(err, network.ErrNodeNotFound)
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.SetLogLevel(context.Background(), "node0", logging.Debug), network.ErrStopped)
+}
+
+func TestGetProfile(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs[1].Flags = map[string]interface{}{
+		config.AdminAPIEnabledKey: false,
+	}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// each node gets its own profile dir
+	node := net.nodes["node0"]
+	assert.Equal(filepath.Join(net.rootDir, "node0", defaultProfilesSubdir), node.profileDir)
+	assert.NoError(os.MkdirAll(node.profileDir, os.ModePerm))
+	adminCli := &profilerAdminClient{dir: node.profileDir}
+	node.client.(*apimocks.Client).On("AdminAPI").Return(adminCli)
+	for _, kind := range []network.ProfileKind{network.ProfileHeap, network.ProfileLock, network.ProfileCPU} {
+		profile, err := net.GetProfile(context.Background(), "node0", kind, 10*time.Millisecond)
+		assert.NoError(err)
+		assert.Equal(string(kind), string(profile))
+	}
+	assert.False(adminCli.cpuRunning)
+	// the CPU profiler is stopped on cancellation
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = net.GetProfile(ctx, "node0", network.ProfileCPU, time.Minute)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.False(adminCli.cpuRunning)
+	_, err = net.GetProfile(context.Background(), "node0", network.ProfileCPU, 0)
+	assert.Error(err)
+	_, err = net.GetProfile(context.Background(), "node0", "goroutine", 0)
+	assert.Error(err)
+	_, err = net.GetProfile(context.Background(), "node1", network.ProfileHeap, 0)
+	assert.ErrorIs(err, ErrAdminAPIDisabled)
+	_, err = net.GetProfile(context.Background(), "unknown", network.ProfileHeap, 0)
+	assert.ErrorIs(err, network.ErrNodeNotFound)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.GetProfile(context.Background(), "node0", network.ProfileHeap, 0)
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestNodeAPITimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs[0].APITimeout = 5 * time.Second
+	var (
+		lock        sync.Mutex
+		httpClients = map[uint16]*http.Client{}
+	)
+	net, err := newNetwork(logging.NoLog{}, func(ipAddr string, port uint16, httpClient *http.Client) (api.Client, error) {
+		lock.Lock()
+		httpClients[port] = httpClient
+		lock.Unlock()
+		return newMockAPISuccessful(ipAddr, port, httpClient)
+	}, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	for _, nodeConfig := range networkConfig.NodeConfigs {
+		node, err := net.GetNode(nodeConfig.Name)
+		assert.NoError(err)
+		expectedTimeout := nodeConfig.APITimeout
+		if expectedTimeout == 0 {
+			expectedTimeout = defaultAPITimeout
+		}
+		assert.Equal(expectedTimeout, node.GetAPITimeout())
+		// the requests of the API client of the node are limited by its timeout
+		assert.Equal(expectedTimeout, httpClients[node.GetAPIPort()].Timeout)
+		// requests to the node are limited by its timeout
+		ctx, cancel := createNodeCtx(context.Background(), node)
+		deadline, ok := ctx.Deadline()
+		cancel()
+		assert.True(ok)
+		assert.WithinDuration(time.Now().Add(expectedTimeout), deadline, time.Second)
+	}
+	// the caller context still applies if shorter
+	node, err := net.GetNode(networkConfig.NodeConfigs[0].Name)
+	assert.NoError(err)
+	parentCtx, parentCancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer parentCancel()
+	ctx, cancel := createNodeCtx(parentCtx, node)
+	defer cancel()
+	<-ctx.Done()
+	assert.ErrorIs(ctx.Err(), context.DeadlineExceeded)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestValidateBlockchainSpecs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	errInvalid := errors.New("invalid genesis")
+	assert.NoError(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{"config":{}}`)},
+		{VmName: "vm2", Genesis: []byte("not json"), GenesisValidator: func([]byte) error { return nil }},
+	}))
+	assert.Error(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: nil},
+	}))
+	assert.Error(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte("not json")},
+	}))
+	err := validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), GenesisValidator: func([]byte) error { return errInvalid }},
+	})
+	assert.ErrorIs(err, errInvalid)
+	// chain configs and fx IDs
+	assert.NoError(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), ChainConfig: []byte(`{"log-level":"debug"}`), ChainConfigRequired: true},
+		{VmName: "vm2", Genesis: []byte(`{}`), FxIDs: []string{ids.GenerateTestID().String()}},
+	}))
+	assert.Error(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), ChainConfigRequired: true},
+	}))
+	assert.Error(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), ChainConfig: []byte("not json")},
+	}))
+	assert.Error(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), FxIDs: []string{"not an id"}},
+	}))
+	// genesis templates
+	assert.NoError(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", GenesisTemplate: `{"networkID":{{.NetworkID}}}`},
+	}))
+	assert.Error(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), GenesisTemplate: `{"networkID":{{.NetworkID}}}`},
+	}))
+	assert.Error(validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", GenesisTemplate: `{"networkID":{{.NetworkID}`},
+	}))
+}
+
+func TestRenderGenesisTemplates(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	fundedAddresses, err := network.FundedAddresses(net.genesis)
+	assert.NoError(err)
+	assert.NotEmpty(fundedAddresses)
+	subnetID := ids.GenerateTestID().String()
+	tmpl := `{"networkID":{{.NetworkID}},"subnet":"{{.SubnetID}}","alloc":[{{range $i, $a := .FundedAddresses}}{{if $i}},{{end}}"{{$a}}"{{end}}]}`
+	chainSpecs := []network.BlockchainSpec{
+		{VmName: "vm1", GenesisTemplate: tmpl, SubnetId: &subnetID},
+		{VmName: "vm2", Genesis: []byte(`{}`), SubnetId: &subnetID},
+	}
+	renderedSpecs, err := net.renderGenesisTemplates(chainSpecs)
+	assert.NoError(err)
+	var rendered struct {
+		NetworkID uint32   `json:"networkID"`
+		Subnet    string   `json:"subnet"`
+		Alloc     []string `json:"alloc"`
+	}
+	assert.NoError(json.Unmarshal(renderedSpecs[0].Genesis, &rendered))
+	assert.Equal(net.networkID, rendered.NetworkID)
+	assert.Equal(subnetID, rendered.Subnet)
+	assert.Equal(fundedAddresses, rendered.Alloc)
+	assert.Empty(renderedSpecs[0].GenesisTemplate)
+	assert.Equal([]byte(`{}`), renderedSpecs[1].Genesis)
+	// the given specs are not modified
+	assert.Empty(chainSpecs[0].Genesis)
+	// the rendered genesis is validated
+	chainSpecs[0].GenesisTemplate = `{"networkID":{{.NetworkID}}`
+	_, err = net.renderGenesisTemplates(chainSpecs)
+	assert.Error(err)
+	errInvalid := errors.New("invalid genesis")
+	chainSpecs[0].GenesisTemplate = tmpl
+	chainSpecs[0].GenesisValidator = func([]byte) error { return errInvalid }
+	_, err = net.renderGenesisTemplates(chainSpecs)
+	assert.ErrorIs(err, errInvalid)
+	// unknown values
+	chainSpecs[0].GenesisTemplate = `{{.Unknown}}`
+	chainSpecs[0].GenesisValidator = nil
+	_, err = net.renderGenesisTemplates(chainSpecs)
+	assert.Error(err)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestRestartNodesWithChainConfigs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	chainConfig := `{"log-level":"debug"}`
+	chainSpecs := []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), ChainConfig: []byte(chainConfig)},
+		{VmName: "vm2", Genesis: []byte(`{}`)},
+	}
+	blockchainIDs := []ids.ID{ids.GenerateTestID(), ids.GenerateTestID()}
+	err = net.restartNodesWithChainConfigs(context.Background(), chainSpecs, blockchainIDs)
+	assert.NoError(err)
+	assert.Len(net.nodes, len(networkConfig.NodeConfigs))
+	for _, node := range net.nodes {
+		chainConfigFiles := node.GetConfig().ChainConfigFiles
+		assert.Equal(chainConfig, chainConfigFiles[blockchainIDs[0].String()])
+		_, ok := chainConfigFiles[blockchainIDs[1].String()]
+		assert.False(ok)
+	}
+	// nodes added later also get the chain config
+	newNode, err := net.AddNode(node.Config{})
+	assert.NoError(err)
+	assert.Equal(chainConfig, newNode.GetConfig().ChainConfigFiles[blockchainIDs[0].String()])
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestRestartNodesWithUpgradeConfigs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	upgradeConfig := `{"precompileUpgrades":[{"feeManagerConfig":{"blockTimestamp":1668950000}}]}`
+	chainSpecs := []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), UpgradeConfig: []byte(upgradeConfig)},
+		{VmName: "vm2", Genesis: []byte(`{}`)},
+	}
+	// invalid JSON is rejected before any tx is issued
+	err = validateBlockchainSpecs(context.Background(), []network.BlockchainSpec{
+		{VmName: "vm1", Genesis: []byte(`{}`), UpgradeConfig: []byte(`{`)},
+	})
+	assert.ErrorContains(err, "upgrade config")
+	blockchainIDs := []ids.ID{ids.GenerateTestID(), ids.GenerateTestID()}
+	oldNodes := map[string]*localNode{}
+	for nodeName, node := range net.nodes {
+		oldNodes[nodeName] = node
+	}
+	err = net.restartNodesWithChainConfigs(context.Background(), chainSpecs, blockchainIDs)
+	assert.NoError(err)
+	assert.Len(net.nodes, len(networkConfig.NodeConfigs))
+	for nodeName, node := range net.nodes {
+		// restarted with the upgrade config, and no chain config
+		assert.NotSame(oldNodes[nodeName], node)
+		assert.Empty(node.GetConfig().ChainConfigFiles[blockchainIDs[0].String()])
+		upgradeConfigFiles := node.GetConfig().UpgradeConfigFiles
+		assert.Equal(upgradeConfig, upgradeConfigFiles[blockchainIDs[0].String()])
+		_, ok := upgradeConfigFiles[blockchainIDs[1].String()]
+		assert.False(ok)
+	}
+	// nodes added later also get the upgrade config
+	newNode, err := net.AddNode(node.Config{})
+	assert.NoError(err)
+	assert.Equal(upgradeConfig, newNode.GetConfig().UpgradeConfigFiles[blockchainIDs[0].String()])
+	assert.NoError(net.Stop(context.Background()))
+}
+
+// P-Chain API client listing [subnets] and [blockchains], as subnetsPlatformClient,
+// whose GetCurrentValidators method returns the nodes of [validators] for the subnet
+type topologyPlatformClient struct {
+	subnetsPlatformClient
+	// subnet ID --> validators
+	validators map[ids.ID][]ids.NodeID
+}
+
+func (c *topologyPlatformClient) GetCurrentValidators(_ context.Context, subnetID ids.ID, _ []ids.NodeID, _ ...rpc.Option) ([]platformvm.ClientPrimaryValidator, error) {
+	vs := []platformvm.ClientPrimaryValidator{}
+	for _, nodeID := range c.validators[subnetID] {
+		vs = append(vs, platformvm.ClientPrimaryValidator{ClientStaker: platformvm.ClientStaker{NodeID: nodeID}})
+	}
+	return vs, nil
+}
+
+func TestExportTopology(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs[2].IsBeacon = false
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	subnetID := ids.GenerateTestID()
+	blockchainID := ids.GenerateTestID()
+	vmID := ids.GenerateTestID()
+	platformCli := &topologyPlatformClient{
+		subnetsPlatformClient: subnetsPlatformClient{
+			subnets: []platformvm.ClientSubnet{{ID: constants.PrimaryNetworkID}, {ID: subnetID}},
+			blockchains: []platformvm.APIBlockchain{
+				{ID: blockchainID, Name: "chain", SubnetID: subnetID, VMID: vmID},
+			},
+		},
+		validators: map[ids.ID][]ids.NodeID{
+			constants.PrimaryNetworkID: {net.nodes["node0"].nodeID, net.nodes["node1"].nodeID, net.nodes["node2"].nodeID},
+			subnetID:                   {net.nodes["node1"].nodeID},
+		},
+	}
+	for _, node := range net.nodes {
+		node.client.(*apimocks.Client).On("PChainAPI").Return(platformCli)
+	}
+	topologyJSON, err := net.ExportTopology(context.Background())
+	assert.NoError(err)
+	topology := []nodeTopology{}
+	assert.NoError(json.Unmarshal(topologyJSON, &topology))
+	// nodes are sorted by name
+	assert.Len(topology, 3)
+	for i, nodeTopology := range topology {
+		nodeName := fmt.Sprintf("node%d", i)
+		node := net.nodes[nodeName]
+		assert.Equal(nodeName, nodeTopology.Name)
+		assert.Equal(node.nodeID.String(), nodeTopology.NodeID)
+		assert.Equal(node.GetURL(), nodeTopology.URL)
+		assert.Equal(node.apiPort, nodeTopology.APIPort)
+		assert.Equal(node.p2pPort, nodeTopology.StakingPort)
+		assert.NotZero(nodeTopology.APIPort)
+		assert.NotZero(nodeTopology.StakingPort)
+		assert.Equal(networkConfig.NodeConfigs[i].IsBeacon, nodeTopology.Beacon)
+	}
+	assert.True(topology[0].Beacon)
+	assert.False(topology[2].Beacon)
+	primaryNetwork := subnetTopology{ID: constants.PrimaryNetworkID.String()}
+	assert.Equal([]subnetTopology{primaryNetwork}, topology[0].Subnets)
+	assert.Equal([]subnetTopology{
+		primaryNetwork,
+		{
+			ID:          subnetID.String(),
+			Blockchains: []blockchainTopology{{Name: "chain", ID: blockchainID.String(), VMID: vmID.String()}},
+		},
+	}, topology[1].Subnets)
+	assert.Equal([]subnetTopology{primaryNetwork}, topology[2].Subnets)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.ExportTopology(context.Background())
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestWithPlatformClient(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	platformCli := &heightPlatformClient{}
+	op := network.NewSetupOp(network.WithPlatformClient(platformCli), network.WithTxNodeName("node1"))
+	// queries go through the given client
+	gotPlatformCli, err := net.getPlatformClient(context.Background(), op)
+	assert.NoError(err)
+	assert.Same(platformCli, gotPlatformCli)
+	// while txs still go through the tx node
+	txNode, err := net.getTxNode(context.Background(), op)
+	assert.NoError(err)
+	assert.Equal("node1", txNode.GetName())
+	// without the option, queries go through the platform client of the tx node
+	txNodePlatformCli := &heightPlatformClient{}
+	net.nodes["node1"].client.(*apimocks.Client).On("PChainAPI").Return(txNodePlatformCli)
+	gotPlatformCli, err = net.getPlatformClient(context.Background(), network.NewSetupOp(network.WithTxNodeName("node1")))
+	assert.NoError(err)
+	assert.Same(txNodePlatformCli, gotPlatformCli)
+	_, err = net.getPlatformClient(context.Background(), network.NewSetupOp(network.WithTxNodeName("unknown")))
+	assert.Error(err)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestCollectMetrics(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// every node serves its own metrics, or the given status code if set
+	var statusCodesLock sync.Mutex
+	statusCodes := map[string]int{}
+	ports := map[string]uint16{}
+	for nodeName, node := range net.nodes {
+		nodeName := nodeName
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(metricsEndpoint, r.URL.Path)
+			assert.Equal(http.MethodGet, r.Method)
+			statusCodesLock.Lock()
+			statusCode, ok := statusCodes[nodeName]
+			statusCodesLock.Unlock()
+			if ok {
+				w.WriteHeader(statusCode)
+				return
+			}
+			_, _ = w.Write([]byte("# TYPE up gauge\nup{node=\"" + nodeName + "\"} 1\n"))
+		}))
+		defer server.Close()
+		serverURL, err := url.Parse(server.URL)
+		assert.NoError(err)
+		port, err := strconv.Atoi(serverURL.Port())
+		assert.NoError(err)
+		node.apiPort = uint16(port)
+		ports[nodeName] = uint16(port)
+	}
+	metrics, err := net.CollectMetrics(context.Background())
+	assert.NoError(err)
+	assert.Len(metrics, len(net.nodes))
+	for nodeName := range net.nodes {
+		assert.Equal("# TYPE up gauge\nup{node=\""+nodeName+"\"} 1\n", string(metrics[nodeName]))
+	}
+	// a non 200 reply fails the collection
+	statusCodesLock.Lock()
+	statusCodes["node1"] = http.StatusInternalServerError
+	statusCodesLock.Unlock()
+	metrics, err = net.CollectMetrics(context.Background())
+	assert.Error(err)
+	assert.Nil(metrics)
+	assert.Contains(err.Error(), "node \"node1\"")
+	assert.Contains(err.Error(), "unexpected status code 500")
+	statusCodesLock.Lock()
+	delete(statusCodes, "node1")
+	statusCodesLock.Unlock()
+	// an unreachable node fails the collection
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachableURL, err := url.Parse(unreachable.URL)
+	assert.NoError(err)
+	unreachablePort, err := strconv.Atoi(unreachableURL.Port())
+	assert.NoError(err)
+	unreachable.Close()
+	net.nodes["node2"].apiPort = uint16(unreachablePort)
+	metrics, err = net.CollectMetrics(context.Background())
+	assert.Error(err)
+	assert.Nil(metrics)
+	assert.Contains(err.Error(), "node \"node2\"")
+	// nodes stopped with StopNode are skipped
+	net.nodes["node2"].apiPort = ports["node2"]
+	assert.NoError(net.StopNode(context.Background(), "node2"))
+	metrics, err = net.CollectMetrics(context.Background())
+	assert.NoError(err)
+	assert.Len(metrics, len(net.nodes)-1)
+	assert.NotContains(metrics, "node2")
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.CollectMetrics(context.Background())
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// every node API is served with the given clock offset
+	offsets := map[string]time.Duration{}
+	for nodeName, node := range net.nodes {
+		nodeName := nodeName
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(infoEndpoint, r.URL.Path)
+			w.Header().Set("Date", time.Now().Add(offsets[nodeName]).UTC().Format(http.TimeFormat))
+		}))
+		defer server.Close()
+		serverURL, err := url.Parse(server.URL)
+		assert.NoError(err)
+		port, err := strconv.Atoi(serverURL.Port())
+		assert.NoError(err)
+		node.apiPort = uint16(port)
+	}
+	assert.NoError(net.CheckClockSkew(context.Background(), 5*time.Second))
+	offsets["node1"] = time.Hour
+	offsets["node2"] = -time.Hour
+	err = net.CheckClockSkew(context.Background(), 5*time.Second)
+	assert.ErrorIs(err, ErrClockSkew)
+	assert.Contains(err.Error(), "node \"node1\"")
+	assert.Contains(err.Error(), "node \"node2\"")
+	assert.NotContains(err.Error(), "node \"node0\"")
+	assert.NoError(net.CheckClockSkew(context.Background(), 2*time.Hour))
+	assert.NoError(net.Stop(context.Background()))
+	assert.ErrorIs(net.CheckClockSkew(context.Background(), time.Second), network.ErrStopped)
+}
+
+// Info API client whose GetNetworkID method always returns [networkID].
+// Only GetNetworkID may be called.
+type networkIDInfoClient struct {
+	info.Client
+	networkID uint32
+}
+
+func (c *networkIDInfoClient) GetNetworkID(context.Context, ...rpc.Option) (uint32, error) {
+	return c.networkID, nil
+}
+
+func TestCheckNetworkIDs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	setNetworkIDs := func(networkIDs map[string]uint32) {
+		for nodeName, node := range net.nodes {
+			networkID, ok := networkIDs[nodeName]
+			if !ok {
+				networkID = net.networkID
+			}
+			ethClient := &apimocks.EthClient{}
+			ethClient.On("Close").Return()
+			client := &apimocks.Client{}
+			client.On("InfoAPI").Return(&networkIDInfoClient{networkID: networkID})
+			client.On("CChainEthAPI").Return(ethClient)
+			node.client = client
+		}
+	}
+	setNetworkIDs(nil)
+	assert.NoError(net.checkNetworkIDs(context.Background(), network.DefaultMaxConcurrency))
+	setNetworkIDs(map[string]uint32{"node1": net.networkID + 1})
+	err = net.checkNetworkIDs(context.Background(), network.DefaultMaxConcurrency)
+	assert.ErrorIs(err, ErrNetworkIDMismatch)
+	assert.Contains(err.Error(), fmt.Sprintf("node \"node1\" network ID %d", net.networkID+1))
+	assert.NotContains(err.Error(), "node \"node0\"")
+	// checked before creating subnets
+	_, err = net.CreateSubnets(context.Background(), 1)
+	assert.ErrorIs(err, ErrNetworkIDMismatch)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestDeployBlockchain(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	chainSpec := network.BlockchainSpec{VmName: "vm1", Genesis: []byte(`{}`)}
+	_, err = net.DeployBlockchain(context.Background(), constants.PrimaryNetworkID, chainSpec)
+	assert.Error(err)
+	_, err = net.DeployBlockchain(context.Background(), ids.GenerateTestID(), network.BlockchainSpec{VmName: "vm1"})
+	assert.Error(err)
+	// the spec is deployed as the ones of CreateBlockchains, starting with the network ID check
+	for _, node := range net.nodes {
+		ethClient := &apimocks.EthClient{}
+		ethClient.On("Close").Return()
+		client := &apimocks.Client{}
+		client.On("InfoAPI").Return(&networkIDInfoClient{networkID: net.networkID + 1})
+		client.On("CChainEthAPI").Return(ethClient)
+		node.client = client
+	}
+	_, err = net.DeployBlockchain(context.Background(), ids.GenerateTestID(), chainSpec)
+	assert.ErrorIs(err, ErrNetworkIDMismatch)
+	assert.NoError(net.Stop(context.Background()))
+	_, err = net.DeployBlockchain(context.Background(), ids.GenerateTestID(), chainSpec)
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestAwaitChainsReadyQuorum(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	chainInfos := []blockchainInfo{{blockchainID: ids.GenerateTestID()}}
+	// the blockchain is running on every node but node2
+	for _, nodeName := range []string{"node0", "node1"} {
+		logsDir := net.nodes[nodeName].GetLogsDir()
+		assert.NoError(os.MkdirAll(logsDir, 0o755))
+		assert.NoError(os.WriteFile(filepath.Join(logsDir, chainInfos[0].blockchainID.String()+".log"), nil, 0o644))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	err = net.awaitChainsReadyQuorum(ctx, chainInfos, network.NewSetupOp())
+	cancel()
+	assert.Error(err)
+	assert.Contains(err.Error(), "running on 2 nodes, 3 required")
+	assert.Contains(err.Error(), "node \"node2\"")
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	err = net.awaitChainsReadyQuorum(ctx, chainInfos, network.NewSetupOp(network.WithBootstrapQuorum(0.9)))
+	cancel()
+	assert.Error(err)
+	// node2 keeps being waited for in the background
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	err = net.awaitChainsReadyQuorum(ctx, chainInfos, network.NewSetupOp(network.WithBootstrapQuorum(0.6)))
+	cancel()
+	assert.NoError(err)
+	// once node2 is stopped, all the nodes it leaves running are enough
+	assert.NoError(net.StopNode(context.Background(), "node2"))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	err = net.awaitChainsReadyQuorum(ctx, chainInfos, network.NewSetupOp())
+	cancel()
+	assert.NoError(err)
+	assert.Error(validateSetupOp(network.NewSetupOp(network.WithBootstrapQuorum(1.5))))
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestRunningEndpoints(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	chainInfos := []blockchainInfo{
+		{chainName: "vm0", blockchainID: ids.GenerateTestID()},
+		{chainName: "vm1", blockchainID: ids.GenerateTestID()},
+	}
+	// the first blockchain is running on node1 only, the second one on no node
+	logsDir := net.nodes["node1"].GetLogsDir()
+	assert.NoError(os.MkdirAll(logsDir, 0o755))
+	assert.NoError(os.WriteFile(filepath.Join(logsDir, chainInfos[0].blockchainID.String()+".log"), nil, 0o644))
+	endpoints := net.runningEndpoints(chainInfos, net.finalizeBlockchains(chainInfos, network.NewSetupOp()))
+	assert.Len(endpoints, 1)
+	assert.Equal("node1", endpoints[0].NodeName)
+	assert.Equal(chainInfos[0].blockchainID, endpoints[0].BlockchainID)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestStartProxy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	blockchainID := ids.GenerateTestID()
+	// every node API answers with the node name
+	for nodeName, node := range net.nodes {
+		nodeName := nodeName
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(fmt.Sprintf("/ext/bc/%s/rpc", blockchainID), r.URL.Path)
+			_, _ = w.Write([]byte(nodeName))
+		}))
+		defer server.Close()
+		serverURL, err := url.Parse(server.URL)
+		assert.NoError(err)
+		port, err := strconv.Atoi(serverURL.Port())
+		assert.NoError(err)
+		node.apiPort = uint16(port)
+	}
+	// node1 is skipped
+	unhealthyNode := net.nodes["node1"]
+	origClient := unhealthyNode.client
+	unhealthyNode.client, err = newMockAPIUnhealthy(unhealthyNode.GetURL(), unhealthyNode.GetAPIPort(), nil)
+	assert.NoError(err)
+	proxyURL, stop, err := net.StartProxy(context.Background(), blockchainID, "")
+	assert.NoError(err)
+	reached := []string{}
+	for i := 0; i < 4; i++ {
+		resp, err := http.Post(proxyURL+"/rpc", "application/json", strings.NewReader("{}"))
+		assert.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(err)
+		assert.NoError(resp.Body.Close())
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		reached = append(reached, string(body))
+	}
+	assert.Equal([]string{"node0", "node2", "node0", "node2"}, reached)
+	stop()
+	// stopping again does nothing
+	stop()
+	_, err = http.Post(proxyURL+"/rpc", "application/json", strings.NewReader("{}"))
+	assert.Error(err)
+	unhealthyNode.client = origClient
+	assert.NoError(net.Stop(context.Background()))
+	_, _, err = net.StartProxy(context.Background(), blockchainID, "")
+	assert.ErrorIs(err, network.ErrStopped)
+}
+
+func TestProxyNoHealthyNodes(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ln := &localNetwork{
+		log:      logging.NoLog{},
+		nodes:    map[string]*localNode{},
+		onStopCh: make(chan struct{}),
+	}
+	proxyURL, stop, err := ln.StartProxy(context.Background(), ids.GenerateTestID(), "")
+	assert.NoError(err)
+	defer stop()
+	resp, err := http.Get(proxyURL + "/rpc")
+	assert.NoError(err)
+	assert.NoError(resp.Body.Close())
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestLoadConfigOnNodeHealthy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	healthyCh := make(chan string, len(networkConfig.NodeConfigs))
+	err = net.loadConfig(context.Background(), networkConfig, network.WithOnNodeHealthy(func(nodeName string) {
+		healthyCh <- nodeName
+	}))
+	assert.NoError(err)
+	healthyNodes := []string{}
+	for range networkConfig.NodeConfigs {
+		select {
+		case nodeName := <-healthyCh:
+			healthyNodes = append(healthyNodes, nodeName)
+		case <-time.After(10 * time.Second):
+			assert.FailNow("nodes not notified as healthy")
+		}
+	}
+	assert.ElementsMatch([]string{"node0", "node1", "node2"}, healthyNodes)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestLoadConfigStakingKeys(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	// last node gets a generated staking key and cert
+	lastNodeConfig := &networkConfig.NodeConfigs[len(networkConfig.NodeConfigs)-1]
+	lastNodeConfig.IsBeacon = false
+	lastNodeConfig.StakingKey = ""
+	lastNodeConfig.StakingCert = ""
+	for i := 0; i < 2; i++ {
+		net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+		assert.NoError(err)
+		err = net.loadConfig(context.Background(), networkConfig)
+		assert.NoError(err)
+		for _, nodeConfig := range networkConfig.NodeConfigs[:len(networkConfig.NodeConfigs)-1] {
+			expectedNodeID, err := utils.ToNodeID([]byte(nodeConfig.StakingKey), []byte(nodeConfig.StakingCert))
+			assert.NoError(err)
+			assert.Equal(expectedNodeID, net.nodes[nodeConfig.Name].GetNodeID())
+		}
+		generatedNode := net.nodes[lastNodeConfig.Name]
+		assert.NotEmpty(generatedNode.getConfig().StakingKey)
+		assert.NotEmpty(generatedNode.getConfig().StakingCert)
+		assert.NotEqual(ids.EmptyNodeID, generatedNode.GetNodeID())
+		assert.NoError(net.Stop(context.Background()))
+	}
+}
+
+// Returns a network whose nodes are given the API clients of [newAPIClientF],
+// with the config of the first node modified by [f]
+func newAPIClientTestNetwork(
+	t *testing.T,
+	newAPIClientF api.NewAPIClientF,
+	f func(*node.Config),
+) network.Network {
+	networkConfig := testNetworkConfig(t)
+	f(&networkConfig.NodeConfigs[0])
+	net, err := newNetwork(logging.NoLog{}, newAPIClientF, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, net.loadConfig(context.Background(), networkConfig))
+	return net
+}
+
+// Test that the API clients send the default headers of the node they query,
+// without modifying http.DefaultClient
+func TestAPIHeaders(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	authorizations := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"isBootstrapped":true}}`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(err)
+	port, err := strconv.ParseUint(serverURL.Port(), 10, 16)
+	assert.NoError(err)
+	httpClients := map[uint16]*http.Client{}
+	var lock sync.Mutex
+	net := newAPIClientTestNetwork(t, func(ipAddr string, port uint16, httpClient *http.Client) (api.Client, error) {
+		lock.Lock()
+		httpClients[port] = httpClient
+		lock.Unlock()
+		return newMockAPISuccessful(ipAddr, port, httpClient)
+	}, func(nodeConfig *node.Config) {
+		nodeConfig.APIHeaders = map[string]string{"Authorization": "Bearer token"}
+	})
+	defer func() {
+		assert.NoError(net.Stop(context.Background()))
+	}()
+	nodes, err := net.GetAllNodes()
+	assert.NoError(err)
+	assert.Nil(http.DefaultClient.Transport)
+	for _, node := range nodes {
+		expected := ""
+		if len(node.GetConfig().APIHeaders) != 0 {
+			expected = "Bearer token"
+		}
+		// query the server with the http client of the node
+		client, err := api.NewAPIClient(serverURL.Hostname(), uint16(port), httpClients[node.GetAPIPort()])
+		assert.NoError(err)
+		bootstrapped, err := client.InfoAPI().IsBootstrapped(context.Background(), "P")
+		assert.NoError(err)
+		assert.True(bootstrapped)
+		assert.Equal(expected, <-authorizations)
+	}
+}
+
+// Transport recording the requests sent through it, answering with a fixed body
+type recordingTransport struct {
+	requests chan *http.Request
+	body     string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests <- req
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+// Test that the API clients send their requests through the transport of the node they query
+func TestAPITransport(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	transport := &recordingTransport{
+		requests: make(chan *http.Request, 1),
+		body:     `{"jsonrpc":"2.0","id":1,"result":{"isBootstrapped":true}}`,
+	}
+	var (
+		lock        sync.Mutex
+		httpClients = map[uint16]*http.Client{}
+		nodeName    string
+	)
+	net := newAPIClientTestNetwork(t, func(ipAddr string, port uint16, httpClient *http.Client) (api.Client, error) {
+		lock.Lock()
+		httpClients[port] = httpClient
+		lock.Unlock()
+		return newMockAPISuccessful(ipAddr, port, httpClient)
+	}, func(nodeConfig *node.Config) {
+		nodeName = nodeConfig.Name
+		nodeConfig.APITransport = transport
+		nodeConfig.APIHeaders = map[string]string{"Authorization": "Bearer token"}
+	})
+	defer func() {
+		assert.NoError(net.Stop(context.Background()))
+	}()
+	node, err := net.GetNode(nodeName)
+	assert.NoError(err)
+	// no server listens on the node port, the transport answers instead
+	client, err := api.NewAPIClient(apiClientIP, node.GetAPIPort(), httpClients[node.GetAPIPort()])
+	assert.NoError(err)
+	bootstrapped, err := client.InfoAPI().IsBootstrapped(context.Background(), "P")
+	assert.NoError(err)
+	assert.True(bootstrapped)
+	req := <-transport.requests
+	assert.Equal(utils.JoinHostPort(apiClientIP, node.GetAPIPort()), req.URL.Host)
+	// the default headers are added before the request reaches the transport
+	assert.Equal("Bearer token", req.Header.Get("Authorization"))
+}
+
+// Transport answering the JSON-RPC requests sent through it with [answer],
+// recording their methods
+type jsonRPCTransport struct {
+	lock    sync.Mutex
+	methods []string
+	answer  func(method string, params json.RawMessage) (string, error)
+}
+
+func (t *jsonRPCTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request := struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return nil, err
+	}
+	t.lock.Lock()
+	t.methods = append(t.methods, request.Method)
+	t.lock.Unlock()
+	body := ""
+	if result, err := t.answer(request.Method, request.Params); err != nil {
+		body = fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":%q}}`, err)
+	} else {
+		body = fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":%s}`, result)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func (t *jsonRPCTransport) getMethods() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]string{}, t.methods...)
+}
+
+// Test that the requests sent while creating the blockchains, including the P-Chain
+// requests of the wallet and of the platform client, go through the transport of the nodes
+func TestCreateBlockchainsAPITransport(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	_, fundedKey, err := setupKeychain(network.NewSetupOp())
+	assert.NoError(err)
+	fundedAddr := fundedKey.PublicKey().Address()
+	avaxAssetID, xChainID := ids.GenerateTestID(), ids.GenerateTestID()
+	utxoBytes, err := txs.Codec.Marshal(txs.Version, &avax.UTXO{
+		UTXOID: avax.UTXOID{TxID: ids.GenerateTestID()},
+		Asset:  avax.Asset{ID: avaxAssetID},
+		Out: &secp256k1fx.TransferOutput{
+			Amt:          1000 * units.Avax,
+			OutputOwners: secp256k1fx.OutputOwners{Threshold: 1, Addrs: []ids.ShortID{fundedAddr}},
+		},
+	})
+	assert.NoError(err)
+	utxo, err := formatting.Encode(formatting.Hex, utxoBytes)
+	assert.NoError(err)
+	networkID, err := utils.NetworkIDFromGenesis([]byte(networkConfig.Genesis))
+	assert.NoError(err)
+	endAddr, err := address.Format("P", constants.GetHRP(networkID), fundedAddr.Bytes())
+	assert.NoError(err)
+	errValidators := errors.New("validators not available")
+	transport := &jsonRPCTransport{
+		answer: func(method string, params json.RawMessage) (string, error) {
+			switch method {
+			case "health.health":
+				return `{"healthy":true}`, nil
+			case "info.isBootstrapped":
+				return `{"isBootstrapped":true}`, nil
+			case "info.getNetworkID":
+				return fmt.Sprintf(`{"networkID":"%d"}`, networkID), nil
+			case "info.getBlockchainID":
+				return fmt.Sprintf(`{"blockchainID":%q}`, xChainID), nil
+			case "info.getTxFee":
+				return `{"txFee":"1000000","createAssetTxFee":"1000000","createSubnetTxFee":"1000000","createBlockchainTxFee":"1000000"}`, nil
+			case "avm.getAssetDescription":
+				return fmt.Sprintf(`{"assetID":%q,"name":"Avalanche","symbol":"AVAX","denomination":"9"}`, avaxAssetID), nil
+			case "platform.getUTXOs", "avm.getUTXOs":
+				args := struct {
+					SourceChain string `json:"sourceChain"`
+				}{}
+				if err := json.Unmarshal(params, &args); err != nil {
+					return "", err
+				}
+				utxos := "[]"
+				// the funds are on the P-Chain
+				if method == "platform.getUTXOs" && args.SourceChain == constants.PlatformChainID.String() {
+					utxos = fmt.Sprintf("[%q]", utxo)
+				}
+				return fmt.Sprintf(`{"numFetched":"0","utxos":%s,"endIndex":{"address":%q,"utxo":%q},"encoding":"hex"}`, utxos, endAddr, ids.Empty), nil
+			case "platform.getCurrentValidators":
+				return "", errValidators
+			default:
+				return "", fmt.Errorf("unexpected method %s", method)
+			}
+		},
+	}
+	for i := range networkConfig.NodeConfigs {
+		networkConfig.NodeConfigs[i].APITransport = transport
+	}
+	// no server listens on the node ports, the transport answers instead
+	net, err := newNetwork(logging.NoLog{}, api.NewAPIClient, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	assert.NoError(net.loadConfig(context.Background(), networkConfig))
+	chainSpecs := []network.BlockchainSpec{{VmName: "vm", Genesis: []byte(`{}`)}}
+	_, err = net.CreateBlockchains(context.Background(), chainSpecs, network.WithTxNodeName("node1"))
+	// fails on the first query of the platform client, once the wallet is set up
+	assert.ErrorContains(err, errValidators.Error())
+	methods := transport.getMethods()
+	assert.Contains(methods, "platform.getUTXOs")
+	assert.Equal("platform.getCurrentValidators", methods[len(methods)-1])
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestNodeGetConfig(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs[0].Flags = map[string]interface{}{
+		config.StakingKeyContentKey: "key content",
+		"some-flag":                 "value",
+	}
+	networkConfig.NodeConfigs[0].APIHeaders = map[string]string{"Authorization": "Bearer token"}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	node := net.nodes[networkConfig.NodeConfigs[0].Name]
+	nodeConfig := node.GetConfig()
+	assert.Equal(networkConfig.NodeConfigs[0].StakingCert, nodeConfig.StakingCert)
+	assert.Equal(redactedValue, nodeConfig.StakingKey)
+	assert.Equal(redactedValue, nodeConfig.Flags[config.StakingKeyContentKey])
+	assert.Equal(redactedValue, nodeConfig.APIHeaders["Authorization"])
+	assert.Equal("Bearer token", node.getConfig().APIHeaders["Authorization"])
+	assert.Equal("value", nodeConfig.Flags["some-flag"])
+	// the running node config is not redacted nor modified
+	nodeConfig.Flags["some-flag"] = "other value"
+	assert.Equal(networkConfig.NodeConfigs[0].StakingKey, node.getConfig().StakingKey)
+	assert.Equal("key content", node.getConfig().Flags[config.StakingKeyContentKey])
+	assert.Equal("value", node.GetConfig().Flags["some-flag"])
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestFinalizeBlockchains(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	chainInfos := []blockchainInfo{
+		{chainName: "subnetevm", blockchainID: ids.GenerateTestID()},
+		{chainName: "timestampvm", blockchainID: ids.GenerateTestID()},
+	}
+	op := network.NewSetupOp(network.WithRPCPaths(map[string]string{"subnetevm": "rpc"}))
+	endpoints := net.finalizeBlockchains(chainInfos, op)
+	assert.Len(endpoints, len(chainInfos)*len(networkConfig.NodeConfigs))
+	for i, endpoint := range endpoints {
+		chainInfo := chainInfos[i/len(networkConfig.NodeConfigs)]
+		node := net.nodes[endpoint.NodeName]
+		assert.EqualValues(fmt.Sprintf("node%d", i%len(networkConfig.NodeConfigs)), endpoint.NodeName)
+		assert.Equal(node.GetNodeID(), endpoint.NodeID)
+		assert.Equal(chainInfo.blockchainID, endpoint.BlockchainID)
+		assert.Equal(fmt.Sprintf("http://%s:%d", node.GetURL(), node.GetAPIPort()), endpoint.BaseURL)
+		expectedPath := "/ext/bc/" + chainInfo.blockchainID.String()
+		if chainInfo.chainName == "subnetevm" {
+			expectedPath += "/rpc"
+		}
+		assert.Equal(expectedPath, endpoint.Path)
+		assert.Equal(endpoint.BaseURL+expectedPath, endpoint.URL())
+	}
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestWriteEndpointsFile(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "out", "endpoints.json")
+	nodeID := ids.GenerateTestNodeID()
+	blockchainID := ids.GenerateTestID()
+	endpoints := []network.Endpoint{
+		{
+			NodeName:     "node0",
+			NodeID:       nodeID,
+			BlockchainID: blockchainID,
+			BaseURL:      "http://127.0.0.1:9650",
+			Path:         "/ext/bc/" + blockchainID.String() + "/rpc",
+		},
+	}
+	assert.NoError(os.MkdirAll(filepath.Dir(path), 0o750))
+	assert.NoError(os.WriteFile(path, []byte("old"), 0o600))
+	// the existing file is replaced
+	assert.NoError(writeEndpointsFile(path, endpoints))
+	contents, err := os.ReadFile(path)
+	assert.NoError(err)
+	entries := []endpointsFileEntry{}
+	assert.NoError(json.Unmarshal(contents, &entries))
+	assert.Equal([]endpointsFileEntry{
+		{
+			NodeName:     "node0",
+			NodeID:       nodeID.String(),
+			BlockchainID: blockchainID.String(),
+			URL:          endpoints[0].URL(),
+			Path:         endpoints[0].Path,
+		},
+	}, entries)
+	// no temporary file is left behind
+	dirEntries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(err)
+	assert.Len(dirEntries, 1)
+}
+
+func TestNodeNamesNaturalOrder(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	for i, nodeName := range []string{"node-10", "node-3", "node-2"} {
+		networkConfig.NodeConfigs[i].Name = nodeName
+	}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	names, err := net.GetNodeNames()
+	assert.NoError(err)
+	assert.Equal([]string{"node-2", "node-3", "node-10"}, names)
+	// the first node in that order issues the txs
+	txNode, err := net.getTxNode(context.Background(), network.NewSetupOp())
+	assert.NoError(err)
+	assert.Equal("node-2", txNode.GetName())
+	assert.NoError(net.Stop(context.Background()))
+}
+
+// Wallet only compared by identity, none of its methods may be called
+type fakeWallet struct {
+	primary.Wallet
+}
+
+func TestWithTxNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	baseWallet := &fakeWallet{}
+	op := network.NewSetupOp()
+	// without tx node, the base wallet is used and kept
+	called := false
+	wallet, err := net.withTxNode(context.Background(), baseWallet, "", nil, op, func(wallet primary.Wallet) error {
+		called = true
+		assert.Same(baseWallet, wallet)
+		return nil
+	})
+	assert.NoError(err)
+	assert.True(called)
+	assert.Same(baseWallet, wallet)
+	errIssue := errors.New("tx rejected")
+	_, err = net.withTxNode(context.Background(), baseWallet, "", nil, op, func(primary.Wallet) error {
+		return errIssue
+	})
+	assert.ErrorIs(err, errIssue)
+	// an unknown tx node fails before issuing any tx
+	called = false
+	_, err = net.withTxNode(context.Background(), baseWallet, "unknown", nil, op, func(primary.Wallet) error {
+		called = true
+		return nil
+	})
+	assert.Error(err)
+	assert.False(called)
+	assert.NoError(net.Stop(context.Background()))
+}
+
+func TestGetTxNode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	// first node by name
+	txNode, err := net.getTxNode(context.Background(), network.NewSetupOp())
+	assert.NoError(err)
+	assert.EqualValues("node0", txNode.GetName())
+	// given node name
+	txNode, err = net.getTxNode(context.Background(), network.NewSetupOp(network.WithTxNodeName("node2")))
+	assert.NoError(err)
+	assert.EqualValues("node2", txNode.GetName())
+	// unknown node name
+	_, err = net.getTxNode(context.Background(), network.NewSetupOp(network.WithTxNodeName("unknown")))
+	assert.Error(err)
+	// P-Chain not bootstrapped
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	client := &apimocks.Client{}
+	client.On("InfoAPI").Return(&bootstrappedInfoClient{bootstrapped: false})
+	net.nodes["node0"].client = client
+	_, err = net.getTxNode(ctx, network.NewSetupOp(network.WithTxNodeName("node0")))
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	// P-Chain bootstrap check keeps failing
+	errConnRefused := errors.New("connection refused")
+	client = &apimocks.Client{}
+	client.On("InfoAPI").Return(&bootstrappedInfoClient{err: errConnRefused})
+	net.nodes["node0"].client = client
+	_, err = net.getTxNode(context.Background(), network.NewSetupOp(network.WithTxNodeName("node0")))
+	assert.ErrorIs(err, errConnRefused)
+
+	// no healthy node
+	net, err = newNetwork(logging.NoLog{}, newMockAPIUnhealthy, &localTestSuccessfulNodeProcessCreator{}, "", "")
+	assert.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	assert.NoError(err)
+	_, err = net.getTxNode(context.Background(), network.NewSetupOp())
+	assert.Error(err)
 }
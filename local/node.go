@@ -6,12 +6,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ava-labs/avalanche-network-runner/api"
 	"github.com/ava-labs/avalanche-network-runner/network/node"
 	"github.com/ava-labs/avalanche-network-runner/network/node/status"
+	"github.com/ava-labs/avalanche-network-runner/utils"
+	"github.com/ava-labs/avalanchego/config"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/message"
 	"github.com/ava-labs/avalanchego/network/peer"
@@ -40,6 +45,13 @@ const (
 	peerMsgQueueBufferSize      = 1024
 	peerResourceTrackerDuration = 10 * time.Second
 	peerStartWaitTimeout        = 30 * time.Second
+	metricsEndpoint             = "/ext/metrics"
+	infoEndpoint                = "/ext/info"
+	defaultAPITimeout           = time.Minute
+	// replaces the sensitive values of the configs returned by GetConfig
+	redactedValue = "<redacted>"
+	// IP the API clients of the nodes send their requests to
+	apiClientIP = "localhost"
 )
 
 // Gives access to basic node info, and to most avalanchego apis
@@ -53,8 +65,19 @@ type localNode struct {
 	networkID uint32
 	// Allows user to make API calls to this node.
 	client api.Client
+	// Sends the requests of [client], and the other requests to the APIs of this node,
+	// with the API headers and transport of its config.
+	httpClient *http.Client
 	// The process running this node.
 	process NodeProcess
+	// Set to 1 when the network stops the process, so that
+	// its exit is not reported as unexpected.
+	stopRequested uint32
+	// Set to 1 when the node is stopped with StopNode, so that it stays in the
+	// network, reported as unhealthy, until started again.
+	// Written while holding the network lock, but also read without it by the
+	// goroutines waiting for the node, so it is accessed atomically.
+	stopped uint32
 	// The API port
 	apiPort uint16
 	// The P2P (staking) port
@@ -65,6 +88,8 @@ type localNode struct {
 	dbDir string
 	// The logs dir of the node
 	logsDir string
+	// The dir the node writes the profiles requested through its admin API to
+	profileDir string
 	// The build dir of the node
 	buildDir string
 	// The node config
@@ -73,11 +98,13 @@ type localNode struct {
 	httpHost string
 	// maps from peer ID to peer object
 	attachedPeers map[string]peer.Peer
+	// file with the JSON config the node process was launched with
+	launchConfigPath string
 }
 
 func defaultGetConnFunc(ctx context.Context, node node.Node) (net.Conn, error) {
 	dialer := net.Dialer{}
-	return dialer.DialContext(ctx, constants.NetworkType, net.JoinHostPort(node.GetURL(), fmt.Sprintf("%d", node.GetP2PPort())))
+	return dialer.DialContext(ctx, constants.NetworkType, node.GetStakingAddress())
 }
 
 // AttachPeer: see Network
@@ -211,11 +238,26 @@ func (node *localNode) GetP2PPort() uint16 {
 	return node.p2pPort
 }
 
+// See node.Node
+func (node *localNode) GetStakingPort() uint16 {
+	return node.p2pPort
+}
+
+// See node.Node
+func (node *localNode) GetStakingAddress() string {
+	return utils.JoinHostPort(node.GetURL(), node.GetStakingPort())
+}
+
 // See node.Node
 func (node *localNode) GetAPIPort() uint16 {
 	return node.apiPort
 }
 
+// See node.Node
+func (node *localNode) GetMetricsURL() string {
+	return "http://" + utils.JoinHostPort(node.GetURL(), node.GetAPIPort()) + metricsEndpoint
+}
+
 func (node *localNode) Status() status.Status {
 	return node.process.Status()
 }
@@ -248,9 +290,45 @@ func (node *localNode) GetConfigFile() string {
 	return node.config.ConfigFile
 }
 
+// See node.Node
+func (node *localNode) GetAPITimeout() time.Duration {
+	if node.config.APITimeout > 0 {
+		return node.config.APITimeout
+	}
+	return defaultAPITimeout
+}
+
 // See node.Node
 func (node *localNode) GetConfig() node.Config {
-	return node.config
+	nodeConfig := node.getConfig()
+	if nodeConfig.StakingKey != "" {
+		nodeConfig.StakingKey = redactedValue
+	}
+	if _, ok := nodeConfig.Flags[config.StakingKeyContentKey]; ok {
+		nodeConfig.Flags[config.StakingKeyContentKey] = redactedValue
+	}
+	for k := range nodeConfig.APIHeaders {
+		nodeConfig.APIHeaders[k] = redactedValue
+	}
+	return nodeConfig
+}
+
+// Returns a copy of the config the node was started with, including the
+// sensitive values, that can be modified to restart the node without
+// affecting the running one.
+func (node *localNode) getConfig() node.Config {
+	nodeConfig := node.config
+	nodeConfig.Flags = copyMapStringInterface(node.config.Flags)
+	nodeConfig.ChainConfigFiles = copyMapStringString(node.config.ChainConfigFiles)
+	nodeConfig.UpgradeConfigFiles = copyMapStringString(node.config.UpgradeConfigFiles)
+	nodeConfig.SubnetConfigFiles = copyMapStringString(node.config.SubnetConfigFiles)
+	if node.config.APIHeaders != nil {
+		nodeConfig.APIHeaders = copyMapStringString(node.config.APIHeaders)
+	}
+	if node.config.ExtraArgs != nil {
+		nodeConfig.ExtraArgs = append([]string{}, node.config.ExtraArgs...)
+	}
+	return nodeConfig
 }
 
 // See node.Node
@@ -279,3 +357,65 @@ func (node *localNode) GetFlag(k string) (string, error) {
 	}
 	return v, nil
 }
+
+// Returns true if the node was started with its admin API enabled, as given
+// with the precedence of buildFlags: by the last of its extra args setting it,
+// else by its flags, or else by its config file.
+// The admin API is disabled by default.
+func (node *localNode) adminAPIEnabled() (bool, error) {
+	if enabled, ok := getExtraArg(node.config.ExtraArgs, config.AdminAPIEnabledKey); ok {
+		// a boolean flag given without value is set
+		if enabled == "" {
+			return true, nil
+		}
+		return strconv.ParseBool(enabled)
+	}
+	var configFile map[string]interface{}
+	if node.config.ConfigFile != "" {
+		if err := json.Unmarshal([]byte(node.config.ConfigFile), &configFile); err != nil {
+			return false, err
+		}
+	}
+	for _, entries := range []map[string]interface{}{node.config.Flags, configFile} {
+		switch enabled := entries[config.AdminAPIEnabledKey].(type) {
+		case nil:
+			continue
+		case bool:
+			return enabled, nil
+		case string:
+			return strconv.ParseBool(enabled)
+		default:
+			return false, fmt.Errorf("unexpected type for %q expected bool got %T", config.AdminAPIEnabledKey, enabled)
+		}
+	}
+	return false, nil
+}
+
+// See node.Node
+// The tracked subnets are read from the whitelisted subnets setting of the
+// node flags, or of the node config file if not given as a flag.
+func (node *localNode) GetTrackedSubnets(context.Context) ([]ids.ID, error) {
+	var configFile map[string]interface{}
+	if node.config.ConfigFile != "" {
+		if err := json.Unmarshal([]byte(node.config.ConfigFile), &configFile); err != nil {
+			return nil, err
+		}
+	}
+	whitelistedSubnets, err := getConfigEntry(node.config.Flags, configFile, config.WhitelistedSubnetsKey, "")
+	if err != nil {
+		return nil, err
+	}
+	subnetIDs := []ids.ID{}
+	for _, subnetIDStr := range strings.Split(whitelistedSubnets, ",") {
+		subnetIDStr = strings.TrimSpace(subnetIDStr)
+		if subnetIDStr == "" {
+			continue
+		}
+		subnetID, err := ids.FromString(subnetIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid whitelisted subnet %q: %w", subnetIDStr, err)
+		}
+		subnetIDs = append(subnetIDs, subnetID)
+	}
+	return subnetIDs, nil
+}
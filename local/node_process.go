@@ -29,6 +29,11 @@ type NodeProcess interface {
 	Stop(ctx context.Context) int
 	// Returns the status of the process.
 	Status() status.Status
+	// Returns a channel closed once the process exits, after which
+	// [Stop] returns its exit code right away.
+	Exited() <-chan struct{}
+	// Returns the ID of the process.
+	Pid() int
 }
 
 // NodeProcessCreator is an interface for new node process creation
@@ -74,7 +79,20 @@ func (npc *nodeProcessCreator) NewNodeProcess(config node.Config, args ...string
 		// redirect stderr and assign a color to the text
 		utils.ColorAndPrepend(stderr, npc.stderr, config.Name, color)
 	}
-	return newNodeProcess(config.Name, npc.log, cmd)
+	np, err := newNodeProcess(config.Name, npc.log, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if config.CPULimit > 0 || config.MemLimitMB > 0 {
+		if err := np.limitResources(config.CPULimit, config.MemLimitMB); err != nil {
+			// don't leave running a node without the requested limits
+			ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+			np.Stop(ctx)
+			cancel()
+			return nil, err
+		}
+	}
+	return np, nil
 }
 
 type nodeProcess struct {
@@ -86,6 +104,8 @@ type nodeProcess struct {
 	state status.Status
 	// Closed when the process exits.
 	closedOnStop chan struct{}
+	// If non-nil, removes the process resource limits once it exits.
+	removeResourceLimits func() error
 }
 
 func newNodeProcess(name string, log logging.Logger, cmd *exec.Cmd) (*nodeProcess, error) {
@@ -115,6 +135,25 @@ func (p *nodeProcess) start() error {
 	return nil
 }
 
+// Limits the CPU usage of the started process to [cpuLimit] cores,
+// and its memory to [memLimitMB] MB.
+// The process runs without limits for the short time before this is called.
+func (p *nodeProcess) limitResources(cpuLimit float64, memLimitMB uint64) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.state == status.Stopped {
+		return fmt.Errorf("node %q exited before applying its resource limits", p.name)
+	}
+	removeResourceLimits, err := applyResourceLimits(p.name, p.cmd.Process.Pid, cpuLimit, memLimitMB)
+	if err != nil {
+		return err
+	}
+	p.removeResourceLimits = removeResourceLimits
+	p.log.Debug("limited node resources", zap.String("node", p.name), zap.Float64("cpu-limit", cpuLimit), zap.Uint64("mem-limit-mb", memLimitMB))
+	return nil
+}
+
 // Wait for the process to exit.
 // When it does, update the state and close [p.closedOnStop]
 func (p *nodeProcess) awaitExit() {
@@ -125,6 +164,12 @@ func (p *nodeProcess) awaitExit() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	if p.removeResourceLimits != nil {
+		if err := p.removeResourceLimits(); err != nil {
+			p.log.Warn("couldn't remove node resource limits", zap.String("node", p.name), zap.Error(err))
+		}
+	}
+
 	p.state = status.Stopped
 	close(p.closedOnStop)
 }
@@ -184,6 +229,17 @@ func (p *nodeProcess) Status() status.Status {
 	return p.state
 }
 
+func (p *nodeProcess) Exited() <-chan struct{} {
+	return p.closedOnStop
+}
+
+func (p *nodeProcess) Pid() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.cmd.Process.Pid
+}
+
 func killDescendants(pid int32, log logging.Logger) {
 	procs, err := process.Processes()
 	if err != nil {
@@ -238,3 +238,13 @@ func TestAttachPeer(t *testing.T) {
 	// also ensures that [assert] calls will be reflected in test results if failed
 	assert.NoError(<-errCh)
 }
+
+func TestGetStakingAddress(t *testing.T) {
+	assert := assert.New(t)
+	node := &localNode{p2pPort: 9651}
+	assert.EqualValues(9651, node.GetStakingPort())
+	assert.Equal(node.GetP2PPort(), node.GetStakingPort())
+	assert.Equal("127.0.0.1:9651", node.GetStakingAddress())
+	node.httpHost = "0.0.0.0"
+	assert.Equal("0.0.0.0:9651", node.GetStakingAddress())
+}
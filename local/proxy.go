@@ -0,0 +1,173 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ava-labs/avalanche-network-runner/network"
+	"github.com/ava-labs/avalanche-network-runner/network/node/status"
+	"github.com/ava-labs/avalanche-network-runner/utils"
+	"github.com/ava-labs/avalanchego/ids"
+	"go.uber.org/zap"
+)
+
+const (
+	// address the blockchain proxy listens on if none is given
+	defaultProxyListenAddr = "127.0.0.1:0"
+	// time given to the in flight proxied requests when the proxy is stopped
+	proxyShutdownTimeout = 5 * time.Second
+)
+
+// Forwards the requests it receives to the endpoint of a blockchain,
+// in turn on each of the healthy nodes of a network.
+type blockchainProxy struct {
+	ln             *localNetwork
+	blockchainPath string
+	// Base URLs of the healthy nodes, sorted by node name.
+	// Refreshed every [healthCheckFreq].
+	targetsLock sync.RWMutex
+	targets     []*url.URL
+	// Incremented on each request to choose its target
+	next     uint64
+	server   *http.Server
+	closeCh  chan struct{}
+	stopOnce sync.Once
+}
+
+// See network.Network
+func (ln *localNetwork) StartProxy(ctx context.Context, blockchainID ids.ID, listenAddr string) (string, func(), error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return "", nil, network.ErrStopped
+	}
+	if listenAddr == "" {
+		listenAddr = defaultProxyListenAddr
+	}
+
+	p := &blockchainProxy{
+		ln:             ln,
+		blockchainPath: fmt.Sprintf("/ext/bc/%s", blockchainID),
+		closeCh:        make(chan struct{}),
+	}
+	p.updateTargets(ctx)
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return "", nil, fmt.Errorf("couldn't listen on %q: %w", listenAddr, err)
+	}
+	p.server = &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: proxyShutdownTimeout,
+	}
+	go func() {
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ln.log.Warn("blockchain proxy stopped serving", zap.Stringer("blockchain-id", blockchainID), zap.Error(err))
+		}
+	}()
+	go p.checkTargets()
+
+	ln.log.Info("started blockchain proxy", zap.Stringer("blockchain-id", blockchainID), zap.String("address", listener.Addr().String()))
+	return "http://" + listener.Addr().String(), p.stop, nil
+}
+
+// Every [healthCheckFreq], refreshes the healthy nodes requests are forwarded to,
+// until the proxy or the network are stopped.
+func (p *blockchainProxy) checkTargets() {
+	for {
+		select {
+		case <-p.closeCh:
+			return
+		case <-p.ln.onStopCh:
+			p.stop()
+			return
+		case <-time.After(healthCheckFreq):
+		}
+		ctx, cancel := p.ln.newStopAwareContext(context.Background())
+		p.ln.lock.RLock()
+		p.updateTargets(ctx)
+		p.ln.lock.RUnlock()
+		cancel()
+	}
+}
+
+// Assumes [p.ln.lock] is held.
+// Sets the targets of the proxy to the running nodes that pass a health check.
+func (p *blockchainProxy) updateTargets(ctx context.Context) {
+	var healthyLock sync.Mutex
+	healthy := []string{}
+	// a failed health check only excludes the node, so [f] never errs
+	_ = forEachNode(ctx, p.ln.runningNodes(), p.ln.healthCheckConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		if node.Status() != status.Running {
+			return nil
+		}
+		cctx, cancel := createNodeCtx(ctx, node)
+		health, err := node.GetAPIClient().HealthAPI().Health(cctx)
+		cancel()
+		if err == nil && health.Healthy {
+			healthyLock.Lock()
+			healthy = append(healthy, nodeName)
+			healthyLock.Unlock()
+		}
+		return nil
+	})
+	sort.Strings(healthy)
+
+	targets := make([]*url.URL, 0, len(healthy))
+	for _, nodeName := range healthy {
+		node := p.ln.nodes[nodeName]
+		targets = append(targets, &url.URL{
+			Scheme: "http",
+			Host:   utils.JoinHostPort(node.GetURL(), node.GetAPIPort()),
+		})
+	}
+	p.targetsLock.Lock()
+	p.targets = targets
+	p.targetsLock.Unlock()
+}
+
+// Forwards [req] to the blockchain endpoint of the next healthy node.
+// Responds with 503 if there are no healthy nodes.
+func (p *blockchainProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p.targetsLock.RLock()
+	targets := p.targets
+	p.targetsLock.RUnlock()
+	if len(targets) == 0 {
+		http.Error(w, "no healthy nodes", http.StatusServiceUnavailable)
+		return
+	}
+	target := targets[(atomic.AddUint64(&p.next, 1)-1)%uint64(len(targets))]
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			r.URL.Path = p.blockchainPath + r.URL.Path
+			r.URL.RawPath = ""
+			r.Host = target.Host
+		},
+	}
+	proxy.ServeHTTP(w, req)
+}
+
+// Stops the proxy, waiting for the in flight requests to complete.
+// It can be called many times.
+func (p *blockchainProxy) stop() {
+	p.stopOnce.Do(func() {
+		close(p.closeCh)
+		ctx, cancel := context.WithTimeout(context.Background(), proxyShutdownTimeout)
+		defer cancel()
+		if err := p.server.Shutdown(ctx); err != nil {
+			p.ln.log.Debug("error shutting down blockchain proxy", zap.Error(err))
+		}
+	})
+}
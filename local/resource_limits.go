@@ -0,0 +1,31 @@
+package local
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ava-labs/avalanchego/utils/units"
+)
+
+const (
+	// cgroup under which a cgroup is created for each limited node process
+	cgroupsParentName = "avalanche-network-runner"
+	// period over which the cpu limit of a node process is enforced, in microseconds
+	cgroupCPUPeriod = 100_000
+	// minimum cpu quota accepted by the kernel, in microseconds
+	cgroupMinCPUQuota = 1_000
+)
+
+// Returns the cgroup v2 cpu.max value limiting a process to [cpuLimit] cores
+func cgroupCPUMax(cpuLimit float64) string {
+	quota := uint64(cpuLimit * cgroupCPUPeriod)
+	if quota < cgroupMinCPUQuota {
+		quota = cgroupMinCPUQuota
+	}
+	return fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)
+}
+
+// Returns the cgroup v2 memory.max value limiting a process to [memLimitMB] MB
+func cgroupMemoryMax(memLimitMB uint64) string {
+	return strconv.FormatUint(memLimitMB*units.MiB, 10)
+}
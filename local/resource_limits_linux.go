@@ -0,0 +1,59 @@
+//go:build linux
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// mount point of the cgroup v2 unified hierarchy
+const cgroupRoot = "/sys/fs/cgroup"
+
+// Moves the process [pid] of node [nodeName] to a new cgroup limiting
+// its CPU usage to [cpuLimit] cores and its memory to [memLimitMB] MB.
+// A zero limit is not applied.
+// Returns a function that removes the cgroup, to be called once the process exits.
+func applyResourceLimits(nodeName string, pid int, cpuLimit float64, memLimitMB uint64) (func() error, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("resource limits need cgroup v2 mounted at %q: %w", cgroupRoot, err)
+	}
+	parentDir := filepath.Join(cgroupRoot, cgroupsParentName)
+	if err := os.MkdirAll(parentDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("couldn't create cgroup %q: %w", parentDir, err)
+	}
+	// enable the controllers for the node cgroups
+	if err := os.WriteFile(filepath.Join(parentDir, "cgroup.subtree_control"), []byte("+cpu +memory"), 0o644); err != nil {
+		return nil, fmt.Errorf("couldn't enable cpu and memory controllers on cgroup %q: %w", parentDir, err)
+	}
+	cgroupDir := filepath.Join(parentDir, fmt.Sprintf("%s-%d", nodeName, pid))
+	if err := os.Mkdir(cgroupDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("couldn't create cgroup %q: %w", cgroupDir, err)
+	}
+	removeCgroup := func() error {
+		if err := os.Remove(cgroupDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("couldn't remove cgroup %q: %w", cgroupDir, err)
+		}
+		return nil
+	}
+	limits := map[string]string{}
+	if cpuLimit > 0 {
+		limits["cpu.max"] = cgroupCPUMax(cpuLimit)
+	}
+	if memLimitMB > 0 {
+		limits["memory.max"] = cgroupMemoryMax(memLimitMB)
+	}
+	for fileName, limit := range limits {
+		if err := os.WriteFile(filepath.Join(cgroupDir, fileName), []byte(limit), 0o644); err != nil {
+			_ = removeCgroup()
+			return nil, fmt.Errorf("couldn't set %s of cgroup %q: %w", fileName, cgroupDir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(cgroupDir, "cgroup.procs"), []byte(fmt.Sprint(pid)), 0o644); err != nil {
+		_ = removeCgroup()
+		return nil, fmt.Errorf("couldn't move process %d to cgroup %q: %w", pid, cgroupDir, err)
+	}
+	return removeCgroup, nil
+}
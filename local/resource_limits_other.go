@@ -0,0 +1,8 @@
+//go:build !linux
+
+package local
+
+// Resource limits are only supported on Linux, so they are ignored.
+func applyResourceLimits(string, int, float64, uint64) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -1,10 +1,13 @@
 package local
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,6 +20,7 @@ import (
 	"github.com/ava-labs/avalanchego/utils/constants"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	dircopy "github.com/otiai10/copy"
+	"go.uber.org/zap"
 )
 
 // NewNetwork returns a new network from the given snapshot
@@ -30,6 +34,7 @@ func NewNetworkFromSnapshot(
 	chainConfigs map[string]string,
 	upgradeConfigs map[string]string,
 	flags map[string]interface{},
+	opts ...network.LoadSnapshotOption,
 ) (network.Network, error) {
 	net, err := newNetwork(
 		log,
@@ -46,13 +51,23 @@ func NewNetworkFromSnapshot(
 	if err != nil {
 		return net, err
 	}
-	err = net.loadSnapshot(context.Background(), snapshotName, binaryPath, buildDir, chainConfigs, upgradeConfigs, flags)
+	err = net.loadSnapshot(context.Background(), snapshotName, binaryPath, buildDir, chainConfigs, upgradeConfigs, flags, opts...)
 	return net, err
 }
 
+// snapshot info needed to load it, besides the network config
+type snapshotMetadata struct {
+	// Compression of the node dbs. Snapshots without metadata are uncompressed.
+	Compression string `json:"compression"`
+	// Versions of the runner and avalanchego the snapshot was saved with.
+	// Not present on snapshots saved before it was recorded.
+	BuildInfo *network.BuildInfo `json:"buildInfo,omitempty"`
+}
+
 // Save network snapshot
-// Network is stopped in order to do a safe preservation
-func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (string, error) {
+// Network is stopped in order to do a safe preservation, and is left stopped
+// even if saving fails or [ctx] is cancelled, in which case no snapshot is written
+func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string, opts ...network.SnapshotOption) (string, error) {
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 	if ln.stopCalled() {
@@ -61,6 +76,15 @@ func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (
 	if len(snapshotName) == 0 {
 		return "", fmt.Errorf("invalid snapshotName %q", snapshotName)
 	}
+	op := network.NewSnapshotOp(opts...)
+	switch op.Compression {
+	case network.SnapshotCompressionNone, network.SnapshotCompressionGzip:
+	default:
+		return "", fmt.Errorf("unknown snapshot compression %q", op.Compression)
+	}
+	if op.MaxConcurrency == 0 {
+		return "", errors.New("max concurrency must be greater than 0")
+	}
 	// check if snapshot already exists
 	snapshotDir := filepath.Join(ln.snapshotsDir, snapshotPrefix+snapshotName)
 	_, err := os.Stat(snapshotDir)
@@ -105,27 +129,48 @@ func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (
 		nodesConfig[nodeName] = nodeConfig
 	}
 
+	// the nodes are queried for their version before they are stopped
+	buildInfo := ln.getBuildInfo(ctx)
 	// stop network to safely save snapshot
 	if err := ln.stop(ctx); err != nil {
 		return "", err
 	}
+	// the snapshot is written to a temp dir, only renamed to [snapshotDir] once complete,
+	// so that no partial snapshot is left behind on failure or cancellation
+	if err := os.MkdirAll(ln.snapshotsDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	tmpSnapshotDir, err := os.MkdirTemp(ln.snapshotsDir, tmpSnapshotPrefix+snapshotName+"-")
+	if err != nil {
+		return "", err
+	}
+	// no-op after a successful rename
+	defer os.RemoveAll(tmpSnapshotDir)
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	// create main snapshot dirs
-	snapshotDbDir := filepath.Join(filepath.Join(snapshotDir, defaultDbSubdir))
+	snapshotDbDir := filepath.Join(filepath.Join(tmpSnapshotDir, defaultDbSubdir))
 	err = os.MkdirAll(snapshotDbDir, os.ModePerm)
 	if err != nil {
 		return "", err
 	}
-	// save db
-	for _, nodeConfig := range nodesConfig {
-		sourceDbDir, ok := nodesDbDir[nodeConfig.Name]
+	// save dbs, [op.MaxConcurrency] of them at a time
+	nodeNames := make([]string, 0, len(nodesConfig))
+	for nodeName := range nodesConfig {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	if err := forEachName(ctx, nodeNames, op.MaxConcurrency, func(ctx context.Context, nodeName string) error {
+		sourceDbDir, ok := nodesDbDir[nodeName]
 		if !ok {
-			return "", fmt.Errorf("failure obtaining db path for node %q", nodeConfig.Name)
+			return fmt.Errorf("failure obtaining db path for node %q", nodeName)
 		}
-		sourceDbDir = filepath.Join(sourceDbDir, constants.NetworkName(ln.networkID))
-		targetDbDir := filepath.Join(filepath.Join(snapshotDbDir, nodeConfig.Name), constants.NetworkName(ln.networkID))
-		if err := dircopy.Copy(sourceDbDir, targetDbDir); err != nil {
-			return "", fmt.Errorf("failure saving node %q db dir: %w", nodeConfig.Name, err)
+		if err := ln.saveNodeDb(ctx, nodeName, sourceDbDir, snapshotDbDir, op.Compression); err != nil {
+			return fmt.Errorf("failure saving node %q db dir: %w", nodeName, err)
 		}
+		return nil
+	}); err != nil {
+		return "", err
 	}
 	// save network conf
 	networkConfig := network.Config{
@@ -135,6 +180,9 @@ func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (
 		BinaryPath:         ln.binaryPath,
 		ChainConfigFiles:   ln.chainConfigFiles,
 		UpgradeConfigFiles: ln.upgradeConfigFiles,
+		SubnetConfigFiles:  ln.subnetConfigFiles,
+
+		HealthCheckConcurrency: ln.healthCheckConcurrency,
 	}
 
 	for _, nodeConfig := range nodesConfig {
@@ -145,14 +193,52 @@ func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (
 	if err != nil {
 		return "", err
 	}
-	err = createFileAndWrite(filepath.Join(snapshotDir, "network.json"), networkConfigJSON)
+	err = createFileAndWrite(filepath.Join(tmpSnapshotDir, "network.json"), networkConfigJSON)
+	if err != nil {
+		return "", err
+	}
+	metadataJSON, err := json.MarshalIndent(snapshotMetadata{Compression: op.Compression, BuildInfo: &buildInfo}, "", "    ")
 	if err != nil {
 		return "", err
 	}
+	err = createFileAndWrite(filepath.Join(tmpSnapshotDir, snapshotMetadataFileName), metadataJSON)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpSnapshotDir, snapshotDir); err != nil {
+		return "", fmt.Errorf("failure moving snapshot %q into place: %w", snapshotName, err)
+	}
 	return snapshotDir, nil
 }
 
+// Saves the db of the network of node [nodeName], under [sourceDbDir], to the snapshot
+// db dir [snapshotDbDir], with [compression]. Aborts if [ctx] is cancelled.
+func (ln *localNetwork) saveNodeDb(
+	ctx context.Context,
+	nodeName string,
+	sourceDbDir string,
+	snapshotDbDir string,
+	compression string,
+) error {
+	sourceDbDir = filepath.Join(sourceDbDir, constants.NetworkName(ln.networkID))
+	if compression == network.SnapshotCompressionGzip {
+		return compressDir(ctx, sourceDbDir, filepath.Join(snapshotDbDir, nodeName+gzipArchiveSuffix))
+	}
+	targetDbDir := filepath.Join(filepath.Join(snapshotDbDir, nodeName), constants.NetworkName(ln.networkID))
+	copyOpts := dircopy.Options{
+		// aborts the copy on cancellation
+		Skip: func(string) (bool, error) {
+			return false, ctx.Err()
+		},
+	}
+	return dircopy.Copy(sourceDbDir, targetDbDir, copyOpts)
+}
+
 // start network from snapshot
+// Returns ErrAlreadyInitialized if a config or snapshot was already loaded.
 func (ln *localNetwork) loadSnapshot(
 	ctx context.Context,
 	snapshotName string,
@@ -161,9 +247,13 @@ func (ln *localNetwork) loadSnapshot(
 	chainConfigs map[string]string,
 	upgradeConfigs map[string]string,
 	flags map[string]interface{},
+	opts ...network.LoadSnapshotOption,
 ) error {
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
+	if ln.initialized() {
+		return network.ErrAlreadyInitialized
+	}
 	snapshotDir := filepath.Join(ln.snapshotsDir, snapshotPrefix+snapshotName)
 	snapshotDbDir := filepath.Join(filepath.Join(snapshotDir, defaultDbSubdir))
 	_, err := os.Stat(snapshotDir)
@@ -184,18 +274,57 @@ func (ln *localNetwork) loadSnapshot(
 	if err != nil {
 		return fmt.Errorf("failure unmarshaling network config from snapshot: %w", err)
 	}
+	// load metadata, not present on snapshots saved before compression was supported
+	metadata := snapshotMetadata{}
+	metadataJSON, err := os.ReadFile(filepath.Join(snapshotDir, snapshotMetadataFileName))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+			return fmt.Errorf("failure unmarshaling metadata from snapshot: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failure reading metadata file from snapshot: %w", err)
+	}
+	if metadata.BuildInfo != nil {
+		ln.log.Info("loading snapshot",
+			zap.String("name", snapshotName),
+			zap.String("runnerVersion", metadata.BuildInfo.RunnerVersion),
+			zap.String("avalanchegoLibVersion", metadata.BuildInfo.AvalancheGoLibVersion),
+			zap.String("avalanchegoVersion", metadata.BuildInfo.AvalancheGoVersion),
+			zap.String("avalanchegoCommit", metadata.BuildInfo.AvalancheGoCommit),
+		)
+	}
 	// add flags
 	for i := range networkConfig.NodeConfigs {
 		for k, v := range flags {
 			networkConfig.NodeConfigs[i].Flags[k] = v
 		}
 	}
+	// replace saved ports, that may not be available on this machine.
+	// beacon addresses are derived from the ports the nodes are started with
+	if err := overrideSnapshotPorts(&networkConfig, network.NewLoadSnapshotOp(opts...)); err != nil {
+		return err
+	}
 	// load db
 	for _, nodeConfig := range networkConfig.NodeConfigs {
-		sourceDbDir := filepath.Join(snapshotDbDir, nodeConfig.Name)
 		targetDbDir := filepath.Join(filepath.Join(ln.rootDir, nodeConfig.Name), defaultDbSubdir)
-		if err := dircopy.Copy(sourceDbDir, targetDbDir); err != nil {
-			return fmt.Errorf("failure loading node %q db dir: %w", nodeConfig.Name, err)
+		switch metadata.Compression {
+		case network.SnapshotCompressionNone:
+			sourceDbDir := filepath.Join(snapshotDbDir, nodeConfig.Name)
+			if err := dircopy.Copy(sourceDbDir, targetDbDir); err != nil {
+				return fmt.Errorf("failure loading node %q db dir: %w", nodeConfig.Name, err)
+			}
+		case network.SnapshotCompressionGzip:
+			networkID, err := utils.NetworkIDFromGenesis([]byte(networkConfig.Genesis))
+			if err != nil {
+				return fmt.Errorf("couldn't get network ID from snapshot genesis: %w", err)
+			}
+			sourceDbArchive := filepath.Join(snapshotDbDir, nodeConfig.Name+gzipArchiveSuffix)
+			if err := decompressDir(sourceDbArchive, filepath.Join(targetDbDir, constants.NetworkName(networkID))); err != nil {
+				return fmt.Errorf("failure loading node %q db dir: %w", nodeConfig.Name, err)
+			}
+		default:
+			return fmt.Errorf("unknown snapshot compression %q", metadata.Compression)
 		}
 		nodeConfig.Flags[config.DBPathKey] = targetDbDir
 	}
@@ -226,11 +355,67 @@ func (ln *localNetwork) loadSnapshot(
 			networkConfig.NodeConfigs[i].UpgradeConfigFiles[k] = v
 		}
 	}
-	return ln.loadConfig(ctx, networkConfig)
+	if err := ln.loadConfig(ctx, networkConfig); err != nil {
+		return err
+	}
+	ln.loadedSnapshotName = snapshotName
+	return nil
+}
+
+// Replaces the saved ports of the nodes of [networkConfig] with the ones given in [op].
+// If [op] gives a port range, the ports of the other nodes are allocated from it.
+func overrideSnapshotPorts(networkConfig *network.Config, op *network.LoadSnapshotOp) error {
+	nodeNames := map[string]struct{}{}
+	for _, nodeConfig := range networkConfig.NodeConfigs {
+		nodeNames[nodeConfig.Name] = struct{}{}
+	}
+	for nodeName := range op.PortOverrides {
+		if _, ok := nodeNames[nodeName]; !ok {
+			return fmt.Errorf("port override given for node %q not found in snapshot", nodeName)
+		}
+	}
+	reallocate := op.MinPort != 0 || op.MaxPort != 0
+	if reallocate {
+		networkConfig.MinPort = op.MinPort
+		networkConfig.MaxPort = op.MaxPort
+	}
+	for i := range networkConfig.NodeConfigs {
+		nodeConfig := &networkConfig.NodeConfigs[i]
+		portOverride, ok := op.PortOverrides[nodeConfig.Name]
+		if !ok && !reallocate {
+			continue
+		}
+		// drop the saved ports, so the node uses the overrides or gets new ones
+		for _, portKey := range []string{config.HTTPPortKey, config.StakingPortKey} {
+			delete(nodeConfig.Flags, portKey)
+			if nodeConfig.ConfigFile != "" {
+				var err error
+				nodeConfig.ConfigFile, err = utils.SetJSONKey(nodeConfig.ConfigFile, portKey, "")
+				if err != nil {
+					return err
+				}
+			}
+		}
+		nodeConfig.APIPort = portOverride.APIPort
+		nodeConfig.P2PPort = portOverride.P2PPort
+	}
+	return nil
 }
 
 // Remove network snapshot
+// Returns ErrSnapshotInUse if the network was loaded from it
 func (ln *localNetwork) RemoveSnapshot(snapshotName string) error {
+	ln.lock.RLock()
+	inUse := !ln.stopCalled() && ln.loadedSnapshotName == snapshotName
+	ln.lock.RUnlock()
+	if inUse {
+		return fmt.Errorf("failure removing snapshot %q: %w", snapshotName, ErrSnapshotInUse)
+	}
+	return ln.RemoveSnapshotForce(snapshotName)
+}
+
+// Remove network snapshot, even if the network was loaded from it
+func (ln *localNetwork) RemoveSnapshotForce(snapshotName string) error {
 	snapshotDir := filepath.Join(ln.snapshotsDir, snapshotPrefix+snapshotName)
 	_, err := os.Stat(snapshotDir)
 	if err != nil {
@@ -243,6 +428,11 @@ func (ln *localNetwork) RemoveSnapshot(snapshotName string) error {
 	if err := os.RemoveAll(snapshotDir); err != nil {
 		return fmt.Errorf("failure removing snapshot path %q: %w", snapshotDir, err)
 	}
+	ln.lock.Lock()
+	if ln.loadedSnapshotName == snapshotName {
+		ln.loadedSnapshotName = ""
+	}
+	ln.lock.Unlock()
 	return nil
 }
 
@@ -266,3 +456,120 @@ func (ln *localNetwork) GetSnapshotNames() ([]string, error) {
 	}
 	return snapshots, nil
 }
+
+// Writes the contents of [srcDir] to [archivePath], as a gzip compressed tar archive
+// with paths relative to [srcDir]. Aborts if [ctx] is cancelled.
+func compressDir(ctx context.Context, srcDir string, archivePath string) error {
+	archiveFile, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archiveFile.Close()
+	gzipWriter := gzip.NewWriter(archiveFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if !info.Mode().IsDir() && !info.Mode().IsRegular() {
+			return fmt.Errorf("unsupported file type of %q", path)
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tarWriter, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+	return archiveFile.Close()
+}
+
+// Extracts the gzip compressed tar archive [archivePath], written by compressDir, into [dstDir].
+func decompressDir(archivePath string, dstDir string) error {
+	archiveFile, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archiveFile.Close()
+	gzipReader, err := gzip.NewReader(archiveFile)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+	if err := os.MkdirAll(dstDir, os.ModePerm); err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dstDir, filepath.FromSlash(header.Name))
+		// don't write outside of [dstDir]
+		if !strings.HasPrefix(path, filepath.Clean(dstDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path %q in archive", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.FileMode(header.Mode)|0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+				return err
+			}
+			if err := extractFile(tarReader, path, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported type of %q in archive", header.Name)
+		}
+	}
+}
+
+func extractFile(r io.Reader, path string, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,197 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ava-labs/avalanche-network-runner/network"
+	"github.com/ava-labs/avalanchego/api/info"
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// description of a blockchain, as exported in the network topology
+type blockchainTopology struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	VMID string `json:"vmID"`
+}
+
+// description of a subnet validated by a node, as exported in the network topology
+type subnetTopology struct {
+	ID          string               `json:"id"`
+	Blockchains []blockchainTopology `json:"blockchains"`
+}
+
+// description of a node, as exported in the network topology
+type nodeTopology struct {
+	Name        string           `json:"name"`
+	NodeID      string           `json:"nodeID"`
+	URL         string           `json:"url"`
+	APIPort     uint16           `json:"apiPort"`
+	StakingPort uint16           `json:"stakingPort"`
+	Beacon      bool             `json:"beacon"`
+	Subnets     []subnetTopology `json:"subnets"`
+}
+
+// See network.Network
+func (ln *localNetwork) ExportTopology(ctx context.Context) ([]byte, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	node := ln.getSomeNode()
+	if node == nil {
+		return nil, errors.New("no nodes available to query the P-Chain")
+	}
+	platformCli := node.GetAPIClient().PChainAPI()
+
+	cctx, cancel := createDefaultCtx(ctx)
+	subnets, err := platformCli.GetSubnets(cctx, nil)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failure getting subnets: %w", err)
+	}
+	cctx, cancel = createDefaultCtx(ctx)
+	blockchains, err := platformCli.GetBlockchains(cctx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failure getting blockchains: %w", err)
+	}
+	subnetBlockchains := map[ids.ID][]blockchainTopology{}
+	for _, blockchain := range blockchains {
+		subnetBlockchains[blockchain.SubnetID] = append(subnetBlockchains[blockchain.SubnetID], blockchainTopology{
+			Name: blockchain.Name,
+			ID:   blockchain.ID.String(),
+			VMID: blockchain.VMID.String(),
+		})
+	}
+
+	// subnets validated by each node
+	nodeSubnets := map[ids.NodeID][]subnetTopology{}
+	for _, subnet := range subnets {
+		cctx, cancel := createDefaultCtx(ctx)
+		vs, err := platformCli.GetCurrentValidators(cctx, subnet.ID, nil)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failure getting validators of subnet %s: %w", subnet.ID, err)
+		}
+		for _, v := range vs {
+			nodeSubnets[v.NodeID] = append(nodeSubnets[v.NodeID], subnetTopology{
+				ID:          subnet.ID.String(),
+				Blockchains: subnetBlockchains[subnet.ID],
+			})
+		}
+	}
+
+	nodeNames := make([]string, 0, len(ln.nodes))
+	for nodeName := range ln.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	topology := make([]nodeTopology, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		node := ln.nodes[nodeName]
+		topology = append(topology, nodeTopology{
+			Name:        nodeName,
+			NodeID:      node.GetNodeID().String(),
+			URL:         node.GetURL(),
+			APIPort:     node.GetAPIPort(),
+			StakingPort: node.GetP2PPort(),
+			Beacon:      node.config.IsBeacon,
+			Subnets:     nodeSubnets[node.GetNodeID()],
+		})
+	}
+	return json.MarshalIndent(topology, "", "  ")
+}
+
+// See network.Network
+func (ln *localNetwork) GetPeers(ctx context.Context, nodeName string) ([]network.PeerInfo, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	// node ID --> name, to name the peers that are nodes of the network
+	nodeNames := make(map[ids.NodeID]string, len(ln.nodes))
+	for name, node := range ln.nodes {
+		nodeNames[node.GetNodeID()] = name
+	}
+	peers, err := getNodePeers(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	peerInfos := make([]network.PeerInfo, len(peers))
+	for i, peer := range peers {
+		peerInfos[i] = network.PeerInfo{
+			NodeID:         peer.ID,
+			NodeName:       nodeNames[peer.ID],
+			IP:             peer.IP,
+			Version:        peer.Version,
+			LastSent:       peer.LastSent,
+			LastReceived:   peer.LastReceived,
+			ObservedUptime: uint8(peer.ObservedUptime),
+			TrackedSubnets: peer.TrackedSubnets,
+			Benched:        peer.Benched,
+		}
+	}
+	return peerInfos, nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetConnectivityMatrix(ctx context.Context) (map[string][]ids.NodeID, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+
+	var matrixLock sync.Mutex
+	matrix := make(map[string][]ids.NodeID, len(ln.nodes))
+	err := forEachNode(ctx, ln.runningNodes(), ln.healthCheckConcurrency, func(ctx context.Context, nodeName string, node *localNode) error {
+		peers, err := getNodePeers(ctx, node)
+		if err != nil {
+			return err
+		}
+		peerIDs := make([]ids.NodeID, len(peers))
+		for i, peer := range peers {
+			peerIDs[i] = peer.ID
+		}
+		matrixLock.Lock()
+		matrix[nodeName] = peerIDs
+		matrixLock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matrix, nil
+}
+
+// Returns the peers of [node], sorted by node ID.
+func getNodePeers(ctx context.Context, node *localNode) ([]info.Peer, error) {
+	cctx, cancel := createNodeCtx(ctx, node)
+	peers, err := node.client.InfoAPI().Peers(cctx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failure getting peers of node %q: %w", node.GetName(), err)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return bytes.Compare(peers[i].ID[:], peers[j].ID[:]) < 0
+	})
+	return peers, nil
+}
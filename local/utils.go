@@ -2,10 +2,12 @@ package local
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 	"net"
+	"syscall"
 	"time"
 )
 
@@ -14,16 +16,16 @@ func init() {
 }
 
 const (
-	maxPort          = math.MaxUint16
-	minPort          = 10000
+	defaultMaxPort   = math.MaxUint16
+	defaultMinPort   = 10000
 	netListenTimeout = 3 * time.Second
 )
 
-// getFreePort generates a random port number and then
-// verifies it is free. If it is, returns that port, otherwise retries.
+// getFreePort generates a random port number in [minPort, maxPort], not in
+// [usedPorts], and then verifies it is free. If it is, returns that port, otherwise retries.
 // Returns an error if no free port is found within [netListenTimeout].
 // Note that it is possible for [getFreePort] to return the same port twice.
-func getFreePort() (uint16, error) {
+func getFreePort(minPort uint16, maxPort uint16, usedPorts map[uint16]struct{}) (uint16, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), netListenTimeout)
 	defer cancel()
 	for {
@@ -32,7 +34,10 @@ func getFreePort() (uint16, error) {
 			return 0, ctx.Err()
 		default:
 			// Generate random port in [minPort, maxPort]
-			port := uint16(rand.Intn(maxPort-minPort+1) + minPort)
+			port := uint16(rand.Intn(int(maxPort)-int(minPort)+1) + int(minPort))
+			if _, ok := usedPorts[port]; ok {
+				continue
+			}
 			// Verify it's free by binding to it
 			l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 			if err != nil {
@@ -45,3 +50,20 @@ func getFreePort() (uint16, error) {
 		}
 	}
 }
+
+// checkPortFree returns ErrPortInUse if [port] is already bound, or is in [usedPorts].
+// Other errors binding to [port] are left for the node to report.
+func checkPortFree(port uint16, usedPorts map[uint16]struct{}) error {
+	if _, ok := usedPorts[port]; ok {
+		return fmt.Errorf("%w: %d", ErrPortInUse, port)
+	}
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return fmt.Errorf("%w: %d", ErrPortInUse, port)
+		}
+		return nil
+	}
+	_ = l.Close()
+	return nil
+}
@@ -1,19 +1,27 @@
 package network
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ava-labs/avalanche-network-runner/network/node"
 	"github.com/ava-labs/avalanche-network-runner/utils"
+	"github.com/ava-labs/avalanchego/config"
 	"github.com/ava-labs/avalanchego/genesis"
 	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/cb58"
 	"github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/crypto"
 	"github.com/ava-labs/avalanchego/utils/formatting/address"
 	"github.com/ava-labs/avalanchego/utils/units"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 )
 
 var cChainConfig map[string]interface{}
@@ -21,6 +29,10 @@ var cChainConfig map[string]interface{}
 const (
 	validatorStake         = units.MegaAvax
 	defaultCChainConfigStr = "{\"config\":{\"chainId\":43115,\"homesteadBlock\":0,\"daoForkBlock\":0,\"daoForkSupport\":true,\"eip150Block\":0,\"eip150Hash\":\"0x2086799aeebeae135c246c65021c82b4e15a2c451340993aacfd2751886514f0\",\"eip155Block\":0,\"eip158Block\":0,\"byzantiumBlock\":0,\"constantinopleBlock\":0,\"petersburgBlock\":0,\"istanbulBlock\":0,\"muirGlacierBlock\":0,\"apricotPhase1BlockTimestamp\":0,\"apricotPhase2BlockTimestamp\":0,\"apricotPhase3BlockTimestamp\":0,\"apricotPhase4BlockTimestamp\":0,\"apricotPhase5BlockTimestamp\":0},\"nonce\":\"0x0\",\"timestamp\":\"0x0\",\"extraData\":\"0x00\",\"gasLimit\":\"0x5f5e100\",\"difficulty\":\"0x0\",\"mixHash\":\"0x0000000000000000000000000000000000000000000000000000000000000000\",\"coinbase\":\"0x0000000000000000000000000000000000000000\",\"number\":\"0x0\",\"gasUsed\":\"0x0\",\"parentHash\":\"0x0000000000000000000000000000000000000000000000000000000000000000\"}"
+	// balance of each funded key of a generated genesis, on each chain
+	fundedKeyBalance = 10 * units.MegaAvax
+	// C-Chain balances are given in wei, and X-Chain and P-Chain balances in nAVAX
+	weiPerNAVAX = 1_000_000_000
 )
 
 func init() {
@@ -37,7 +49,8 @@ type AddrAndBalance struct {
 
 // Config that defines a network when it is created.
 type Config struct {
-	// Must not be empty
+	// Must not be empty.
+	// Validated as the nodes do, e.g. supply and initial stakers, before they are started.
 	Genesis string `json:"genesis"`
 	// May have length 0
 	// (i.e. network may have no nodes on creation.)
@@ -61,39 +74,162 @@ type Config struct {
 	ChainConfigFiles map[string]string `json:"chainConfigFiles"`
 	// Upgrade config files to use per default, if not specified in node config
 	UpgradeConfigFiles map[string]string `json:"upgradeConfigFiles"`
+	// Subnet config files to use per default, if not specified in node config
+	SubnetConfigFiles map[string]string `json:"subnetConfigFiles"`
+	// Blockchain ID --> aliases given to it by every node.
+	// Not supported yet: the AvalancheGo version the runner is built against
+	// can't read chain aliases on startup, so Validate rejects any alias.
+	// Aliases may still be added at runtime through the admin API aliasChain
+	// endpoint of each node, and are lost when it restarts.
+	// Must be empty.
+	Aliases ChainAliases `json:"aliases"`
+	// Directory under which all node databases, logs and config files are written.
+	// It is created if it doesn't exist.
+	// If empty, the network root directory is used.
+	RootDataDir string `json:"rootDataDir"`
+	// Range of ports from which node ports not given explicitly are allocated.
+	// If 0, MinPort defaults to 10000 and MaxPort to 65535.
+	MinPort uint16 `json:"minPort"`
+	MaxPort uint16 `json:"maxPort"`
+	// Maximum number of nodes queried at a time by the health checks of the network,
+	// e.g. Healthy, so that large networks don't open a connection to every node at once.
+	// Setup operations are limited by their own maximum concurrency instead.
+	// If 0, DefaultMaxConcurrency is used.
+	HealthCheckConcurrency uint32 `json:"healthCheckConcurrency"`
 }
 
-// Validate returns an error if this config is invalid
+// ChainAliases maps blockchain IDs to their aliases
+type ChainAliases map[ids.ID][]string
+
+// UnmarshalJSON decodes the aliases from an object keyed by the CB58 encoded
+// blockchain IDs, which ids.ID can't decode as map keys
+func (a *ChainAliases) UnmarshalJSON(b []byte) error {
+	var unparsedAliases map[string][]string
+	if err := json.Unmarshal(b, &unparsedAliases); err != nil {
+		return err
+	}
+	if unparsedAliases == nil {
+		*a = nil
+		return nil
+	}
+	aliases := make(ChainAliases, len(unparsedAliases))
+	for chainIDStr, chainAliases := range unparsedAliases {
+		chainID, err := ids.FromString(chainIDStr)
+		if err != nil {
+			return fmt.Errorf("couldn't parse blockchain ID %q: %w", chainIDStr, err)
+		}
+		aliases[chainID] = chainAliases
+	}
+	*a = aliases
+	return nil
+}
+
+// Validate returns an error if this config is invalid.
+// All the problems found are described in the error, not just the first one.
 func (c *Config) Validate() error {
-	var someNodeIsBeacon bool
-	switch {
-	case len(c.Genesis) == 0:
+	if len(c.Genesis) == 0 {
 		return errors.New("no genesis given")
 	}
+	errs := []string{}
 	networkID, err := utils.NetworkIDFromGenesis([]byte(c.Genesis))
 	if err != nil {
-		return fmt.Errorf("couldn't get network ID from genesis: %w", err)
+		errs = append(errs, fmt.Sprintf("couldn't get network ID from genesis: %s", err))
+	} else if _, _, err := genesis.FromFlag(networkID, base64.StdEncoding.EncodeToString([]byte(c.Genesis))); err != nil {
+		// the nodes would fail to start with the same error, as the genesis
+		// given to them is checked the same way, but only the flag content
+		// is accepted base64 encoded
+		errs = append(errs, fmt.Sprintf("invalid genesis: %s", err))
+	}
+	if len(c.Aliases) != 0 {
+		errs = append(errs, "chain aliases are not supported, add them with the admin API aliasChain endpoint of the nodes instead")
+	}
+	if c.MinPort != 0 && c.MaxPort != 0 && c.MinPort > c.MaxPort {
+		errs = append(errs, fmt.Sprintf("min port %d is greater than max port %d", c.MinPort, c.MaxPort))
 	}
+	var someNodeIsBeacon bool
+	nodeNames := map[string]struct{}{}
+	// port --> name of the node using it
+	usedPorts := map[uint16]string{}
+	// staking cert --> name of the node using it
+	usedStakingCerts := map[string]string{}
 	for i, nodeConfig := range c.NodeConfigs {
-		if err := nodeConfig.Validate(networkID); err != nil {
-			var nodeName string
-			if len(nodeConfig.Name) > 0 {
-				nodeName = nodeConfig.Name
+		var nodeName string
+		if len(nodeConfig.Name) > 0 {
+			nodeName = nodeConfig.Name
+			if _, ok := nodeNames[nodeName]; ok {
+				errs = append(errs, fmt.Sprintf("repeated node name %q", nodeName))
+			}
+			nodeNames[nodeName] = struct{}{}
+		} else {
+			nodeName = strconv.Itoa(i)
+		}
+		if err == nil {
+			if err := nodeConfig.Validate(networkID); err != nil {
+				errs = append(errs, fmt.Sprintf("node %q config failed validation: %s", nodeName, err))
+			}
+		}
+		for _, nodePort := range []struct {
+			key       string
+			requested uint16
+		}{
+			{config.HTTPPortKey, nodeConfig.APIPort},
+			{config.StakingPortKey, nodeConfig.P2PPort},
+		} {
+			port, ok := nodePort.requested, nodePort.requested != 0
+			if !ok {
+				port, ok = getPortFlag(nodeConfig.Flags, nodePort.key)
+			}
+			if !ok {
+				continue
+			}
+			if otherNodeName, ok := usedPorts[port]; ok {
+				errs = append(errs, fmt.Sprintf("node %q port %d already used by node %q", nodeName, port, otherNodeName))
+				continue
+			}
+			usedPorts[port] = nodeName
+		}
+		if nodeConfig.StakingCert != "" {
+			if otherNodeName, ok := usedStakingCerts[nodeConfig.StakingCert]; ok {
+				errs = append(errs, fmt.Sprintf("node %q staking cert already used by node %q", nodeName, otherNodeName))
 			} else {
-				nodeName = strconv.Itoa(i)
+				usedStakingCerts[nodeConfig.StakingCert] = nodeName
 			}
-			return fmt.Errorf("node %q config failed validation: %w", nodeName, err)
 		}
 		if nodeConfig.IsBeacon {
 			someNodeIsBeacon = true
 		}
 	}
 	if len(c.NodeConfigs) > 0 && !someNodeIsBeacon {
-		return errors.New("beacon nodes not given")
+		errs = append(errs, "beacon nodes not given")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
 	}
 	return nil
 }
 
+// Returns the port set for [portKey] in [flags], if any
+func getPortFlag(flags map[string]interface{}, portKey string) (uint16, bool) {
+	switch port := flags[portKey].(type) {
+	case int:
+		return uint16(port), true
+	case float64:
+		return uint16(port), true
+	default:
+		return 0, false
+	}
+}
+
+// StakerSpec defines an initial staker of a generated genesis
+type StakerSpec struct {
+	NodeID ids.NodeID
+	// Address receiving the staking rewards.
+	// If empty, a random address is used.
+	RewardAddr ids.ShortID
+	// Delegation fee, in ten-thousandths of a percent (e.g. 10_000 is 1%)
+	DelegationFee uint32
+}
+
 // Return a genesis JSON where:
 // The nodes in [genesisVdrs] are validators.
 // The C-Chain and X-Chain balances are given by
@@ -116,6 +252,184 @@ func NewAvalancheGoGenesis(
 	case len(xChainBalances)+len(cChainBalances) == 0:
 		return nil, errors.New("no genesis balances given")
 	}
+	allocations := []genesis.UnparsedAllocation{}
+	for _, xChainBal := range xChainBalances {
+		xChainAddr, _ := address.Format("X", constants.GetHRP(networkID), xChainBal.Addr[:])
+		allocations = append(
+			allocations,
+			genesis.UnparsedAllocation{
+				ETHAddr:       "0x0000000000000000000000000000000000000000",
+				AVAXAddr:      xChainAddr,
+				InitialAmount: xChainBal.Balance,
+				UnlockSchedule: []genesis.LockedAmount{
+					{
+						Amount:   validatorStake * uint64(len(genesisVdrs)), // Stake
+						Locktime: uint64(time.Now().Add(7 * 24 * time.Hour).Unix()),
+					},
+				},
+			},
+		)
+	}
+	cChainAllocs := map[string]*big.Int{}
+	for _, cChainBal := range cChainBalances {
+		cChainAllocs[fmt.Sprintf("0x%s", cChainBal.Addr.Hex())] = new(big.Int).SetUint64(cChainBal.Balance)
+	}
+	stakers := make([]StakerSpec, len(genesisVdrs))
+	for i, genesisVdr := range genesisVdrs {
+		stakers[i] = StakerSpec{
+			NodeID:        genesisVdr,
+			DelegationFee: 10_000,
+		}
+	}
+	return newAvalancheGoGenesis(networkID, allocations, cChainAllocs, stakers)
+}
+
+// GenerateGenesis returns a genesis JSON where each of [fundedKeys], given
+// as "PrivateKey-" prefixed CB58 strings, is funded on the X-Chain,
+// the P-Chain (unlocked) and the C-Chain, and [stakers] are the initial stakers.
+// The funded keys can then be used to issue transactions on the network.
+func GenerateGenesis(
+	networkID uint32,
+	fundedKeys []string,
+	stakers []StakerSpec,
+) ([]byte, error) {
+	switch networkID {
+	case constants.TestnetID, constants.MainnetID, constants.LocalID:
+		return nil, errors.New("network ID can't be mainnet, testnet or local network ID")
+	}
+	switch {
+	case len(fundedKeys) == 0:
+		return nil, errors.New("no funded keys given")
+	case len(stakers) == 0:
+		return nil, errors.New("no stakers given")
+	}
+	allocations := []genesis.UnparsedAllocation{}
+	cChainAllocs := map[string]*big.Int{}
+	cChainBalance := new(big.Int).Mul(new(big.Int).SetUint64(fundedKeyBalance), big.NewInt(weiPerNAVAX))
+	for i, fundedKey := range fundedKeys {
+		key, err := ParsePrivateKey(fundedKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid funded key %d: %w", i, err)
+		}
+		avaxAddr, err := address.Format("X", constants.GetHRP(networkID), key.PublicKey().Address().Bytes())
+		if err != nil {
+			return nil, err
+		}
+		ethAddr := ethcrypto.PubkeyToAddress(key.ToECDSA().PublicKey).Hex()
+		allocations = append(
+			allocations,
+			genesis.UnparsedAllocation{
+				ETHAddr:       ethAddr,
+				AVAXAddr:      avaxAddr,
+				InitialAmount: fundedKeyBalance,
+				UnlockSchedule: []genesis.LockedAmount{
+					{
+						Amount: fundedKeyBalance,
+					},
+				},
+			},
+		)
+		cChainAllocs[ethAddr] = cChainBalance
+	}
+	return newAvalancheGoGenesis(networkID, allocations, cChainAllocs, stakers)
+}
+
+// ParsePrivateKey parses a "PrivateKey-" prefixed CB58 encoded secp256k1 private key
+func ParsePrivateKey(keyStr string) (*crypto.PrivateKeySECP256K1R, error) {
+	if !strings.HasPrefix(keyStr, crypto.PrivateKeyPrefix) {
+		return nil, fmt.Errorf("missing %s prefix", crypto.PrivateKeyPrefix)
+	}
+	keyBytes, err := cb58.Decode(strings.TrimPrefix(keyStr, crypto.PrivateKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+	factory := crypto.FactorySECP256K1R{}
+	key, err := factory.ToPrivateKey(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return key.(*crypto.PrivateKeySECP256K1R), nil
+}
+
+// FundedAddresses returns the addresses given spendable funds on the X-Chain or
+// the P-Chain by the allocations of [genesisBytes], sorted and formatted as in the
+// genesis (e.g. X-custom1...). The addresses only holding the initial stake are skipped.
+func FundedAddresses(genesisBytes []byte) ([]string, error) {
+	fundedAddrs, err := fundedAddresses(genesisBytes)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(fundedAddrs))
+	for _, addr := range fundedAddrs {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// FundedKeys returns the keys among [keys] whose address is funded by the
+// allocations of [genesisBytes], as "PrivateKey-" prefixed CB58 strings, in the
+// order of [keys]. Only the addresses are in the genesis, so the keys can't be
+// derived from it.
+func FundedKeys(genesisBytes []byte, keys []*crypto.PrivateKeySECP256K1R) ([]string, error) {
+	fundedAddrs, err := fundedAddresses(genesisBytes)
+	if err != nil {
+		return nil, err
+	}
+	fundedKeys := []string{}
+	for _, key := range keys {
+		if _, ok := fundedAddrs[key.PublicKey().Address()]; ok {
+			fundedKeys = append(fundedKeys, key.String())
+		}
+	}
+	return fundedKeys, nil
+}
+
+// Returns address --> address as formatted in [genesisBytes], for the addresses
+// given spendable funds by the genesis allocations.
+func fundedAddresses(genesisBytes []byte) (map[ids.ShortID]string, error) {
+	var config genesis.UnparsedConfig
+	if err := json.Unmarshal(genesisBytes, &config); err != nil {
+		return nil, fmt.Errorf("couldn't unmarshal genesis: %w", err)
+	}
+	stakeAddrs := map[string]struct{}{}
+	for _, addr := range config.InitialStakedFunds {
+		stakeAddrs[addr] = struct{}{}
+	}
+	fundedAddrs := map[ids.ShortID]string{}
+	for _, allocation := range config.Allocations {
+		if _, ok := stakeAddrs[allocation.AVAXAddr]; ok {
+			continue
+		}
+		funded := allocation.InitialAmount > 0
+		for _, lockedAmount := range allocation.UnlockSchedule {
+			funded = funded || lockedAmount.Amount > 0
+		}
+		if !funded {
+			continue
+		}
+		_, _, addrBytes, err := address.Parse(allocation.AVAXAddr)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse genesis address %q: %w", allocation.AVAXAddr, err)
+		}
+		addr, err := ids.ToShortID(addrBytes)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse genesis address %q: %w", allocation.AVAXAddr, err)
+		}
+		fundedAddrs[addr] = allocation.AVAXAddr
+	}
+	return fundedAddrs, nil
+}
+
+// Returns a genesis JSON with the given X-Chain and P-Chain [allocations],
+// C-Chain hex address --> balance in wei [cChainAllocs], and [stakers], each of
+// them staking [validatorStake] from a random address.
+func newAvalancheGoGenesis(
+	networkID uint32,
+	allocations []genesis.UnparsedAllocation,
+	cChainAllocs map[string]*big.Int,
+	stakers []StakerSpec,
+) ([]byte, error) {
 
 	// Address that controls stake doesn't matter -- generate it randomly
 	genesisVdrStakeAddr, _ := address.Format(
@@ -132,7 +446,7 @@ func NewAvalancheGoGenesis(
 				InitialAmount: 0,
 				UnlockSchedule: []genesis.LockedAmount{ // Provides stake to validators
 					{
-						Amount: uint64(len(genesisVdrs)) * validatorStake,
+						Amount: uint64(len(stakers)) * validatorStake,
 					},
 				},
 			},
@@ -143,32 +457,13 @@ func NewAvalancheGoGenesis(
 		InitialStakeDurationOffset: 5_400,      // 90 minutes
 		Message:                    "hello world",
 	}
-
-	for _, xChainBal := range xChainBalances {
-		xChainAddr, _ := address.Format("X", constants.GetHRP(networkID), xChainBal.Addr[:])
-		config.Allocations = append(
-			config.Allocations,
-			genesis.UnparsedAllocation{
-				ETHAddr:       "0x0000000000000000000000000000000000000000",
-				AVAXAddr:      xChainAddr,
-				InitialAmount: xChainBal.Balance,
-				UnlockSchedule: []genesis.LockedAmount{
-					{
-						Amount:   validatorStake * uint64(len(genesisVdrs)), // Stake
-						Locktime: uint64(time.Now().Add(7 * 24 * time.Hour).Unix()),
-					},
-				},
-			},
-		)
-	}
+	config.Allocations = append(config.Allocations, allocations...)
 
 	// Set initial C-Chain balances.
-	cChainAllocs := map[string]interface{}{}
-	for _, cChainBal := range cChainBalances {
-		addrHex := fmt.Sprintf("0x%s", cChainBal.Addr.Hex())
-		balHex := fmt.Sprintf("0x%x", cChainBal.Balance)
-		cChainAllocs[addrHex] = map[string]interface{}{
-			"balance": balHex,
+	cChainAllocsConfig := map[string]interface{}{}
+	for addrHex, balance := range cChainAllocs {
+		cChainAllocsConfig[addrHex] = map[string]interface{}{
+			"balance": fmt.Sprintf("0x%x", balance),
 		}
 	}
 	// avoid modifying original cChainConfig
@@ -176,20 +471,28 @@ func NewAvalancheGoGenesis(
 	for k, v := range cChainConfig {
 		localCChainConfig[k] = v
 	}
-	localCChainConfig["alloc"] = cChainAllocs
+	localCChainConfig["alloc"] = cChainAllocsConfig
 	cChainConfigBytes, _ := json.Marshal(localCChainConfig)
 	config.CChainGenesis = string(cChainConfigBytes)
 
 	// Set initial validators.
-	// Give staking rewards to random address.
-	rewardAddr, _ := address.Format("X", constants.GetHRP(networkID), ids.GenerateTestShortID().Bytes())
-	for _, genesisVdr := range genesisVdrs {
+	// Give staking rewards to random address if not given.
+	randomRewardAddr, _ := address.Format("X", constants.GetHRP(networkID), ids.GenerateTestShortID().Bytes())
+	for _, staker := range stakers {
+		rewardAddr := randomRewardAddr
+		if staker.RewardAddr != ids.ShortEmpty {
+			var err error
+			rewardAddr, err = address.Format("X", constants.GetHRP(networkID), staker.RewardAddr.Bytes())
+			if err != nil {
+				return nil, err
+			}
+		}
 		config.InitialStakers = append(
 			config.InitialStakers,
 			genesis.UnparsedStaker{
-				NodeID:        genesisVdr,
+				NodeID:        staker.NodeID,
 				RewardAddress: rewardAddr,
-				DelegationFee: 10_000,
+				DelegationFee: staker.DelegationFee,
 			},
 		)
 	}
@@ -6,6 +6,11 @@ import (
 
 	"github.com/ava-labs/avalanche-network-runner/network"
 	"github.com/ava-labs/avalanche-network-runner/network/node"
+	"github.com/ava-labs/avalanchego/genesis"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/crypto"
+	"github.com/ava-labs/avalanchego/utils/formatting/address"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,3 +64,196 @@ func TestConfigMarshalJSON(t *testing.T) {
 	assert := assert.New(t)
 	assert.EqualValues(control, netcfg)
 }
+
+func TestConfigValidate(t *testing.T) {
+	assert := assert.New(t)
+	genesisBytes, err := network.GenerateGenesis(
+		1337,
+		[]string{genesis.EWOQKey.String()},
+		[]network.StakerSpec{{NodeID: ids.GenerateTestNodeID()}},
+	)
+	assert.NoError(err)
+	netcfg := network.Config{
+		Genesis: string(genesisBytes),
+		NodeConfigs: []node.Config{
+			{
+				Name:        "node1",
+				StakingKey:  "key1",
+				StakingCert: "cert1",
+				Flags: map[string]interface{}{
+					"http-port": 9650,
+				},
+			},
+			{
+				Name:        "node1",
+				StakingKey:  "key2",
+				StakingCert: "cert2",
+				Flags: map[string]interface{}{
+					"staking-port": float64(9650),
+				},
+			},
+		},
+	}
+	err = netcfg.Validate()
+	assert.Error(err)
+	// all the problems are reported
+	assert.Contains(err.Error(), "repeated node name \"node1\"")
+	assert.Contains(err.Error(), "port 9650 already used by node \"node1\"")
+	assert.Contains(err.Error(), "beacon nodes not given")
+
+	netcfg.NodeConfigs[0].IsBeacon = true
+	netcfg.NodeConfigs[1].Name = "node2"
+	netcfg.NodeConfigs[1].Flags["staking-port"] = float64(9651)
+	assert.NoError(netcfg.Validate())
+
+	// explicit node ports are also checked
+	netcfg.NodeConfigs[1].P2PPort = 9650
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "port 9650 already used by node \"node1\"")
+	netcfg.NodeConfigs[1].P2PPort = 0
+
+	// extra args must be flags
+	netcfg.NodeConfigs[1].ExtraArgs = []string{"--some-flag=1", "value"}
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "invalid extra arg \"value\"")
+	netcfg.NodeConfigs[1].ExtraArgs = []string{"--some-flag=1", "--some-bool-flag"}
+	assert.NoError(netcfg.Validate())
+
+	netcfg.NodeConfigs[1].CPULimit = -1
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "negative cpu limit")
+	netcfg.NodeConfigs[1].CPULimit = 0.5
+	netcfg.NodeConfigs[1].MemLimitMB = 512
+	assert.NoError(netcfg.Validate())
+
+	netcfg.NodeConfigs[1].APIHeaders = map[string]string{"": "value"}
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "empty API header name")
+	netcfg.NodeConfigs[1].APIHeaders = map[string]string{"Authorization": "Bearer token"}
+	assert.NoError(netcfg.Validate())
+
+	// staking key and cert are given together, and not shared
+	stakingKey, stakingCert := netcfg.NodeConfigs[1].StakingKey, netcfg.NodeConfigs[1].StakingCert
+	netcfg.NodeConfigs[1].StakingKey = ""
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "staking cert given without staking key")
+	netcfg.NodeConfigs[1].StakingCert = netcfg.NodeConfigs[0].StakingCert
+	netcfg.NodeConfigs[1].StakingKey = netcfg.NodeConfigs[0].StakingKey
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "node \"node2\" staking cert already used by node \"node1\"")
+	netcfg.NodeConfigs[1].StakingKey, netcfg.NodeConfigs[1].StakingCert = "", ""
+	assert.NoError(netcfg.Validate())
+	netcfg.NodeConfigs[1].StakingKey, netcfg.NodeConfigs[1].StakingCert = stakingKey, stakingCert
+
+	// chain aliases can't be given to the nodes on startup
+	netcfg.Aliases = map[ids.ID][]string{ids.GenerateTestID(): {"subnetevm"}}
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "chain aliases are not supported")
+	netcfg.Aliases = nil
+	assert.NoError(netcfg.Validate())
+
+	netcfg.MinPort = 20000
+	netcfg.MaxPort = 10000
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "min port 20000 is greater than max port 10000")
+	netcfg.MinPort, netcfg.MaxPort = 0, 0
+	assert.NoError(netcfg.Validate())
+
+	// the genesis is checked as the nodes would check it
+	var genesisMap map[string]interface{}
+	assert.NoError(json.Unmarshal(genesisBytes, &genesisMap))
+	genesisMap["initialStakers"] = []interface{}{}
+	invalidGenesis, err := json.Marshal(genesisMap)
+	assert.NoError(err)
+	netcfg.Genesis = string(invalidGenesis)
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "invalid genesis")
+	netcfg.Genesis = "{\"networkID\": 1337}"
+	err = netcfg.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "initial supply")
+}
+
+func TestGenerateGenesis(t *testing.T) {
+	assert := assert.New(t)
+	networkID := uint32(1337)
+	fundedKey := genesis.EWOQKey.String()
+	stakers := []network.StakerSpec{
+		{NodeID: ids.GenerateTestNodeID(), DelegationFee: 20_000},
+		{NodeID: ids.GenerateTestNodeID(), RewardAddr: genesis.EWOQKey.PublicKey().Address(), DelegationFee: 20_000},
+	}
+	genesisBytes, err := network.GenerateGenesis(networkID, []string{fundedKey}, stakers)
+	assert.NoError(err)
+
+	var unparsedConfig genesis.UnparsedConfig
+	assert.NoError(json.Unmarshal(genesisBytes, &unparsedConfig))
+	config, err := unparsedConfig.Parse()
+	assert.NoError(err)
+	// avalanchego accepts it
+	_, _, err = genesis.FromConfig(&config)
+	assert.NoError(err)
+	assert.Len(config.InitialStakers, len(stakers))
+	for i, staker := range stakers {
+		assert.Equal(staker.NodeID, config.InitialStakers[i].NodeID)
+		assert.Equal(staker.DelegationFee, config.InitialStakers[i].DelegationFee)
+	}
+	assert.Equal(genesis.EWOQKey.PublicKey().Address(), config.InitialStakers[1].RewardAddress)
+	// the funded key has X-Chain, unlocked P-Chain and C-Chain funds
+	var fundedAllocation *genesis.Allocation
+	for i, allocation := range config.Allocations {
+		if allocation.AVAXAddr == genesis.EWOQKey.PublicKey().Address() {
+			fundedAllocation = &config.Allocations[i]
+		}
+	}
+	assert.NotNil(fundedAllocation)
+	assert.NotZero(fundedAllocation.InitialAmount)
+	assert.Len(fundedAllocation.UnlockSchedule, 1)
+	assert.NotZero(fundedAllocation.UnlockSchedule[0].Amount)
+	assert.Zero(fundedAllocation.UnlockSchedule[0].Locktime)
+	assert.Contains(config.CChainGenesis, "0x8db97C7cEcE249c2b98bDC0226Cc4C2A57BF52FC")
+
+	_, err = network.GenerateGenesis(networkID, []string{"PrivateKey-invalid"}, stakers)
+	assert.Error(err)
+	_, err = network.GenerateGenesis(networkID, nil, stakers)
+	assert.Error(err)
+	_, err = network.GenerateGenesis(networkID, []string{fundedKey}, nil)
+	assert.Error(err)
+	_, err = network.GenerateGenesis(constants.LocalID, []string{fundedKey}, stakers)
+	assert.Error(err)
+}
+
+func TestFundedAddressesAndKeys(t *testing.T) {
+	assert := assert.New(t)
+	networkID := uint32(1337)
+	fundedKey, err := network.ParsePrivateKey(genesis.EWOQKey.String())
+	assert.NoError(err)
+	stakers := []network.StakerSpec{{NodeID: ids.GenerateTestNodeID()}}
+	genesisBytes, err := network.GenerateGenesis(networkID, []string{fundedKey.String()}, stakers)
+	assert.NoError(err)
+
+	// the stake address of the generated genesis is not funded
+	addrs, err := network.FundedAddresses(genesisBytes)
+	assert.NoError(err)
+	fundedAddr, err := address.Format("X", constants.GetHRP(networkID), fundedKey.PublicKey().Address().Bytes())
+	assert.NoError(err)
+	assert.Equal([]string{fundedAddr}, addrs)
+
+	factory := crypto.FactorySECP256K1R{}
+	otherKey, err := factory.NewPrivateKey()
+	assert.NoError(err)
+	keys, err := network.FundedKeys(genesisBytes, []*crypto.PrivateKeySECP256K1R{otherKey.(*crypto.PrivateKeySECP256K1R), fundedKey})
+	assert.NoError(err)
+	assert.Equal([]string{fundedKey.String()}, keys)
+
+	_, err = network.FundedAddresses([]byte("not a genesis"))
+	assert.Error(err)
+}
@@ -2,21 +2,215 @@ package network
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/ava-labs/avalanche-network-runner/network/node"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/logging"
 )
 
 var (
-	ErrUndefined    = errors.New("undefined network")
-	ErrStopped      = errors.New("network stopped")
-	ErrNodeNotFound = errors.New("node not found in network")
+	ErrUndefined      = errors.New("undefined network")
+	ErrStopped        = errors.New("network stopped")
+	ErrNodeNotFound   = errors.New("node not found in network")
+	ErrSubnetNotFound = errors.New("subnet not found")
+	ErrNotValidator   = errors.New("node is not a current validator")
+
+	// returned when loading a config or snapshot into a network already loaded
+	ErrAlreadyInitialized = errors.New("network already initialized")
 )
 
 type BlockchainSpec struct {
 	VmName   string
 	Genesis  []byte
 	SubnetId *string
+	// Optional VM specific validation of [Genesis], run before the blockchain is created.
+	// If nil, [Genesis] is only checked to be non empty valid JSON.
+	GenesisValidator func([]byte) error
+	// Optional chain config of the blockchain, written to the chain config dir
+	// of each node once the blockchain is created. The nodes are then restarted
+	// so that the blockchain runs with it.
+	ChainConfig []byte
+	// True if the VM can't run without a chain config, so that
+	// creation fails early if [ChainConfig] is not given.
+	ChainConfigRequired bool
+	// Optional upgrade config of the blockchain (e.g. activation times of the VM
+	// network upgrades), JSON encoded, written to the chain config dir of each
+	// node, as upgrade.json, once the blockchain is created. The nodes are then
+	// restarted, along with [ChainConfig], so that the blockchain runs with it.
+	UpgradeConfig []byte
+	// IDs of the feature extensions used by the VM. May be empty.
+	FxIDs []string
+	// Optional text/template of the genesis, used instead of [Genesis], which must
+	// then be empty. It is rendered with the GenesisTemplateValues of the network
+	// once the subnet of the blockchain is known, before the blockchain is created,
+	// and the rendered genesis is validated as [Genesis] would be.
+	GenesisTemplate string
+}
+
+// GenesisTemplateValues are the values a blockchain genesis template is rendered with
+// (e.g. {{.NetworkID}}).
+type GenesisTemplateValues struct {
+	NetworkID uint32
+	// X-Chain and P-Chain addresses funded by the network genesis, sorted
+	FundedAddresses []string
+	// ID of the subnet of the blockchain
+	SubnetID string
+}
+
+// BlockchainInfo describes a blockchain of the network, as given by the P-Chain
+type BlockchainInfo struct {
+	Name     string
+	ID       ids.ID
+	SubnetID ids.ID
+	VMID     ids.ID
+}
+
+// SubnetInfo describes a subnet of the network, as given by the P-Chain
+type SubnetInfo struct {
+	ID ids.ID
+	// P-Chain addresses of the keys controlling the subnet (e.g. P-custom1...)
+	ControlKeys []string
+	// Number of control keys that must sign the subnet txs
+	Threshold uint32
+	// Blockchains of the subnet, sorted by name
+	Blockchains []BlockchainInfo
+}
+
+// ValidatorInfo describes a current validator of the primary network, as given by the P-Chain
+type ValidatorInfo struct {
+	NodeID ids.NodeID
+	// Name of the node of the network with [NodeID], or empty if there is none
+	NodeName  string
+	StartTime time.Time
+	EndTime   time.Time
+	// nAVAX staked by the validator, not including its delegations
+	StakeAmount uint64
+	// True if the queried node is connected to the validator
+	Connected bool
+}
+
+// SubnetValidatorDetail describes a current validator of a subnet, as given by the P-Chain
+type SubnetValidatorDetail struct {
+	NodeID ids.NodeID
+	// Name of the node of the network with [NodeID], or empty if there is none
+	NodeName  string
+	Weight    uint64
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// ProfileKind is a kind of performance profile that nodes write through their admin API
+type ProfileKind string
+
+const (
+	// heap allocations
+	ProfileHeap ProfileKind = "heap"
+	// CPU usage, sampled for a given duration
+	ProfileCPU ProfileKind = "cpu"
+	// holders of contended mutexes
+	ProfileLock ProfileKind = "lock"
+)
+
+// NodeRoleKind is a kind of role a node can have in the network
+type NodeRoleKind string
+
+const (
+	// nodes the other nodes bootstrap from
+	NodeRoleBeacon NodeRoleKind = "beacon"
+	// current validators of the primary network
+	NodeRoleValidator NodeRoleKind = "validator"
+	// current validators of a given subnet
+	NodeRoleSubnetValidator NodeRoleKind = "subnet-validator"
+)
+
+// NodeRole selects the nodes having a role in the network
+type NodeRole struct {
+	Kind NodeRoleKind
+	// Subnet validated by the nodes, only used by NodeRoleSubnetValidator
+	SubnetID ids.ID
+}
+
+// BeaconRole selects the beacons of the network
+func BeaconRole() NodeRole {
+	return NodeRole{Kind: NodeRoleBeacon}
+}
+
+// ValidatorRole selects the current validators of the primary network
+func ValidatorRole() NodeRole {
+	return NodeRole{Kind: NodeRoleValidator}
+}
+
+// SubnetValidatorRole selects the current validators of [subnetID]
+func SubnetValidatorRole(subnetID ids.ID) NodeRole {
+	return NodeRole{Kind: NodeRoleSubnetValidator, SubnetID: subnetID}
+}
+
+// Endpoint locates the API of a blockchain on a node
+type Endpoint struct {
+	// Name of the node
+	NodeName string
+	NodeID   ids.NodeID
+	// ID of the blockchain
+	BlockchainID ids.ID
+	// Scheme, host and port of the node API (e.g. http://127.0.0.1:9650)
+	BaseURL string
+	// Path of the blockchain API on the node, including the VM
+	// specific RPC path if one was given (e.g. /ext/bc/<id>/rpc)
+	Path string
+}
+
+// URL returns the full URL of the endpoint
+func (e Endpoint) URL() string {
+	return e.BaseURL + e.Path
+}
+
+// VMBlockQuery gives how to get the height of the last accepted block of a custom VM,
+// with a JSON-RPC request to the blockchain endpoint of a node
+type VMBlockQuery struct {
+	// JSON-RPC method returning the last accepted block (e.g. eth_blockNumber)
+	Method string
+	// May be nil.
+	Params interface{}
+	// Returns the height in the result of the method
+	ParseHeight func(result json.RawMessage) (uint64, error)
+}
+
+// PeerInfo describes a peer a node is connected to, as given by its info API
+type PeerInfo struct {
+	NodeID ids.NodeID
+	// Name of the network node with [NodeID], or empty if it's not a node of the network
+	NodeName       string
+	IP             string
+	Version        string
+	LastSent       time.Time
+	LastReceived   time.Time
+	ObservedUptime uint8
+	TrackedSubnets []ids.ID
+	// Chains on which the peer is benched for being unresponsive
+	Benched []ids.ID
+}
+
+// NodeExit describes the unexpected exit of a node process
+type NodeExit struct {
+	NodeName string
+	// -1 if the process was terminated by a signal
+	ExitCode int
+}
+
+// BuildInfo describes the versions of the software making up a network,
+// to be included in bug reports
+type BuildInfo struct {
+	// Version of the runner module, or "(devel)" if it is built from a local checkout
+	RunnerVersion string `json:"runnerVersion"`
+	// Version of the avalanchego module the runner is built against
+	AvalancheGoLibVersion string `json:"avalancheGoLibVersion"`
+	// Version and git commit of the avalanchego binary run by the nodes, as reported
+	// by the Info API of some node. Empty if no node could be queried.
+	AvalancheGoVersion string `json:"avalancheGoVersion"`
+	AvalancheGoCommit  string `json:"avalancheGoCommit"`
 }
 
 // Network is an abstraction of an Avalanche network
@@ -24,39 +218,299 @@ type Network interface {
 	// Returns nil if all the nodes in the network are healthy.
 	// A stopped network is considered unhealthy.
 	// Timeout is given by the context parameter.
-	Healthy(context.Context) error
+	// If a minimum healthy duration is given in the options, the nodes must then
+	// stay healthy for that long, waiting for them again whenever one is not.
+	Healthy(context.Context, ...HealthOption) error
 	// Stop all the nodes.
+	// Node databases and logs are not removed, and remain available
+	// under GetRootDir() for post-mortem analysis.
 	// Returns ErrStopped if Stop() was previously called.
+	// Safe to call concurrently: only the first call stops the nodes, and the
+	// others wait for it to finish and return ErrStopped.
 	Stop(context.Context) error
+	// Returns the root directory under which node databases, logs
+	// and config files are written.
+	// Can also be called after Stop().
+	GetRootDir() string
+	// Returns the genesis the network was started with, either
+	// from its config or from the snapshot it was loaded from.
+	// Can also be called after Stop().
+	// Returns ErrUndefined if the network was not started.
+	GetGenesis() ([]byte, error)
+	// Returns the X-Chain and P-Chain addresses funded by the genesis,
+	// formatted as in it (e.g. X-custom1...), sorted.
+	// Can also be called after Stop().
+	// Returns ErrUndefined if the network was not started.
+	GetFundedAddresses() ([]string, error)
+	// Returns the private keys, "PrivateKey-" prefixed and CB58 encoded, known to
+	// the network and funded by the genesis, e.g. the pre-funded test key of the
+	// default genesis, usable as the funded key of the setup options.
+	// Keys of other funded addresses can't be derived from the genesis.
+	// Can also be called after Stop().
+	// Returns ErrUndefined if the network was not started.
+	GetFundedKeys() ([]string, error)
 	// Start a new node with the given config.
 	// Returns ErrStopped if Stop() was previously called.
 	AddNode(node.Config) (node.Node, error)
+	// Returns a channel receiving the exits of the node processes that were not
+	// stopped by the network, e.g. crashes, as soon as they happen.
+	// Exits are dropped if the channel buffer is full. The channel is never closed.
+	NodeExited() <-chan NodeExit
+	// Returns a JSON description of the network nodes: name, node ID, URL, ports,
+	// whether it is a beacon, and the subnets and blockchains each node validates.
+	// Returns ErrStopped if Stop() was previously called.
+	ExportTopology(context.Context) ([]byte, error)
+	// Returns the peers the node with the given name is connected to, sorted by node ID.
+	// Returns ErrStopped if Stop() was previously called.
+	GetPeers(ctx context.Context, nodeName string) ([]PeerInfo, error)
+	// Returns the IDs of the peers each node, but the nodes stopped with StopNode,
+	// is connected to, sorted.
+	// Node name --> IDs, which may include nodes that are not part of the network.
+	// Returns ErrStopped if Stop() was previously called.
+	GetConnectivityMatrix(context.Context) (map[string][]ids.NodeID, error)
+	// Add nodes until the network has the given number of nodes, and wait for
+	// all of them to be healthy. The new nodes bootstrap from the network's beacons.
+	// If any node fails to start or become healthy, the added nodes are removed.
+	// Returns all the nodes in the network.
+	// Returns ErrStopped if Stop() was previously called.
+	ScaleTo(context.Context, uint32) (map[string]node.Node, error)
 	// Stop the node with this name.
+	// If it is the last beacon of the network, another healthy node becomes a
+	// beacon, so that the nodes added later can bootstrap. If there is none,
+	// the node is not removed and an error is returned.
 	// Returns ErrStopped if Stop() was previously called.
 	RemoveNode(ctx context.Context, name string) error
+	// Gracefully withdraw the node with this name, then remove it as RemoveNode does.
+	// The validators of the primary network and of the subnets can't withdraw before the
+	// end of their validation periods, so draining a node waits, for at most the given
+	// timeout, until it is no longer a current or pending validator of the primary
+	// network nor of any subnet. Then the node leaves the validator sets gracefully,
+	// without its abrupt stop lowering the uptimes and the connected stake.
+	// If the timeout expires first, the node is not removed and an error naming the
+	// subnets it still validates is returned.
+	// Returns ErrStopped if Stop() was previously called.
+	DrainNode(ctx context.Context, name string, timeout time.Duration) error
+	// Stop the node with this name, keeping it in the network, with its config
+	// and data dir, so that it can be started again with StartNode.
+	// While stopped, the node is reported as unhealthy.
+	// Returns ErrStopped if Stop() was previously called.
+	StopNode(ctx context.Context, name string) error
+	// Start again the node with this name, stopped with StopNode, with its
+	// original config, ports and data dir. Doesn't wait for it to be healthy.
+	// Returns ErrStopped if Stop() was previously called.
+	StartNode(ctx context.Context, name string) error
+	// Add the given latency and packet loss percentage to each packet sent between the
+	// two nodes with these names, in both directions, replacing any previous ones.
+	// A zero latency and loss remove them. The conditions apply to the connections the
+	// nodes have when called, so they are lost if the nodes reconnect.
+	// Implemented with tc netem, so it needs Linux and the CAP_NET_ADMIN capability.
+	// Returns ErrStopped if Stop() was previously called.
+	SetNetworkConditions(ctx context.Context, nodeA, nodeB string, latency time.Duration, lossPct float64) error
 	// Return the node with this name.
 	// Returns ErrStopped if Stop() was previously called.
 	GetNode(name string) (node.Node, error)
+	// Return the node with this node ID.
+	// Returns ErrNodeNotFound if no node of the network has it.
+	// Returns ErrStopped if Stop() was previously called.
+	GetNodeByID(nodeID ids.NodeID) (node.Node, error)
+	// Returns the JSON config the node with this name was launched with, as written to
+	// its dir: the entries of its config file, overridden by all the flags passed to
+	// its process, including the ones set by the network runner.
+	// Returns ErrStopped if Stop() was previously called.
+	GetNodeConfigFile(name string) ([]byte, error)
 	// Return all the nodes in this network.
 	// Node name --> Node.
 	// Returns ErrStopped if Stop() was previously called.
 	GetAllNodes() (map[string]node.Node, error)
-	// Returns the names of all nodes in this network.
+	// Runs the given function on each node of the network, for at most
+	// [concurrency] nodes at a time.
+	// All the nodes are visited, and the errors of all of them are reported.
+	// Returns ErrStopped if Stop() was previously called.
+	ForEachNode(ctx context.Context, concurrency int, fn func(node.Node) error) error
+	// Returns the names of all nodes in this network, in natural order, comparing
+	// runs of digits by their numeric value, so that "node-2" is before "node-10".
 	// Returns ErrStopped if Stop() was previously called.
 	GetNodeNames() ([]string, error)
+	// Returns the names of the nodes of this network having the given role, in
+	// the order of GetNodeNames. The validator roles are given by the P-Chain
+	// of some node, and only cover current validators, not pending ones.
+	// Returns ErrStopped if Stop() was previously called.
+	GetNodeNamesByRole(ctx context.Context, role NodeRole) ([]string, error)
+	// Returns an error describing the missing and extra nodes, if
+	// the IDs of the network nodes are not the given ones.
+	// Returns ErrStopped if Stop() was previously called.
+	VerifyNodes(context.Context, []ids.NodeID) error
+	// Compares the clock of every node, as given by its info API responses,
+	// against the local one, and errors if any of them differs by more than
+	// the given maximum skew, as validator start times are checked against
+	// the node clocks. Clocks are compared with a resolution of one second.
+	// Returns ErrStopped if Stop() was previously called.
+	CheckClockSkew(ctx context.Context, maxSkew time.Duration) error
+	// Sets the log and display levels of all the loggers of the given node, through its admin API,
+	// which the node must have been started with.
+	// Returns ErrStopped if Stop() was previously called.
+	SetLogLevel(ctx context.Context, nodeName string, level logging.Level) error
+	// Returns the pprof encoded profile of the given kind of the given node, written
+	// through its admin API, which the node must have been started with.
+	// A ProfileCPU profile samples for [cpuDuration], which is ignored by the other kinds.
+	// Returns ErrStopped if Stop() was previously called.
+	GetProfile(ctx context.Context, nodeName string, kind ProfileKind, cpuDuration time.Duration) ([]byte, error)
+	// Scrape the metrics endpoint of every node once, but the nodes stopped with StopNode.
+	// Node name --> raw Prometheus exposition text.
+	// Returns ErrStopped if Stop() was previously called.
+	CollectMetrics(context.Context) (map[string][]byte, error)
 	// Save network snapshot
-	// Network is stopped in order to do a safe preservation
+	// Network is stopped in order to do a safe preservation, and is left stopped
+	// if saving fails or the context is cancelled, in which case no partial snapshot is left
+	// Node dbs are saved uncompressed, unless a compression is given in the options
 	// Returns the full local path to the snapshot dir
-	SaveSnapshot(context.Context, string) (string, error)
+	SaveSnapshot(context.Context, string, ...SnapshotOption) (string, error)
 	// Remove network snapshot
+	// Fails if the running network was loaded from it
 	RemoveSnapshot(string) error
+	// Remove network snapshot, even if the running network was loaded from it
+	RemoveSnapshotForce(string) error
 	// Get name of available snapshots
 	GetSnapshotNames() ([]string, error)
 	// Restart a given node using the same config, optionally changing binary path,
 	// whitelisted subnets, a map of chain configs, and a map of upgrade configs
 	RestartNode(context.Context, string, string, string, map[string]string, map[string]string) error
-	// Create the specified blockchains
-	CreateBlockchains(context.Context, []BlockchainSpec) error
-	// Create the given numbers of subnets
-	CreateSubnets(context.Context, uint32) error
+	// Restart the node with this name with the given binary, using its build dir,
+	// and the same config, identity, ports and database, and wait for it to be healthy.
+	// Fails before stopping the node if the binary is not an existing executable file.
+	// Returns ErrStopped if Stop() was previously called.
+	UpgradeNode(ctx context.Context, name string, newBinaryPath string) error
+	// Restart the node with this name with an empty database, keeping its config,
+	// identity and ports, and wait for it to be healthy, that is, re-bootstrapped
+	// from the beacons of the network.
+	// Fails before stopping the node if no other beacon is healthy.
+	// Returns ErrStopped if Stop() was previously called.
+	ResetNodeState(ctx context.Context, name string) error
+	// Restart the nodes one at a time, waiting for each one to become healthy
+	// before restarting the next one. If the given config is not nil, its binary
+	// path, flags, chain config files and upgrade config files are applied to all nodes.
+	// The nodes stopped with StopNode stay stopped, and get the config when started again.
+	// Returns ErrStopped if Stop() was previously called.
+	RollingRestart(context.Context, *node.Config) error
+	// Stop all the nodes, then start them again with the same configs, identities,
+	// ports and databases, beacons first, and wait for all of them to be healthy.
+	// The nodes stopped with StopNode stay stopped.
+	// Returns ErrStopped if Stop() was previously called.
+	Restart(context.Context) error
+	// Returns the ID of the blockchain with the given name.
+	// Fails if there are no blockchains, or more than one, with that name.
+	// Returns ErrStopped if Stop() was previously called.
+	GetBlockchainID(context.Context, string) (ids.ID, error)
+	// Returns all the blockchains of the network, other than the P-Chain.
+	// Returns ErrStopped if Stop() was previously called.
+	GetBlockchains(context.Context) ([]BlockchainInfo, error)
+	// Returns the control keys, threshold and blockchains of the given subnet.
+	// Returns ErrSubnetNotFound if the P-Chain doesn't know the subnet.
+	// Returns ErrStopped if Stop() was previously called.
+	GetSubnetInfo(ctx context.Context, subnetID ids.ID) (SubnetInfo, error)
+	// Returns the current validators of the primary network, as given by the P-Chain
+	// of some node, sorted by node ID.
+	// Returns ErrStopped if Stop() was previously called.
+	GetPrimaryValidators(ctx context.Context) ([]ValidatorInfo, error)
+	// Returns the current validators of the given subnet, with their weights and
+	// validation periods, as given by the P-Chain of some node, sorted by node ID.
+	// Returns ErrStopped if Stop() was previously called.
+	GetSubnetValidatorDetails(ctx context.Context, subnetID ids.ID) ([]SubnetValidatorDetail, error)
+	// Returns the IDs of the nodes added as validators of the given subnet
+	// whose start time has not been reached yet, so they are not validating it.
+	// Returns ErrStopped if Stop() was previously called.
+	GetPendingSubnetValidators(ctx context.Context, subnetID ids.ID) ([]ids.NodeID, error)
+	// Returns the uptime of the given node, as a percentage, as reported by its Info API:
+	// the average of the uptimes observed by the other validators, weighted by stake.
+	// Fails if the node is not a validator.
+	// Returns ErrStopped if Stop() was previously called.
+	GetUptime(ctx context.Context, nodeName string) (float64, error)
+	// Returns the uptime of the given validator of the given subnet, as a percentage,
+	// as observed by the P-Chain since the start of its primary network validation.
+	// The P-Chain only measures primary network uptimes, so the uptime of a subnet
+	// validator is the one of its primary network validation.
+	// Returns ErrNotValidator if the node is not a current validator of the subnet.
+	// Returns ErrStopped if Stop() was previously called.
+	GetSubnetValidatorUptime(ctx context.Context, subnetID ids.ID, nodeID ids.NodeID) (float64, error)
+	// Restart the node with the given name so it also tracks the given subnet,
+	// keeping its identity, ports and database.
+	// Does nothing if the node already tracks the subnet.
+	// Returns ErrStopped if Stop() was previously called.
+	TrackSubnet(ctx context.Context, nodeName string, subnetID ids.ID) error
+	// Returns the current height of the given blockchain on each node,
+	// but the nodes stopped with StopNode.
+	// The blockchain must be the P-Chain, or run an EVM, i.e. be the C-Chain or
+	// a subnet-evm blockchain: the height of other VMs is given by AwaitVMBlock.
+	// Node name --> height.
+	// Returns ErrStopped if Stop() was previously called.
+	GetChainHeight(context.Context, ids.ID) (map[string]uint64, error)
+	// Returns the current P-Chain height on each node, as GetChainHeight does,
+	// so that the setup of subnets can wait for the nodes to converge, as
+	// the P-Chain txs are only confirmed by the nodes that reached them.
+	// Node name --> height.
+	// Returns ErrStopped if Stop() was previously called.
+	GetPChainHeight(ctx context.Context) (map[string]uint64, error)
+	// Waits until all nodes, but the nodes stopped with StopNode, are at least at the
+	// given height of the given blockchain, which must be the P-Chain or run an EVM,
+	// as for GetChainHeight.
+	// Timeout is given by the context parameter, in which case the lagging nodes are reported.
+	// Returns ErrStopped if Stop() was previously called.
+	AwaitChainHeight(context.Context, ids.ID, uint64) error
+	// Waits until the custom VM of the given blockchain has accepted at least the block
+	// at the given height on all nodes, but the nodes stopped with StopNode, as given
+	// by the query of its RPC at the given path of the blockchain endpoint (e.g. /rpc).
+	// Timeout is given by the context parameter, in which case the lagging nodes are reported.
+	// Returns ErrStopped if Stop() was previously called.
+	AwaitVMBlock(ctx context.Context, blockchainID ids.ID, height uint64, rpcPath string, query VMBlockQuery) error
+	// Starts an HTTP proxy on the given address (127.0.0.1 on a random port if empty),
+	// forwarding each request to the endpoint of the given blockchain of the nodes,
+	// in turn, e.g. <proxy URL>/rpc to /ext/bc/<blockchain ID>/rpc.
+	// Nodes failing their periodic health check are skipped.
+	// Returns the proxy URL, and a function stopping the proxy, which is also
+	// stopped when the network is.
+	// Returns ErrStopped if Stop() was previously called.
+	StartProxy(ctx context.Context, blockchainID ids.ID, listenAddr string) (string, func(), error)
+	// Create the specified blockchains.
+	// Returns the endpoints of the created blockchains on each node,
+	// sorted by blockchain, in the order of the specs, and then by node name.
+	CreateBlockchains(context.Context, []BlockchainSpec, ...SetupOption) ([]Endpoint, error)
+	// Create the given numbers of subnets, with all the nodes as validators,
+	// waiting for them to be validating.
+	// Returns the IDs of the created subnets, so that their blockchains can
+	// be deployed later with DeployBlockchain.
+	CreateSubnets(context.Context, uint32, ...SetupOption) ([]ids.ID, error)
+	// Create the given blockchain on the given existing subnet, as CreateBlockchains does
+	// for a spec with that subnet ID, e.g. once the VM plugin is available.
+	// Returns the endpoints of the created blockchain on each node, sorted by node name.
+	// Returns ErrStopped if Stop() was previously called.
+	DeployBlockchain(ctx context.Context, subnetID ids.ID, chainSpec BlockchainSpec, opts ...SetupOption) ([]Endpoint, error)
+	// Start a new node with the given config, tracking the given existing subnet,
+	// and add it as a validator of the primary network and of the subnet, waiting
+	// for it to be validating the subnet.
+	// The txs are signed by the given private key, "PrivateKey-" prefixed and CB58
+	// encoded, or by the key given in the options if empty.
+	// Other nodes not validating the subnet yet are also added as its validators.
+	// If the validation fails, the node is left running.
+	// Returns ErrStopped if Stop() was previously called.
+	AddSubnetValidatorNode(
+		ctx context.Context,
+		nodeConfig node.Config,
+		subnetID ids.ID,
+		privateKey string,
+		opts ...SetupOption,
+	) (node.Node, error)
+	// Returns the total fee, in nAVAX, of the P-Chain txs creating the given blockchain
+	// with [validatorCount] subnet validators: the creation of its subnet, unless the
+	// spec has a subnet ID, the subnet validator txs and the blockchain creation.
+	// The fees are the current ones reported by the Info API of a node, so the result
+	// can be used to fund the key issuing the txs. Primary network validators and
+	// their stakes are not included.
+	// Returns ErrStopped if Stop() was previously called.
+	EstimateSetupCost(ctx context.Context, chainSpec BlockchainSpec, validatorCount uint32) (uint64, error)
+	// Returns the versions of the runner, of the avalanchego module it is built
+	// against and of the avalanchego binary run by the nodes.
+	// Nodes upgraded with UpgradeNode may run a different avalanchego version than
+	// the reported one. If Stop() was previously called, no avalanchego binary
+	// version is reported.
+	GetBuildInfo() BuildInfo
 }
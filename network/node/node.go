@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ava-labs/avalanche-network-runner/api"
 	"github.com/ava-labs/avalanche-network-runner/network/node/status"
@@ -27,8 +30,18 @@ type Node interface {
 	GetURL() string
 	// Return this node's P2P (staking) port.
 	GetP2PPort() uint16
+	// Return this node's staking port, the same as GetP2PPort.
+	GetStakingPort() uint16
+	// Return the address other nodes connect to this node at, as IP:port
+	// (e.g. 127.0.0.1:9651), e.g. to give it as a bootstrap IP to external nodes.
+	GetStakingAddress() string
 	// Return this node's HTTP API port.
 	GetAPIPort() uint16
+	// Return the timeout of each request made to this node
+	// through its API client.
+	GetAPITimeout() time.Duration
+	// Return the URL of this node's Prometheus metrics endpoint.
+	GetMetricsURL() string
 	// Starts a new test peer, connects it to the given node, and returns the peer.
 	// [handler] defines how the test peer handles messages it receives.
 	// The test peer can be used to send messages to the node it's attached to.
@@ -49,10 +62,13 @@ type Node interface {
 	GetBuildDir() string
 	// Return this node's config file contents
 	GetConfigFile() string
-	// Return this node's config
+	// Return the config this node was started with.
+	// Sensitive values, like the staking key, are redacted.
 	GetConfig() Config
 	// Return this node's flag value
 	GetFlag(string) (string, error)
+	// Return the subnets this node is configured to track
+	GetTrackedSubnets(context.Context) ([]ids.ID, error)
 }
 
 // Config encapsulates an avalanchego configuration
@@ -64,9 +80,11 @@ type Config struct {
 	// True if other nodes should use this node
 	// as a bootstrap beacon.
 	IsBeacon bool `json:"isBeacon"`
-	// Must not be nil.
-	StakingKey string `json:"stakingKey"`
-	// Must not be nil.
+	// PEM encoded staking TLS key and cert of the node, which determine its node ID.
+	// They must be both given, so the node ID is the same across runs,
+	// or both empty, in which case a new key and cert are generated when the
+	// node is added.
+	StakingKey  string `json:"stakingKey"`
 	StakingCert string `json:"stakingCert"`
 	// May be nil.
 	ConfigFile string `json:"configFile"`
@@ -74,6 +92,8 @@ type Config struct {
 	ChainConfigFiles map[string]string `json:"chainConfigFiles"`
 	// May be nil.
 	UpgradeConfigFiles map[string]string `json:"upgradeConfigFiles"`
+	// Subnet ID --> subnet config. May be nil.
+	SubnetConfigFiles map[string]string `json:"subnetConfigFiles"`
 	// Flags can hold additional flags for the node.
 	// It can be empty.
 	// The precedence of flags handling is:
@@ -87,20 +107,89 @@ type Config struct {
 	RedirectStdout bool `json:"redirectStdout"`
 	// If non-nil, direct this node's Stderr to os.Stderr
 	RedirectStderr bool `json:"redirectStderr"`
+	// API (HTTP) port of the node.
+	// If 0, it is taken from the flags or the config file, or otherwise
+	// allocated from the network port range.
+	APIPort uint16 `json:"apiPort"`
+	// P2P (staking) port of the node.
+	// If 0, it is taken from the flags or the config file, or otherwise
+	// allocated from the network port range.
+	P2PPort uint16 `json:"p2pPort"`
+	// Timeout of each request made to the node API through its API client,
+	// independent of the time budget of the calling operation.
+	// If 0, a default of 1 minute is used.
+	APITimeout time.Duration `json:"apiTimeout"`
+	// Additional command line arguments for the node, in the form --name=value,
+	// or --name for boolean flags. They are appended after all the other flags,
+	// so they take precedence over the ones given in [Flags] and the config file.
+	// Arguments setting a flag managed by the network runner (e.g. db dir, ports,
+	// bootstrap nodes, staking files) are rejected when the node is started.
+	ExtraArgs []string `json:"extraArgs"`
+	// Maximum CPU usage of the node process, in number of cores (e.g. 0.5).
+	// If 0, CPU usage is not limited.
+	// Resource limits are applied with cgroups v2 on Linux, and ignored on other systems.
+	CPULimit float64 `json:"cpuLimit"`
+	// Maximum memory usage of the node process, in MB.
+	// If 0, memory usage is not limited.
+	MemLimitMB uint64 `json:"memLimitMB"`
+	// HTTP headers added to every request made to the node API through its
+	// API client, or by the network itself, as when issuing the setup txs
+	// (e.g. Authorization: Bearer <token>), for nodes behind an
+	// authenticating proxy. Headers given on each call take precedence.
+	// They are not sent by the websocket connection of the C-Chain eth client.
+	// Their values are redacted by GetConfig.
+	// May be nil.
+	APIHeaders map[string]string `json:"apiHeaders"`
+	// Transport the requests made to the node API through its API client, or
+	// by the network itself, are sent through, e.g. to record and replay them in tests.
+	// It doesn't apply to the websocket connection of the C-Chain eth client.
+	// It is not saved in snapshots nor config files.
+	// May be nil.
+	APITransport http.RoundTripper `json:"-"`
 }
 
 // Validate returns an error if this config is invalid
 func (c *Config) Validate(expectedNetworkID uint32) error {
 	switch {
-	case c.StakingKey == "":
-		return errors.New("staking key not given")
-	case c.StakingCert == "":
-		return errors.New("staking cert not given")
+	case c.StakingKey == "" && c.StakingCert != "":
+		return errors.New("staking cert given without staking key")
+	case c.StakingCert == "" && c.StakingKey != "":
+		return errors.New("staking key given without staking cert")
+	case c.CPULimit < 0:
+		return fmt.Errorf("negative cpu limit %v", c.CPULimit)
 	default:
+		for k := range c.APIHeaders {
+			if strings.TrimSpace(k) == "" {
+				return errors.New("empty API header name")
+			}
+		}
+		if err := ValidateExtraArgs(c.ExtraArgs); err != nil {
+			return err
+		}
 		return validateConfigFile([]byte(c.ConfigFile), expectedNetworkID)
 	}
 }
 
+// ValidateExtraArgs returns an error if some argument of [extraArgs]
+// is not of the form --name=value or --name
+func ValidateExtraArgs(extraArgs []string) error {
+	for _, arg := range extraArgs {
+		if !strings.HasPrefix(arg, "--") || len(ExtraArgName(arg)) == 0 {
+			return fmt.Errorf("invalid extra arg %q, expected --name=value or --name", arg)
+		}
+	}
+	return nil
+}
+
+// ExtraArgName returns the name of the flag set by command line argument [arg]
+func ExtraArgName(arg string) string {
+	name := strings.TrimLeft(arg, "-")
+	if i := strings.Index(name, "="); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
 // Returns an error if config file [configFile] is invalid.
 // If len([configFile]) == 0, returns nil.
 func validateConfigFile(configFile []byte, expectedNetworkID uint32) error {
@@ -0,0 +1,510 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/avalanchego/vms/platformvm"
+)
+
+const (
+	// default number of retries of a tx issuance that failed with a transient error
+	DefaultIssueRetries = 3
+	// default wait before the first retry of a tx issuance, doubled on each retry
+	DefaultIssueRetryBackoff = time.Second
+	// default maximum number of nodes concurrently queried or checked during the setup
+	DefaultMaxConcurrency = 16
+	// default time budget for the nodes of a loaded config to reach its readiness mode
+	DefaultReadinessTimeout = 5 * time.Minute
+)
+
+// ReadinessMode gives what the network creation from a config waits for
+type ReadinessMode int
+
+const (
+	// the network creation returns once the nodes are started
+	ReadinessNone ReadinessMode = iota
+	// the network creation waits for the primary network chains of all the
+	// nodes to be bootstrapped, but not for the nodes to be healthy, e.g. for
+	// the custom VMs to be ready
+	ReadinessBootstrapped
+	// the network creation waits for all the nodes to be healthy
+	ReadinessHealthy
+)
+
+func (m ReadinessMode) String() string {
+	switch m {
+	case ReadinessNone:
+		return "none"
+	case ReadinessBootstrapped:
+		return "bootstrapped"
+	case ReadinessHealthy:
+		return "healthy"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(m))
+	}
+}
+
+const (
+	// snapshot node dbs are copied as they are
+	SnapshotCompressionNone = ""
+	// snapshot node dbs are saved as gzip compressed tar archives
+	SnapshotCompressionGzip = "gzip"
+)
+
+// DelegatorSpec defines a delegation to a primary network validator
+type DelegatorSpec struct {
+	// Name of the validator node to delegate to, either a current
+	// or a pending primary network validator
+	NodeName string
+	// Amount of nAVAX to delegate
+	Weight uint64
+}
+
+// SetupOp holds the optional settings used when creating subnets and blockchains
+type SetupOp struct {
+	// Delegations to issue to the primary network validators
+	Delegators []DelegatorSpec
+	// P-Chain client used for the queries of the setup, e.g. of the validators.
+	// If nil, a client for the node issuing transactions is used.
+	// Only the queries are redirected: transactions are still issued by the wallet
+	// through the tx node of the network, as selected by TxNodeName.
+	PlatformClient platformvm.Client
+	// Name of the node used to issue transactions.
+	// If empty, the first node by name, in the order of GetNodeNames, that passes
+	// a health check is used.
+	TxNodeName string
+	// Names of the nodes used to issue, respectively, the validator and delegator
+	// txs, and the blockchain creation txs, so that their propagation from other
+	// nodes than the one issuing the rest of the txs is exercised.
+	// If empty, the node used to issue transactions is used.
+	ValidatorsTxNodeName  string
+	BlockchainsTxNodeName string
+	// Time budget of each setup phase: creating subnets, including the restart
+	// of the nodes to track them, adding validators and delegators, creating
+	// blockchains, and waiting for them to bootstrap.
+	// Each phase fails with a phase specific error if its budget is exceeded.
+	// If 0, the phase is only limited by the context of the setup.
+	SubnetsTimeout     time.Duration
+	ValidatorsTimeout  time.Duration
+	BlockchainsTimeout time.Duration
+	BootstrapTimeout   time.Duration
+	// Number of times a tx issuance is retried if it fails with a transient
+	// network error, and wait before the first retry, doubled on each retry.
+	// Definitive rejections are not retried.
+	IssueRetries      uint32
+	IssueRetryBackoff time.Duration
+	// Addresses of the control keys of the created subnets, and number of
+	// them needed to sign subnet txs.
+	// If empty, the pre-funded test key is the only control key, with threshold 1.
+	// Subnet txs, like adding validators and creating blockchains, are signed with
+	// the funded key and [SubnetSigningKeys], so the threshold must be reachable
+	// with them for the setup to succeed.
+	ControlKeys []string
+	Threshold   uint32
+	// Private keys, "PrivateKey-" prefixed and CB58 encoded, also signing the
+	// subnet txs, for subnets controlled by multiple keys.
+	// The setup fails before issuing subnet txs if the keys controlling a subnet
+	// don't reach its threshold.
+	SubnetSigningKeys []string
+	// Maximum number of nodes concurrently queried or checked for health during
+	// the setup, so large networks don't start a goroutine and a connection per
+	// node for each check. Must be greater than 0.
+	MaxConcurrency uint32
+	// VM name --> path appended to the blockchain path of the endpoints returned
+	// for the blockchains of that VM (e.g. "/rpc"), so they are directly usable
+	// by VM clients. VMs not in the map get the plain blockchain path.
+	RPCPaths map[string]string
+	// If greater than 0, maximum difference between the clock of any node and the
+	// local one, checked before issuing the validator txs, whose start times are
+	// relative to the node clocks.
+	// If 0, the clocks are not checked.
+	MaxClockSkew time.Duration
+	// Number of nodes that may fail to confirm the setup txs, i.e. the created
+	// subnets and the validator txs, e.g. because they are unreachable, without
+	// failing the setup. At least one node must always confirm them.
+	// If 0, all the nodes must confirm them.
+	MaxUnconfirmedNodes uint32
+	// Fraction, in (0, 1], of the nodes on which the created blockchains must be running
+	// for the setup to succeed, e.g. 0.8 for 80% of them, so a slow node doesn't block it.
+	// The other nodes keep being waited for in the background, for at most
+	// [BootstrapTimeout] if not 0, and logged once ready or failing.
+	// If 0, all the nodes must be running the blockchains.
+	BootstrapQuorum float64
+	// Private key, "PrivateKey-" prefixed and CB58 encoded, signing all the setup
+	// txs, which are issued without using the node keystore.
+	// It must be funded on the P-Chain, e.g. by a genesis from GenerateGenesis.
+	// If empty, the pre-funded test key of the default genesis is used.
+	FundedKey string
+	// If true, subnet validator txs are issued also for the nodes that are already
+	// current or pending validators of the subnet, which are otherwise skipped.
+	// The P-Chain may reject them as duplicates.
+	ForceSubnetValidatorTxs bool
+	// Optional subnet config (e.g. gossip settings, validator only), JSON encoded,
+	// applied to each created subnet. It is written to the subnet config dir of
+	// every node before the nodes are restarted to whitelist the subnets, and kept
+	// as a network default for nodes added later.
+	SubnetConfig []byte
+	// If true, the blockchains not being ready and validated once created, in the
+	// bootstrap phase, is logged as a warning instead of failing the setup, which
+	// then returns the endpoints of the nodes already running each blockchain.
+	BestEffort bool
+	// If true, a blockchain whose spec matches, by VM name and VM ID, a blockchain
+	// already on its subnet is not created again, and the existing one is used
+	// instead, so that deploying again is idempotent.
+	IfNotExists bool
+	// Optional ID added as a field to the log lines of the setup, so that the logs
+	// of concurrent setups can be told apart.
+	RequestID string
+	// If not empty, path of a file to which the endpoints returned by the
+	// blockchain deployment are also written, as JSON, for other tools to use.
+	// The file is replaced atomically, so it is never seen partially written.
+	// If it can't be written, the endpoints are returned along with the error.
+	EndpointsFile string
+}
+
+// SetupOption sets optional settings of a SetupOp
+type SetupOption func(*SetupOp)
+
+// NewSetupOp returns a SetupOp with default settings, modified by [opts]
+func NewSetupOp(opts ...SetupOption) *SetupOp {
+	op := &SetupOp{
+		IssueRetries:      DefaultIssueRetries,
+		IssueRetryBackoff: DefaultIssueRetryBackoff,
+		MaxConcurrency:    DefaultMaxConcurrency,
+	}
+	for _, opt := range opts {
+		opt(op)
+	}
+	return op
+}
+
+// WithDelegators adds delegations to primary network validators
+func WithDelegators(delegators []DelegatorSpec) SetupOption {
+	return func(op *SetupOp) {
+		op.Delegators = delegators
+	}
+}
+
+// WithPlatformClient sets the P-Chain client used for queries only, as
+// transactions are still issued through the tx node of the network
+func WithPlatformClient(platformCli platformvm.Client) SetupOption {
+	return func(op *SetupOp) {
+		op.PlatformClient = platformCli
+	}
+}
+
+// WithTxNodeName sets the name of the node used to issue transactions
+func WithTxNodeName(nodeName string) SetupOption {
+	return func(op *SetupOp) {
+		op.TxNodeName = nodeName
+	}
+}
+
+// WithValidatorsTxNodeName sets the name of the node used to issue the validator and delegator txs
+func WithValidatorsTxNodeName(nodeName string) SetupOption {
+	return func(op *SetupOp) {
+		op.ValidatorsTxNodeName = nodeName
+	}
+}
+
+// WithBlockchainsTxNodeName sets the name of the node used to issue the blockchain creation txs
+func WithBlockchainsTxNodeName(nodeName string) SetupOption {
+	return func(op *SetupOp) {
+		op.BlockchainsTxNodeName = nodeName
+	}
+}
+
+// WithSubnetsTimeout sets the time budget for creating subnets
+func WithSubnetsTimeout(timeout time.Duration) SetupOption {
+	return func(op *SetupOp) {
+		op.SubnetsTimeout = timeout
+	}
+}
+
+// WithValidatorsTimeout sets the time budget for adding validators and delegators
+func WithValidatorsTimeout(timeout time.Duration) SetupOption {
+	return func(op *SetupOp) {
+		op.ValidatorsTimeout = timeout
+	}
+}
+
+// WithBlockchainsTimeout sets the time budget for creating blockchains
+func WithBlockchainsTimeout(timeout time.Duration) SetupOption {
+	return func(op *SetupOp) {
+		op.BlockchainsTimeout = timeout
+	}
+}
+
+// WithBootstrapTimeout sets the time budget for waiting for the created blockchains to bootstrap
+func WithBootstrapTimeout(timeout time.Duration) SetupOption {
+	return func(op *SetupOp) {
+		op.BootstrapTimeout = timeout
+	}
+}
+
+// WithIssueRetries sets the retries and initial backoff of tx issuances failing with a transient error
+func WithIssueRetries(retries uint32, backoff time.Duration) SetupOption {
+	return func(op *SetupOp) {
+		op.IssueRetries = retries
+		op.IssueRetryBackoff = backoff
+	}
+}
+
+// WithSubnetControlKeys sets the control keys and threshold of the created subnets
+func WithSubnetControlKeys(controlKeys []string, threshold uint32) SetupOption {
+	return func(op *SetupOp) {
+		op.ControlKeys = controlKeys
+		op.Threshold = threshold
+	}
+}
+
+// WithSubnetSigningKeys sets additional private keys signing the subnet txs
+func WithSubnetSigningKeys(signingKeys []string) SetupOption {
+	return func(op *SetupOp) {
+		op.SubnetSigningKeys = signingKeys
+	}
+}
+
+// WithMaxConcurrency sets the maximum number of nodes concurrently queried or checked during the setup
+func WithMaxConcurrency(maxConcurrency uint32) SetupOption {
+	return func(op *SetupOp) {
+		op.MaxConcurrency = maxConcurrency
+	}
+}
+
+// WithRPCPaths sets the VM specific paths appended to the returned blockchain endpoints
+func WithRPCPaths(rpcPaths map[string]string) SetupOption {
+	return func(op *SetupOp) {
+		op.RPCPaths = rpcPaths
+	}
+}
+
+// WithMaxClockSkew sets the maximum node clock skew checked before issuing the validator txs
+func WithMaxClockSkew(maxSkew time.Duration) SetupOption {
+	return func(op *SetupOp) {
+		op.MaxClockSkew = maxSkew
+	}
+}
+
+// WithBootstrapQuorum sets the fraction of the nodes that must be running the created blockchains
+func WithBootstrapQuorum(quorum float64) SetupOption {
+	return func(op *SetupOp) {
+		op.BootstrapQuorum = quorum
+	}
+}
+
+// WithMaxUnconfirmedNodes sets the number of nodes that may fail to confirm the setup txs
+func WithMaxUnconfirmedNodes(maxUnconfirmedNodes uint32) SetupOption {
+	return func(op *SetupOp) {
+		op.MaxUnconfirmedNodes = maxUnconfirmedNodes
+	}
+}
+
+// WithFundedKey sets the private key signing the setup txs
+func WithFundedKey(fundedKey string) SetupOption {
+	return func(op *SetupOp) {
+		op.FundedKey = fundedKey
+	}
+}
+
+// WithForceSubnetValidatorTxs sets whether subnet validator txs are issued for nodes already validating the subnet
+func WithForceSubnetValidatorTxs(force bool) SetupOption {
+	return func(op *SetupOp) {
+		op.ForceSubnetValidatorTxs = force
+	}
+}
+
+// WithSubnetConfig sets the subnet config applied to each created subnet
+func WithSubnetConfig(subnetConfig []byte) SetupOption {
+	return func(op *SetupOp) {
+		op.SubnetConfig = subnetConfig
+	}
+}
+
+// WithBestEffort sets whether the bootstrap phase errors are downgraded to warnings
+func WithBestEffort(bestEffort bool) SetupOption {
+	return func(op *SetupOp) {
+		op.BestEffort = bestEffort
+	}
+}
+
+// WithIfNotExists sets whether existing blockchains matching the specs are used instead of created
+func WithIfNotExists(ifNotExists bool) SetupOption {
+	return func(op *SetupOp) {
+		op.IfNotExists = ifNotExists
+	}
+}
+
+// WithRequestID sets the ID added to the log lines of the setup
+func WithRequestID(requestID string) SetupOption {
+	return func(op *SetupOp) {
+		op.RequestID = requestID
+	}
+}
+
+// WithEndpointsFile sets the path of the file the returned blockchain endpoints are written to
+func WithEndpointsFile(path string) SetupOption {
+	return func(op *SetupOp) {
+		op.EndpointsFile = path
+	}
+}
+
+// SnapshotOp holds the optional settings used when saving a snapshot
+type SnapshotOp struct {
+	// Compression of the node dbs saved in the snapshot.
+	// Either SnapshotCompressionNone or SnapshotCompressionGzip.
+	// It is recorded in the snapshot, so that loading it decompresses the dbs.
+	Compression string
+	// Maximum number of node dbs concurrently saved. Must be greater than 0.
+	MaxConcurrency uint32
+}
+
+// SnapshotOption sets optional settings of a SnapshotOp
+type SnapshotOption func(*SnapshotOp)
+
+// NewSnapshotOp returns a SnapshotOp with default settings, modified by [opts]
+func NewSnapshotOp(opts ...SnapshotOption) *SnapshotOp {
+	op := &SnapshotOp{
+		Compression:    SnapshotCompressionNone,
+		MaxConcurrency: DefaultMaxConcurrency,
+	}
+	for _, opt := range opts {
+		opt(op)
+	}
+	return op
+}
+
+// WithSnapshotCompression sets the compression of the node dbs saved in the snapshot
+func WithSnapshotCompression(compression string) SnapshotOption {
+	return func(op *SnapshotOp) {
+		op.Compression = compression
+	}
+}
+
+// HealthOp holds the optional settings used when waiting for the network to be healthy
+type HealthOp struct {
+	// If greater than 0, duration for which all the nodes must be healthy, at each
+	// of the periodic health checks, for the network to be considered healthy,
+	// so that nodes flapping during bootstrap are waited for.
+	// If 0, a single healthy check of each node is enough.
+	MinHealthyDuration time.Duration
+}
+
+// HealthOption sets optional settings of a HealthOp
+type HealthOption func(*HealthOp)
+
+// NewHealthOp returns a HealthOp with default settings, modified by [opts]
+func NewHealthOp(opts ...HealthOption) *HealthOp {
+	op := &HealthOp{}
+	for _, opt := range opts {
+		opt(op)
+	}
+	return op
+}
+
+// WithMinHealthyDuration sets the duration for which all the nodes must stay healthy
+func WithMinHealthyDuration(minHealthyDuration time.Duration) HealthOption {
+	return func(op *HealthOp) {
+		op.MinHealthyDuration = minHealthyDuration
+	}
+}
+
+// WithSnapshotMaxConcurrency sets the maximum number of node dbs concurrently saved
+func WithSnapshotMaxConcurrency(maxConcurrency uint32) SnapshotOption {
+	return func(op *SnapshotOp) {
+		op.MaxConcurrency = maxConcurrency
+	}
+}
+
+// LoadConfigOp holds the optional settings used when creating a network from a config
+type LoadConfigOp struct {
+	// If not nil, called with the name of each node of the config once it becomes
+	// healthy, while the network creation returns without waiting for it.
+	// Nodes are watched until they become healthy, or they or the network are stopped.
+	// It may be called concurrently for different nodes.
+	OnNodeHealthy func(nodeName string)
+	// What the network creation waits for before returning. Defaults to ReadinessNone.
+	// If the nodes don't reach it within [ReadinessTimeout], they are stopped.
+	ReadinessMode    ReadinessMode
+	ReadinessTimeout time.Duration
+}
+
+// LoadConfigOption sets optional settings of a LoadConfigOp
+type LoadConfigOption func(*LoadConfigOp)
+
+// NewLoadConfigOp returns a LoadConfigOp with default settings, modified by [opts]
+func NewLoadConfigOp(opts ...LoadConfigOption) *LoadConfigOp {
+	op := &LoadConfigOp{
+		ReadinessTimeout: DefaultReadinessTimeout,
+	}
+	for _, opt := range opts {
+		opt(op)
+	}
+	return op
+}
+
+// WithOnNodeHealthy sets the function called as each node of the config becomes healthy
+func WithOnNodeHealthy(onNodeHealthy func(nodeName string)) LoadConfigOption {
+	return func(op *LoadConfigOp) {
+		op.OnNodeHealthy = onNodeHealthy
+	}
+}
+
+// WithReadinessMode sets what the network creation waits for before returning
+func WithReadinessMode(mode ReadinessMode) LoadConfigOption {
+	return func(op *LoadConfigOp) {
+		op.ReadinessMode = mode
+	}
+}
+
+// WithReadinessTimeout sets the time budget for the nodes to reach the readiness mode
+func WithReadinessTimeout(timeout time.Duration) LoadConfigOption {
+	return func(op *LoadConfigOp) {
+		op.ReadinessTimeout = timeout
+	}
+}
+
+// PortOverride gives the ports of a node loaded from a snapshot, replacing the saved ones.
+// A zero port is allocated from the network port range.
+type PortOverride struct {
+	APIPort uint16
+	P2PPort uint16
+}
+
+// LoadSnapshotOp holds the optional settings used when loading a snapshot
+type LoadSnapshotOp struct {
+	// Node name --> ports to use instead of the saved ones.
+	PortOverrides map[string]PortOverride
+	// If any of them is not 0, range of ports replacing the saved network port range,
+	// from which all the node ports not given in [PortOverrides] are allocated.
+	MinPort uint16
+	MaxPort uint16
+}
+
+// LoadSnapshotOption sets optional settings of a LoadSnapshotOp
+type LoadSnapshotOption func(*LoadSnapshotOp)
+
+// NewLoadSnapshotOp returns a LoadSnapshotOp with default settings, modified by [opts]
+func NewLoadSnapshotOp(opts ...LoadSnapshotOption) *LoadSnapshotOp {
+	op := &LoadSnapshotOp{}
+	for _, opt := range opts {
+		opt(op)
+	}
+	return op
+}
+
+// WithPortOverrides sets the ports to use for the given nodes instead of the saved ones
+func WithPortOverrides(portOverrides map[string]PortOverride) LoadSnapshotOption {
+	return func(op *LoadSnapshotOp) {
+		op.PortOverrides = portOverrides
+	}
+}
+
+// WithSnapshotPortRange sets the range from which new ports are allocated for the loaded nodes
+func WithSnapshotPortRange(minPort uint16, maxPort uint16) LoadSnapshotOption {
+	return func(op *LoadSnapshotOp) {
+		op.MinPort = minPort
+		op.MaxPort = maxPort
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/ava-labs/avalanche-network-runner/local"
 	"github.com/ava-labs/avalanche-network-runner/network"
 	"github.com/ava-labs/avalanche-network-runner/rpcpb"
+	"github.com/ava-labs/avalanche-network-runner/utils"
 	"github.com/ava-labs/avalanche-network-runner/utils/constants"
 	"github.com/ava-labs/avalanche-network-runner/ux"
 	"github.com/ava-labs/avalanchego/config"
@@ -275,7 +276,7 @@ func (lc *localNetwork) createBlockchains(
 		return
 	}
 
-	if err := lc.nw.CreateBlockchains(ctx, chainSpecs); err != nil {
+	if _, err := lc.nw.CreateBlockchains(ctx, chainSpecs); err != nil {
 		lc.startErrCh <- err
 		return
 	}
@@ -319,7 +320,7 @@ func (lc *localNetwork) createSubnets(
 		return
 	}
 
-	if err := lc.nw.CreateSubnets(ctx, numSubnets); err != nil {
+	if _, err := lc.nw.CreateSubnets(ctx, numSubnets); err != nil {
 		lc.startErrCh <- err
 		return
 	}
@@ -480,7 +481,7 @@ func (lc *localNetwork) updateNodeInfo() error {
 
 		lc.nodeInfos[name] = &rpcpb.NodeInfo{
 			Name:               node.GetName(),
-			Uri:                fmt.Sprintf("http://%s:%d", node.GetURL(), node.GetAPIPort()),
+			Uri:                "http://" + utils.JoinHostPort(node.GetURL(), node.GetAPIPort()),
 			Id:                 node.GetNodeID().String(),
 			ExecPath:           node.GetBinaryPath(),
 			LogDir:             node.GetLogsDir(),
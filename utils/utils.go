@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ava-labs/avalanchego/ids"
@@ -17,6 +21,14 @@ const (
 	dirTimestampFormat  = "20060102_150405"
 )
 
+// JoinHostPort returns [host]:[port], usable in a URL, with [host] enclosed in square
+// brackets if it is an IPv6 address. [host] may also be a hostname, or an already
+// bracketed IPv6 address.
+func JoinHostPort(host string, port uint16) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+}
+
 func ToNodeID(stakingKey, stakingCert []byte) (ids.NodeID, error) {
 	cert, err := staking.LoadTLSCertFromBytes(stakingKey, stakingCert)
 	if err != nil {
@@ -48,6 +60,7 @@ var (
 	ErrNotExists              = errors.New("avalanche exec not exists")
 	ErrNotExistsPlugin        = errors.New("plugin exec not exists")
 	ErrNotExistsPluginGenesis = errors.New("plugin genesis not exists")
+	ErrNotExecutable          = errors.New("avalanche exec is not executable")
 )
 
 func CheckExecPath(exec string) error {
@@ -64,6 +77,22 @@ func CheckExecPath(exec string) error {
 	return nil
 }
 
+// CheckExecutable checks as CheckExecPath that [exec] exists, and also that
+// it is a file executable by someone.
+func CheckExecutable(exec string) error {
+	if err := CheckExecPath(exec); err != nil {
+		return err
+	}
+	info, err := os.Stat(exec)
+	if err != nil {
+		return fmt.Errorf("failed to stat exec %q (%w)", exec, err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+		return ErrNotExecutable
+	}
+	return nil
+}
+
 func CheckPluginPaths(pluginExec string, pluginGenesisPath string) error {
 	var err error
 	if _, err = os.Stat(pluginExec); err != nil {
@@ -91,6 +120,52 @@ func VMID(vmName string) (ids.ID, error) {
 	return ids.ToID(b)
 }
 
+// SortNodeNames sorts [names] in natural order, comparing runs of digits by their
+// numeric value, so that "node-2" sorts before "node-10".
+func SortNodeNames(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return naturalLess(names[i], names[j])
+	})
+}
+
+// Returns true if [a] sorts before [b] in natural order
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits == "" || bDigits == "" {
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		// numbers are compared by length once their leading zeros are removed,
+		// and then digit by digit, so they can be arbitrarily large
+		aNum, bNum := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+		if len(aNum) != len(bNum) {
+			return len(aNum) < len(bNum)
+		}
+		if aNum != bNum {
+			return aNum < bNum
+		}
+		// equal numbers with less leading zeros first, so the order is total
+		if len(aDigits) != len(bDigits) {
+			return len(aDigits) < len(bDigits)
+		}
+		a, b = a[len(aDigits):], b[len(bDigits):]
+	}
+	return len(a) < len(b)
+}
+
+// Returns the run of digits at the start of [s]
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
 func MkDirWithTimestamp(dirPrefix string) (string, error) {
 	currentTime := time.Now().Format(dirTimestampFormat)
 	dirName := dirPrefix + "_" + currentTime
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -83,6 +85,29 @@ func TestCheckExecPath(t *testing.T) {
 	}
 }
 
+func TestCheckExecutable(t *testing.T) {
+	dir := t.TempDir()
+	execPath := filepath.Join(dir, "exec")
+	assert.NoError(t, os.WriteFile(execPath, nil, 0o755))
+	nonExecPath := filepath.Join(dir, "non-exec")
+	assert.NoError(t, os.WriteFile(nonExecPath, nil, 0o644))
+
+	tt := []struct {
+		execPath    string
+		expectedErr error
+	}{
+		{execPath: execPath, expectedErr: nil},
+		{execPath: nonExecPath, expectedErr: ErrNotExecutable},
+		{execPath: dir, expectedErr: ErrNotExecutable},
+		{execPath: filepath.Join(dir, "not-exists"), expectedErr: ErrNotExists},
+		{execPath: "", expectedErr: ErrInvalidExecPath},
+	}
+	for i, tv := range tt {
+		err := CheckExecutable(tv.execPath)
+		assert.Equal(t, tv.expectedErr, err, fmt.Sprintf("[%d] unexpected error", i))
+	}
+}
+
 func TestCheckPluginPaths(t *testing.T) {
 	pluginF, err := os.CreateTemp(os.TempDir(), "test-check-exec-plugin")
 	assert.NoError(t, err)
@@ -125,3 +150,31 @@ func TestCheckPluginPaths(t *testing.T) {
 		assert.Equal(t, tv.expectedErr, err, fmt.Sprintf("[%d] unexpected error", i))
 	}
 }
+
+func TestSortNodeNames(t *testing.T) {
+	names := []string{"node-10", "node-2", "node-1", "beacon", "node-02", "node-9b", "node-9a", "node-100", "node"}
+	SortNodeNames(names)
+	assert.Equal(t, []string{"beacon", "node", "node-1", "node-2", "node-02", "node-9a", "node-9b", "node-10", "node-100"}, names)
+}
+
+func TestJoinHostPort(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{host: "127.0.0.1", expected: "127.0.0.1:9650"},
+		{host: "::1", expected: "[::1]:9650"},
+		{host: "[::1]", expected: "[::1]:9650"},
+		{host: "localhost", expected: "localhost:9650"},
+		{host: "node1.example.com", expected: "node1.example.com:9650"},
+	}
+	for _, tt := range tests {
+		hostPort := JoinHostPort(tt.host, 9650)
+		assert.Equal(t, tt.expected, hostPort)
+		// the result is a valid URL host
+		u, err := url.Parse("http://" + hostPort + "/ext/info")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "9650", u.Port())
+		}
+	}
+}